@@ -3936,6 +3936,11 @@ type ModifyCacheClusterInput struct {
 	// 2 (7 - 5) cache node IDs to remove.
 	CacheNodeIdsToRemove []*string `locationNameList:"CacheNodeId" type:"list"`
 
+	// A valid cache node type that you want to scale this cache cluster to. The
+	// value of this parameter must be one of the ScaleUpModifications values returned
+	// by the ListAllowedCacheNodeTypeModification action.
+	CacheNodeType *string `type:"string"`
+
 	// The name of the cache parameter group to apply to this cache cluster. This
 	// change is asynchronously applied as soon as possible for parameters when
 	// the ApplyImmediately parameter is specified as true for this request.
@@ -0,0 +1,532 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/frodenas/brokerapi"
+	"github.com/frodenas/brokerapi/auth"
+	"github.com/pivotal-golang/lager"
+)
+
+const provisionLogKey = "provision"
+const updateLogKey = "update"
+const deprovisionLogKey = "deprovision"
+const bindLogKey = "bind"
+const unbindLogKey = "unbind"
+const lastOperationLogKey = "last-operation"
+const fetchInstanceLogKey = "fetch-instance"
+const fetchBindingLogKey = "fetch-binding"
+
+const instanceIDLogKey = "instance-id"
+const bindingIDLogKey = "binding-id"
+const provisionDetailsLogKey = "provision-details"
+const updateDetailsLogKey = "update-details"
+const deprovisionDetailsLogKey = "deprovision-details"
+const bindDetailsLogKey = "bind-details"
+const unbindDetailsLogKey = "unbind-details"
+
+const invalidProvisionDetailsErrorKey = "invalid-provision-details"
+const invalidUpdateDetailsErrorKey = "invalid-update-details"
+const invalidBindDetailsErrorKey = "invalid-bind-details"
+
+const instanceAlreadyExistsErrorKey = "instance-already-exists"
+const instanceMissingErrorKey = "instance-missing"
+const instanceLimitReachedErrorKey = "instance-limit-reached"
+const instanceAsyncRequiredErrorKey = "instance-async-required"
+const instanceNotUpdateableErrorKey = "instance-not-updateable"
+const instanceNotBindableErrorKey = "instance-not-bindable"
+const bindingAlreadyExistsErrorKey = "binding-already-exists"
+const bindingMissingErrorKey = "binding-missing"
+const fetchNotSupportedErrorKey = "fetch-not-supported"
+const bindingAppGUIDRequiredErrorKey = "binding-app-guid-required"
+const unknownErrorKey = "unknown-error"
+
+const statusUnprocessableEntity = 422
+
+// ErrInstanceAlreadyProvisioned is returned by Provision, along with the provisioning response,
+// when an identical service instance already exists. It is reported as 200 OK.
+var ErrInstanceAlreadyProvisioned = errors.New("instance already provisioned")
+
+// ErrBindingAlreadyBound is returned by Bind, along with the binding response, when an
+// identical service binding already exists. It is reported as 200 OK.
+var ErrBindingAlreadyBound = errors.New("binding already bound")
+
+// New builds the broker HTTP handler. It mirrors brokerapi.New, but also honours
+// FailureResponse errors so the broker can return status codes brokerapi does not map.
+func New(serviceBroker brokerapi.ServiceBroker, logger lager.Logger, brokerCredentials brokerapi.BrokerCredentials) http.Handler {
+	router := newHTTPRouter()
+
+	router.Get("/v2/catalog", catalog(serviceBroker, router, logger))
+
+	router.Get("/v2/service_instances/{instance_id}", fetchInstance(serviceBroker, router, logger))
+	router.Put("/v2/service_instances/{instance_id}", provision(serviceBroker, router, logger))
+	router.Patch("/v2/service_instances/{instance_id}", update(serviceBroker, router, logger))
+	router.Delete("/v2/service_instances/{instance_id}", deprovision(serviceBroker, router, logger))
+
+	router.Get("/v2/service_instances/{instance_id}/service_bindings/{binding_id}", fetchBinding(serviceBroker, router, logger))
+	router.Put("/v2/service_instances/{instance_id}/service_bindings/{binding_id}", bind(serviceBroker, router, logger))
+	router.Delete("/v2/service_instances/{instance_id}/service_bindings/{binding_id}", unbind(serviceBroker, router, logger))
+
+	router.Get("/v2/service_instances/{instance_id}/last_operation", lastOperation(serviceBroker, router, logger))
+
+	return wrapAuth(router, brokerCredentials)
+}
+
+func wrapAuth(router httpRouter, credentials brokerapi.BrokerCredentials) http.Handler {
+	return auth.NewWrapper(credentials.Username, credentials.Password).Wrap(router)
+}
+
+func catalog(serviceBroker brokerapi.ServiceBroker, router httpRouter, logger lager.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if catalogProvider, ok := serviceBroker.(CatalogProvider); ok {
+			respond(w, http.StatusOK, catalogProvider.Catalog())
+			return
+		}
+
+		catalogResponse := serviceBroker.Services()
+
+		respond(w, http.StatusOK, catalogResponse)
+	}
+}
+
+func provision(serviceBroker brokerapi.ServiceBroker, router httpRouter, logger lager.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := router.Vars(req)
+		instanceID := vars["instance_id"]
+		acceptsIncomplete := false
+		if req.URL.Query().Get("accepts_incomplete") == "true" {
+			acceptsIncomplete = true
+		}
+
+		logger := logger.Session(provisionLogKey, lager.Data{
+			instanceIDLogKey: instanceID,
+		})
+
+		var details brokerapi.ProvisionDetails
+		if err := json.NewDecoder(req.Body).Decode(&details); err != nil {
+			logger.Error(invalidProvisionDetailsErrorKey, err)
+			respond(w, http.StatusBadRequest, brokerapi.ErrorResponse{
+				Description: err.Error(),
+			})
+			return
+		}
+
+		logger = logger.WithData(lager.Data{
+			provisionDetailsLogKey: details,
+		})
+
+		provisioningResponse, asynch, err := serviceBroker.Provision(instanceID, details, acceptsIncomplete)
+		if err == ErrInstanceAlreadyProvisioned {
+			respond(w, http.StatusOK, provisioningResponse)
+			return
+		}
+		if err != nil {
+			if respondWithFailure(w, logger, err) {
+				return
+			}
+			switch err {
+			case brokerapi.ErrInstanceAlreadyExists:
+				logger.Error(instanceAlreadyExistsErrorKey, err)
+				respond(w, http.StatusConflict, brokerapi.EmptyResponse{})
+			case brokerapi.ErrInstanceLimitMet:
+				logger.Error(instanceLimitReachedErrorKey, err)
+				respond(w, http.StatusInternalServerError, brokerapi.ErrorResponse{
+					Description: err.Error(),
+				})
+			case brokerapi.ErrAsyncRequired:
+				logger.Error(instanceAsyncRequiredErrorKey, err)
+				respond(w, statusUnprocessableEntity, brokerapi.ErrorResponse{
+					Error:       "AsyncRequired",
+					Description: err.Error(),
+				})
+			default:
+				logger.Error(unknownErrorKey, err)
+				respond(w, http.StatusInternalServerError, brokerapi.ErrorResponse{
+					Description: err.Error(),
+				})
+			}
+			return
+		}
+
+		if asynch {
+			respond(w, http.StatusAccepted, provisioningResponse)
+			return
+		}
+
+		respond(w, http.StatusCreated, provisioningResponse)
+	}
+}
+
+func update(serviceBroker brokerapi.ServiceBroker, router httpRouter, logger lager.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := router.Vars(req)
+		instanceID := vars["instance_id"]
+		acceptsIncomplete := false
+		if req.URL.Query().Get("accepts_incomplete") == "true" {
+			acceptsIncomplete = true
+		}
+
+		logger := logger.Session(updateLogKey, lager.Data{
+			instanceIDLogKey: instanceID,
+		})
+
+		var details brokerapi.UpdateDetails
+		if err := json.NewDecoder(req.Body).Decode(&details); err != nil {
+			logger.Error(invalidUpdateDetailsErrorKey, err)
+			respond(w, http.StatusBadRequest, brokerapi.ErrorResponse{
+				Description: err.Error(),
+			})
+			return
+		}
+
+		logger = logger.WithData(lager.Data{
+			updateDetailsLogKey: details,
+		})
+
+		asynch, err := serviceBroker.Update(instanceID, details, acceptsIncomplete)
+		if err != nil {
+			if respondWithFailure(w, logger, err) {
+				return
+			}
+			switch err {
+			case brokerapi.ErrInstanceDoesNotExist:
+				logger.Error(instanceMissingErrorKey, err)
+				respond(w, http.StatusNotFound, brokerapi.ErrorResponse{
+					Description: err.Error(),
+				})
+			case brokerapi.ErrAsyncRequired:
+				logger.Error(instanceAsyncRequiredErrorKey, err)
+				respond(w, statusUnprocessableEntity, brokerapi.ErrorResponse{
+					Error:       "AsyncRequired",
+					Description: err.Error(),
+				})
+			case brokerapi.ErrInstanceNotUpdateable:
+				logger.Error(instanceNotUpdateableErrorKey, err)
+				respond(w, http.StatusInternalServerError, brokerapi.ErrorResponse{
+					Description: err.Error(),
+				})
+			default:
+				logger.Error(unknownErrorKey, err)
+				respond(w, http.StatusInternalServerError, brokerapi.ErrorResponse{
+					Description: err.Error(),
+				})
+			}
+			return
+		}
+
+		if asynch {
+			respond(w, http.StatusAccepted, brokerapi.EmptyResponse{})
+			return
+		}
+
+		respond(w, http.StatusOK, brokerapi.EmptyResponse{})
+	}
+}
+
+func deprovision(serviceBroker brokerapi.ServiceBroker, router httpRouter, logger lager.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := router.Vars(req)
+		instanceID := vars["instance_id"]
+		acceptsIncomplete := false
+		if req.URL.Query().Get("accepts_incomplete") == "true" {
+			acceptsIncomplete = true
+		}
+
+		logger := logger.Session(deprovisionLogKey, lager.Data{
+			instanceIDLogKey: instanceID,
+		})
+
+		details := brokerapi.DeprovisionDetails{
+			ServiceID: req.FormValue("service_id"),
+			PlanID:    req.FormValue("plan_id"),
+		}
+
+		logger = logger.WithData(lager.Data{
+			deprovisionDetailsLogKey: details,
+		})
+
+		asynch, err := serviceBroker.Deprovision(instanceID, details, acceptsIncomplete)
+		if err != nil {
+			if respondWithFailure(w, logger, err) {
+				return
+			}
+			switch err {
+			case brokerapi.ErrInstanceDoesNotExist:
+				logger.Error(instanceMissingErrorKey, err)
+				respond(w, http.StatusGone, brokerapi.EmptyResponse{})
+			case brokerapi.ErrAsyncRequired:
+				logger.Error(instanceAsyncRequiredErrorKey, err)
+				respond(w, statusUnprocessableEntity, brokerapi.ErrorResponse{
+					Error:       "AsyncRequired",
+					Description: err.Error(),
+				})
+			default:
+				logger.Error(unknownErrorKey, err)
+				respond(w, http.StatusInternalServerError, brokerapi.ErrorResponse{
+					Description: err.Error(),
+				})
+			}
+			return
+		}
+
+		if asynch {
+			respond(w, http.StatusAccepted, brokerapi.EmptyResponse{})
+			return
+		}
+
+		respond(w, http.StatusOK, brokerapi.EmptyResponse{})
+	}
+}
+
+func bind(serviceBroker brokerapi.ServiceBroker, router httpRouter, logger lager.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := router.Vars(req)
+		instanceID := vars["instance_id"]
+		bindingID := vars["binding_id"]
+
+		logger := logger.Session(bindLogKey, lager.Data{
+			instanceIDLogKey: instanceID,
+			bindingIDLogKey:  bindingID,
+		})
+
+		var details brokerapi.BindDetails
+		if err := json.NewDecoder(req.Body).Decode(&details); err != nil {
+			logger.Error(invalidBindDetailsErrorKey, err)
+			respond(w, http.StatusBadRequest, brokerapi.ErrorResponse{
+				Description: err.Error(),
+			})
+			return
+		}
+
+		logger = logger.WithData(lager.Data{
+			bindDetailsLogKey: details,
+		})
+
+		bindingResponse, err := serviceBroker.Bind(instanceID, bindingID, details)
+		if err == ErrBindingAlreadyBound {
+			respond(w, http.StatusOK, bindingResponse)
+			return
+		}
+		if err != nil {
+			if respondWithFailure(w, logger, err) {
+				return
+			}
+			switch err {
+			case brokerapi.ErrInstanceDoesNotExist:
+				logger.Error(instanceMissingErrorKey, err)
+				respond(w, http.StatusNotFound, brokerapi.ErrorResponse{
+					Description: err.Error(),
+				})
+			case brokerapi.ErrBindingAlreadyExists:
+				logger.Error(bindingAlreadyExistsErrorKey, err)
+				respond(w, http.StatusConflict, brokerapi.ErrorResponse{
+					Description: err.Error(),
+				})
+			case brokerapi.ErrAppGUIDRequired:
+				logger.Error(bindingAppGUIDRequiredErrorKey, err)
+				respond(w, statusUnprocessableEntity, brokerapi.ErrorResponse{
+					Error:       "RequiresApp",
+					Description: err.Error(),
+				})
+			case brokerapi.ErrInstanceNotBindable:
+				logger.Error(instanceNotBindableErrorKey, err)
+				respond(w, http.StatusInternalServerError, brokerapi.ErrorResponse{
+					Description: err.Error(),
+				})
+			default:
+				logger.Error(unknownErrorKey, err)
+				respond(w, http.StatusInternalServerError, brokerapi.ErrorResponse{
+					Description: err.Error(),
+				})
+			}
+			return
+		}
+
+		respond(w, http.StatusCreated, bindingResponse)
+	}
+}
+
+func unbind(serviceBroker brokerapi.ServiceBroker, router httpRouter, logger lager.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := router.Vars(req)
+		instanceID := vars["instance_id"]
+		bindingID := vars["binding_id"]
+
+		logger := logger.Session(unbindLogKey, lager.Data{
+			instanceIDLogKey: instanceID,
+			bindingIDLogKey:  bindingID,
+		})
+
+		details := brokerapi.UnbindDetails{
+			ServiceID: req.FormValue("service_id"),
+			PlanID:    req.FormValue("plan_id"),
+		}
+
+		logger = logger.WithData(lager.Data{
+			unbindDetailsLogKey: details,
+		})
+
+		if err := serviceBroker.Unbind(instanceID, bindingID, details); err != nil {
+			if respondWithFailure(w, logger, err) {
+				return
+			}
+			switch err {
+			case brokerapi.ErrInstanceDoesNotExist:
+				logger.Error(instanceMissingErrorKey, err)
+				respond(w, http.StatusInternalServerError, brokerapi.ErrorResponse{
+					Description: err.Error(),
+				})
+			case brokerapi.ErrBindingDoesNotExist:
+				logger.Error(bindingMissingErrorKey, err)
+				respond(w, http.StatusGone, brokerapi.EmptyResponse{})
+			default:
+				logger.Error(unknownErrorKey, err)
+				respond(w, http.StatusInternalServerError, brokerapi.ErrorResponse{
+					Description: err.Error(),
+				})
+			}
+			return
+		}
+
+		respond(w, http.StatusOK, brokerapi.EmptyResponse{})
+	}
+}
+
+func lastOperation(serviceBroker brokerapi.ServiceBroker, router httpRouter, logger lager.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := router.Vars(req)
+		instanceID := vars["instance_id"]
+
+		logger := logger.Session(lastOperationLogKey, lager.Data{
+			instanceIDLogKey: instanceID,
+		})
+
+		lastOperationResponse, err := serviceBroker.LastOperation(instanceID)
+		if err != nil {
+			if respondWithFailure(w, logger, err) {
+				return
+			}
+			switch err {
+			case brokerapi.ErrInstanceDoesNotExist:
+				logger.Error(instanceMissingErrorKey, err)
+				respond(w, http.StatusGone, brokerapi.EmptyResponse{})
+			default:
+				logger.Error(unknownErrorKey, err)
+				respond(w, http.StatusInternalServerError, brokerapi.ErrorResponse{
+					Description: err.Error(),
+				})
+			}
+			return
+		}
+
+		respond(w, http.StatusOK, lastOperationResponse)
+	}
+}
+
+func fetchInstance(serviceBroker brokerapi.ServiceBroker, router httpRouter, logger lager.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := router.Vars(req)
+		instanceID := vars["instance_id"]
+
+		logger := logger.Session(fetchInstanceLogKey, lager.Data{
+			instanceIDLogKey: instanceID,
+		})
+
+		instanceFetcher, ok := serviceBroker.(InstanceFetcher)
+		if !ok {
+			err := errors.New("Fetching service instances is not supported")
+			logger.Error(fetchNotSupportedErrorKey, err)
+			respond(w, http.StatusNotFound, brokerapi.ErrorResponse{
+				Description: err.Error(),
+			})
+			return
+		}
+
+		fetchInstanceResponse, err := instanceFetcher.FetchInstance(instanceID)
+		if err != nil {
+			if respondWithFailure(w, logger, err) {
+				return
+			}
+			switch err {
+			case brokerapi.ErrInstanceDoesNotExist:
+				logger.Error(instanceMissingErrorKey, err)
+				respond(w, http.StatusNotFound, brokerapi.EmptyResponse{})
+			default:
+				logger.Error(unknownErrorKey, err)
+				respond(w, http.StatusInternalServerError, brokerapi.ErrorResponse{
+					Description: err.Error(),
+				})
+			}
+			return
+		}
+
+		respond(w, http.StatusOK, fetchInstanceResponse)
+	}
+}
+
+func fetchBinding(serviceBroker brokerapi.ServiceBroker, router httpRouter, logger lager.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := router.Vars(req)
+		instanceID := vars["instance_id"]
+		bindingID := vars["binding_id"]
+
+		logger := logger.Session(fetchBindingLogKey, lager.Data{
+			instanceIDLogKey: instanceID,
+			bindingIDLogKey:  bindingID,
+		})
+
+		instanceFetcher, ok := serviceBroker.(InstanceFetcher)
+		if !ok {
+			err := errors.New("Fetching service bindings is not supported")
+			logger.Error(fetchNotSupportedErrorKey, err)
+			respond(w, http.StatusNotFound, brokerapi.ErrorResponse{
+				Description: err.Error(),
+			})
+			return
+		}
+
+		fetchBindingResponse, err := instanceFetcher.FetchBinding(instanceID, bindingID)
+		if err != nil {
+			if respondWithFailure(w, logger, err) {
+				return
+			}
+			switch err {
+			case brokerapi.ErrInstanceDoesNotExist:
+				logger.Error(instanceMissingErrorKey, err)
+				respond(w, http.StatusNotFound, brokerapi.EmptyResponse{})
+			case brokerapi.ErrBindingDoesNotExist:
+				logger.Error(bindingMissingErrorKey, err)
+				respond(w, http.StatusNotFound, brokerapi.EmptyResponse{})
+			default:
+				logger.Error(unknownErrorKey, err)
+				respond(w, http.StatusInternalServerError, brokerapi.ErrorResponse{
+					Description: err.Error(),
+				})
+			}
+			return
+		}
+
+		respond(w, http.StatusOK, fetchBindingResponse)
+	}
+}
+
+func respondWithFailure(w http.ResponseWriter, logger lager.Logger, err error) bool {
+	failure, ok := err.(*FailureResponse)
+	if !ok {
+		return false
+	}
+
+	logger.Error(failure.LoggerAction(), err)
+	respond(w, failure.StatusCode(), failure.ErrorResponse())
+	return true
+}
+
+func respond(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.Encode(response)
+}
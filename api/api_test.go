@@ -0,0 +1,331 @@
+package api_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/frodenas/brokerapi"
+	"github.com/frodenas/brokerapi/fakes"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/cloudfoundry-community/elasticache-broker/api"
+)
+
+type fakeCatalogProvider struct {
+	*fakes.FakeServiceBroker
+	catalog CatalogResponse
+}
+
+func (f *fakeCatalogProvider) Catalog() CatalogResponse {
+	return f.catalog
+}
+
+type fakeInstanceFetcher struct {
+	*fakes.FakeServiceBroker
+
+	fetchInstanceID       string
+	fetchInstanceResponse FetchInstanceResponse
+	fetchInstanceError    error
+
+	fetchBindingID       string
+	fetchBindingResponse FetchBindingResponse
+	fetchBindingError    error
+}
+
+func (f *fakeInstanceFetcher) FetchInstance(instanceID string) (FetchInstanceResponse, error) {
+	f.fetchInstanceID = instanceID
+	return f.fetchInstanceResponse, f.fetchInstanceError
+}
+
+func (f *fakeInstanceFetcher) FetchBinding(instanceID, bindingID string) (FetchBindingResponse, error) {
+	f.fetchInstanceID = instanceID
+	f.fetchBindingID = bindingID
+	return f.fetchBindingResponse, f.fetchBindingError
+}
+
+var _ = Describe("API", func() {
+	var (
+		serviceBroker *fakes.FakeServiceBroker
+		handler       http.Handler
+		recorder      *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		serviceBroker = &fakes.FakeServiceBroker{}
+		credentials := brokerapi.BrokerCredentials{Username: "username", Password: "password"}
+		handler = New(serviceBroker, lagertest.NewTestLogger("api_test"), credentials)
+		recorder = httptest.NewRecorder()
+	})
+
+	makeRequest := func(method, path, body string) {
+		request, err := http.NewRequest(method, path, strings.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+		request.SetBasicAuth("username", "password")
+		handler.ServeHTTP(recorder, request)
+	}
+
+	decodeErrorResponse := func() brokerapi.ErrorResponse {
+		errorResponse := brokerapi.ErrorResponse{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &errorResponse)).To(Succeed())
+		return errorResponse
+	}
+
+	Describe("Catalog", func() {
+		It("returns the brokerapi catalog", func() {
+			makeRequest("GET", "/v2/catalog", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(ContainSubstring(`"name":"p-cassandra"`))
+		})
+
+		It("returns the catalog of a catalog provider", func() {
+			catalogProvider := &fakeCatalogProvider{
+				FakeServiceBroker: serviceBroker,
+				catalog: CatalogResponse{
+					Services: []Service{
+						Service{
+							ID: "service-id",
+							Plans: []ServicePlan{
+								ServicePlan{
+									ID: "plan-id",
+									Schemas: &ServiceSchemas{
+										ServiceInstance: ServiceInstanceSchema{
+											Create: &InputParametersSchema{Parameters: map[string]interface{}{"type": "object"}},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+			handler = New(catalogProvider, lagertest.NewTestLogger("api_test"), brokerapi.BrokerCredentials{Username: "username", Password: "password"})
+
+			makeRequest("GET", "/v2/catalog", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(ContainSubstring(`"schemas":{"service_instance":{"create":{"parameters":{"type":"object"}}}`))
+		})
+	})
+
+	It("rejects requests without the broker credentials", func() {
+		request, err := http.NewRequest("GET", "/v2/catalog", nil)
+		Expect(err).ToNot(HaveOccurred())
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	Describe("Provision", func() {
+		It("returns 202 for asynchronous provisions", func() {
+			serviceBroker.ProvisionAsynch = true
+
+			makeRequest("PUT", "/v2/service_instances/instance-id?accepts_incomplete=true", `{"plan_id": "plan-id"}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusAccepted))
+			Expect(serviceBroker.ProvisionInstanceID).To(Equal("instance-id"))
+			Expect(serviceBroker.ProvisionAcceptsIncomplete).To(BeTrue())
+		})
+
+		It("returns the status code of a failure response", func() {
+			serviceBroker.ProvisionError = NewBadRequestResponse(errors.New("Invalid tag"), "invalid-tags")
+
+			makeRequest("PUT", "/v2/service_instances/instance-id?accepts_incomplete=true", `{}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(decodeErrorResponse().Description).To(Equal("Invalid tag"))
+		})
+
+		It("returns 200 when an identical instance is already provisioned", func() {
+			serviceBroker.ProvisionResponse = brokerapi.ProvisioningResponse{DashboardURL: "https://dashboard"}
+			serviceBroker.ProvisionError = ErrInstanceAlreadyProvisioned
+
+			makeRequest("PUT", "/v2/service_instances/instance-id?accepts_incomplete=true", `{}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(ContainSubstring(`"dashboard_url":"https://dashboard"`))
+		})
+
+		It("keeps the brokerapi error mapping", func() {
+			serviceBroker.ProvisionError = brokerapi.ErrInstanceAlreadyExists
+
+			makeRequest("PUT", "/v2/service_instances/instance-id?accepts_incomplete=true", `{}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusConflict))
+		})
+	})
+
+	Describe("Update", func() {
+		It("returns the status code and error code of a failure response", func() {
+			serviceBroker.UpdateError = NewFailureResponseWithErrorCode(errors.New("in progress"), 422, "concurrency-error", "ConcurrencyError")
+
+			makeRequest("PATCH", "/v2/service_instances/instance-id?accepts_incomplete=true", `{}`)
+
+			Expect(recorder.Code).To(Equal(422))
+			Expect(decodeErrorResponse().Error).To(Equal("ConcurrencyError"))
+		})
+
+		It("returns 404 when the instance does not exist", func() {
+			serviceBroker.UpdateError = brokerapi.ErrInstanceDoesNotExist
+
+			makeRequest("PATCH", "/v2/service_instances/instance-id?accepts_incomplete=true", `{}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			Expect(decodeErrorResponse().Description).To(Equal(brokerapi.ErrInstanceDoesNotExist.Error()))
+		})
+	})
+
+	Describe("Deprovision", func() {
+		It("returns 410 when the instance does not exist", func() {
+			serviceBroker.DeprovisionError = brokerapi.ErrInstanceDoesNotExist
+
+			makeRequest("DELETE", "/v2/service_instances/instance-id?accepts_incomplete=true&service_id=s&plan_id=p", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusGone))
+			Expect(serviceBroker.DeprovisionDetails.ServiceID).To(Equal("s"))
+		})
+
+		It("returns 422 with the ConcurrencyError error code when another operation is in progress", func() {
+			serviceBroker.DeprovisionError = NewConcurrencyErrorResponse(errors.New("in progress"), "concurrency-error")
+
+			makeRequest("DELETE", "/v2/service_instances/instance-id?accepts_incomplete=true&service_id=s&plan_id=p", "")
+
+			Expect(recorder.Code).To(Equal(422))
+			Expect(decodeErrorResponse().Error).To(Equal("ConcurrencyError"))
+			Expect(decodeErrorResponse().Description).To(Equal("in progress"))
+		})
+	})
+
+	Describe("Bind", func() {
+		It("returns the status code of a failure response", func() {
+			serviceBroker.BindError = NewBadRequestResponse(errors.New("Invalid parameter"), "invalid-parameters")
+
+			makeRequest("PUT", "/v2/service_instances/instance-id/service_bindings/binding-id", `{}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("returns 200 and the credentials when an identical binding already exists", func() {
+			serviceBroker.BindResponse = brokerapi.BindingResponse{Credentials: map[string]interface{}{"host": "cache-host"}}
+			serviceBroker.BindError = ErrBindingAlreadyBound
+
+			makeRequest("PUT", "/v2/service_instances/instance-id/service_bindings/binding-id", `{}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(ContainSubstring(`"host":"cache-host"`))
+		})
+
+		It("returns 409 when a different binding already exists", func() {
+			serviceBroker.BindError = brokerapi.ErrBindingAlreadyExists
+
+			makeRequest("PUT", "/v2/service_instances/instance-id/service_bindings/binding-id", `{}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusConflict))
+		})
+
+		It("returns 404 when the instance does not exist", func() {
+			serviceBroker.BindError = brokerapi.ErrInstanceDoesNotExist
+
+			makeRequest("PUT", "/v2/service_instances/instance-id/service_bindings/binding-id", `{}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			Expect(decodeErrorResponse().Description).To(Equal(brokerapi.ErrInstanceDoesNotExist.Error()))
+		})
+	})
+
+	Describe("Unbind", func() {
+		It("returns 410 when the binding does not exist", func() {
+			serviceBroker.UnbindError = brokerapi.ErrBindingDoesNotExist
+
+			makeRequest("DELETE", "/v2/service_instances/instance-id/service_bindings/binding-id?service_id=s&plan_id=p", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusGone))
+		})
+	})
+
+	Describe("Fetch", func() {
+		var instanceFetcher *fakeInstanceFetcher
+
+		BeforeEach(func() {
+			instanceFetcher = &fakeInstanceFetcher{FakeServiceBroker: serviceBroker}
+			handler = New(instanceFetcher, lagertest.NewTestLogger("api_test"), brokerapi.BrokerCredentials{Username: "username", Password: "password"})
+		})
+
+		It("returns the service instance", func() {
+			instanceFetcher.fetchInstanceResponse = FetchInstanceResponse{
+				ServiceID:  "service-id",
+				PlanID:     "plan-id",
+				Parameters: map[string]interface{}{"engine_version": "2.8.24"},
+			}
+
+			makeRequest("GET", "/v2/service_instances/instance-id", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(instanceFetcher.fetchInstanceID).To(Equal("instance-id"))
+			Expect(recorder.Body.String()).To(ContainSubstring(`"plan_id":"plan-id"`))
+			Expect(recorder.Body.String()).To(ContainSubstring(`"parameters":{"engine_version":"2.8.24"}`))
+		})
+
+		It("returns 404 when the service instance does not exist", func() {
+			instanceFetcher.fetchInstanceError = brokerapi.ErrInstanceDoesNotExist
+
+			makeRequest("GET", "/v2/service_instances/instance-id", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("returns the status code of a failure response", func() {
+			instanceFetcher.fetchInstanceError = NewConcurrencyErrorResponse(errors.New("in progress"), "concurrency-error")
+
+			makeRequest("GET", "/v2/service_instances/instance-id", "")
+
+			Expect(recorder.Code).To(Equal(422))
+			Expect(decodeErrorResponse().Error).To(Equal("ConcurrencyError"))
+		})
+
+		It("returns the service binding", func() {
+			instanceFetcher.fetchBindingResponse = FetchBindingResponse{Credentials: map[string]interface{}{"host": "cache-host"}}
+
+			makeRequest("GET", "/v2/service_instances/instance-id/service_bindings/binding-id", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(instanceFetcher.fetchInstanceID).To(Equal("instance-id"))
+			Expect(instanceFetcher.fetchBindingID).To(Equal("binding-id"))
+			Expect(recorder.Body.String()).To(ContainSubstring(`"credentials":{"host":"cache-host"}`))
+		})
+
+		It("returns 404 when the service binding does not exist", func() {
+			instanceFetcher.fetchBindingError = brokerapi.ErrBindingDoesNotExist
+
+			makeRequest("GET", "/v2/service_instances/instance-id/service_bindings/binding-id", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("returns 404 when the service broker cannot fetch service instances", func() {
+			handler = New(serviceBroker, lagertest.NewTestLogger("api_test"), brokerapi.BrokerCredentials{Username: "username", Password: "password"})
+
+			makeRequest("GET", "/v2/service_instances/instance-id", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			Expect(decodeErrorResponse().Description).To(Equal("Fetching service instances is not supported"))
+		})
+	})
+
+	Describe("LastOperation", func() {
+		It("returns the last operation state", func() {
+			serviceBroker.LastOperationResponse = brokerapi.LastOperationResponse{State: brokerapi.LastOperationSucceeded}
+
+			makeRequest("GET", "/v2/service_instances/instance-id/last_operation", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(ContainSubstring(`"state":"succeeded"`))
+		})
+	})
+})
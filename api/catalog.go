@@ -0,0 +1,60 @@
+package api
+
+import (
+	"github.com/frodenas/brokerapi"
+)
+
+// CatalogProvider is implemented by service brokers that publish catalog fields
+// brokerapi.CatalogResponse does not know about, such as plan schemas.
+type CatalogProvider interface {
+	Catalog() CatalogResponse
+}
+
+type CatalogResponse struct {
+	Services []Service `json:"services"`
+}
+
+type Service struct {
+	ID              string                     `json:"id"`
+	Name            string                     `json:"name"`
+	Description     string                     `json:"description"`
+	Bindable        bool                       `json:"bindable"`
+	Tags            []string                   `json:"tags,omitempty"`
+	Metadata        *brokerapi.ServiceMetadata `json:"metadata,omitempty"`
+	Requires        []string                   `json:"requires,omitempty"`
+	PlanUpdateable  bool                       `json:"plan_updateable"`
+	Plans           []ServicePlan              `json:"plans"`
+	DashboardClient *brokerapi.DashboardClient `json:"dashboard_client,omitempty"`
+
+	InstancesRetrievable bool `json:"instances_retrievable,omitempty"`
+	BindingsRetrievable  bool `json:"bindings_retrievable,omitempty"`
+}
+
+type ServicePlan struct {
+	ID             string                         `json:"id"`
+	Name           string                         `json:"name"`
+	Description    string                         `json:"description"`
+	Metadata       *brokerapi.ServicePlanMetadata `json:"metadata,omitempty"`
+	Free           bool                           `json:"free"`
+	Bindable       *bool                          `json:"bindable,omitempty"`
+	PlanUpdateable *bool                          `json:"plan_updateable,omitempty"`
+	Schemas        *ServiceSchemas                `json:"schemas,omitempty"`
+}
+
+type ServiceSchemas struct {
+	ServiceInstance ServiceInstanceSchema `json:"service_instance"`
+	ServiceBinding  ServiceBindingSchema  `json:"service_binding"`
+}
+
+type ServiceInstanceSchema struct {
+	Create *InputParametersSchema `json:"create,omitempty"`
+	Update *InputParametersSchema `json:"update,omitempty"`
+}
+
+type ServiceBindingSchema struct {
+	Create *InputParametersSchema `json:"create,omitempty"`
+}
+
+type InputParametersSchema struct {
+	Parameters map[string]interface{} `json:"parameters"`
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/frodenas/brokerapi"
+)
+
+// FailureResponse is an error that carries the HTTP status code and OSBAPI error code
+// that must be returned to the platform, for errors brokerapi does not know about.
+type FailureResponse struct {
+	error
+	statusCode   int
+	loggerAction string
+	errorCode    string
+}
+
+func NewFailureResponse(err error, statusCode int, loggerAction string) *FailureResponse {
+	return &FailureResponse{
+		error:        err,
+		statusCode:   statusCode,
+		loggerAction: loggerAction,
+	}
+}
+
+func NewFailureResponseWithErrorCode(err error, statusCode int, loggerAction string, errorCode string) *FailureResponse {
+	return &FailureResponse{
+		error:        err,
+		statusCode:   statusCode,
+		loggerAction: loggerAction,
+		errorCode:    errorCode,
+	}
+}
+
+// NewBadRequestResponse returns a 400 failure response, used when request parameters violate policy.
+func NewBadRequestResponse(err error, loggerAction string) *FailureResponse {
+	return NewFailureResponse(err, http.StatusBadRequest, loggerAction)
+}
+
+// NewUnprocessableEntityResponse returns a 422 failure response, used when a request is valid
+// but cannot be fulfilled given the current state of the service instance.
+func NewUnprocessableEntityResponse(err error, loggerAction string) *FailureResponse {
+	return NewFailureResponse(err, statusUnprocessableEntity, loggerAction)
+}
+
+// NewConcurrencyErrorResponse returns a 422 failure response with the ConcurrencyError error
+// code, used when another operation is in progress on the service instance.
+func NewConcurrencyErrorResponse(err error, loggerAction string) *FailureResponse {
+	return NewFailureResponseWithErrorCode(err, statusUnprocessableEntity, loggerAction, "ConcurrencyError")
+}
+
+func (f *FailureResponse) StatusCode() int {
+	return f.statusCode
+}
+
+func (f *FailureResponse) LoggerAction() string {
+	return f.loggerAction
+}
+
+func (f *FailureResponse) ErrorResponse() brokerapi.ErrorResponse {
+	return brokerapi.ErrorResponse{
+		Error:       f.errorCode,
+		Description: f.Error(),
+	}
+}
@@ -0,0 +1,21 @@
+package api
+
+// InstanceFetcher is implemented by service brokers that can return their service instances
+// and service bindings, which brokerapi.ServiceBroker has no methods for.
+type InstanceFetcher interface {
+	FetchInstance(instanceID string) (FetchInstanceResponse, error)
+	FetchBinding(instanceID, bindingID string) (FetchBindingResponse, error)
+}
+
+// FetchInstanceResponse has no dashboard_url: the broker provides no service dashboard.
+type FetchInstanceResponse struct {
+	ServiceID  string                 `json:"service_id"`
+	PlanID     string                 `json:"plan_id"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+type FetchBindingResponse struct {
+	Credentials    interface{}            `json:"credentials"`
+	SyslogDrainURL string                 `json:"syslog_drain_url,omitempty"`
+	Parameters     map[string]interface{} `json:"parameters,omitempty"`
+}
@@ -0,0 +1,13 @@
+package awscloudwatch_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestAWSCloudWatch(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "AWS CloudWatch Suite")
+}
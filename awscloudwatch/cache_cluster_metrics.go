@@ -0,0 +1,5 @@
+package awscloudwatch
+
+type CacheClusterMetrics interface {
+	MemoryUsage(cacheClusterID string, engine string, cacheNodeIDs []string) (int64, error)
+}
@@ -0,0 +1,79 @@
+package awscloudwatch
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/pivotal-golang/lager"
+)
+
+const elastiCacheNamespace = "AWS/ElastiCache"
+
+// memoryUsagePeriod is how far back MemoryUsage looks for the peak memory usage.
+const memoryUsagePeriod = time.Hour
+
+type CloudWatchCacheClusterMetrics struct {
+	cloudwatchsvc *CloudWatchAPI
+	logger        lager.Logger
+}
+
+func NewCloudWatchCacheClusterMetrics(
+	cloudwatchsvc *CloudWatchAPI,
+	logger lager.Logger,
+) *CloudWatchCacheClusterMetrics {
+	return &CloudWatchCacheClusterMetrics{
+		cloudwatchsvc: cloudwatchsvc,
+		logger:        logger.Session("cloudwatch-cache-cluster-metrics"),
+	}
+}
+
+// MemoryUsage returns the sum of the peak number of bytes used for cache by each cache node
+// during the last hour.
+func (r *CloudWatchCacheClusterMetrics) MemoryUsage(cacheClusterID string, engine string, cacheNodeIDs []string) (int64, error) {
+	metricName := "BytesUsedForCache"
+	if engine == "memcached" {
+		metricName = "BytesUsedForCacheItems"
+	}
+
+	endTime := time.Now()
+	startTime := endTime.Add(-memoryUsagePeriod)
+
+	var memoryUsage int64
+	for _, cacheNodeID := range cacheNodeIDs {
+		input := &getMetricStatisticsInput{
+			Namespace:  aws.String(elastiCacheNamespace),
+			MetricName: aws.String(metricName),
+			Dimensions: []*dimension{
+				&dimension{Name: aws.String("CacheClusterId"), Value: aws.String(cacheClusterID)},
+				&dimension{Name: aws.String("CacheNodeId"), Value: aws.String(cacheNodeID)},
+			},
+			StartTime:  aws.Time(startTime),
+			EndTime:    aws.Time(endTime),
+			Period:     aws.Int64(int64(memoryUsagePeriod / time.Second)),
+			Statistics: []*string{aws.String("Maximum")},
+		}
+
+		r.logger.Debug("get-metric-statistics", lager.Data{"input": input})
+		output, err := r.cloudwatchsvc.getMetricStatistics(input)
+		if err != nil {
+			r.logger.Error("aws-cloudwatch-error", err)
+			if awsErr, ok := err.(awserr.Error); ok {
+				return 0, errors.New(awsErr.Code() + ": " + awsErr.Message())
+			}
+			return 0, err
+		}
+		r.logger.Debug("get-metric-statistics", lager.Data{"output": output})
+
+		var maximum float64
+		for _, datapoint := range output.Datapoints {
+			if value := aws.Float64Value(datapoint.Maximum); value > maximum {
+				maximum = value
+			}
+		}
+		memoryUsage += int64(maximum)
+	}
+
+	return memoryUsage, nil
+}
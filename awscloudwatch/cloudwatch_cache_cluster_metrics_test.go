@@ -0,0 +1,135 @@
+package awscloudwatch_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/cloudfoundry-community/elasticache-broker/awscloudwatch"
+)
+
+var _ = Describe("CloudWatchCacheClusterMetrics", func() {
+	var (
+		server        *httptest.Server
+		requests      []url.Values
+		responses     []string
+		responseCodes []int
+
+		cacheClusterMetrics *CloudWatchCacheClusterMetrics
+	)
+
+	BeforeEach(func() {
+		requests = []url.Values{}
+		responses = []string{}
+		responseCodes = []int{}
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body, _ := ioutil.ReadAll(req.Body)
+			values, _ := url.ParseQuery(string(body))
+			requests = append(requests, values)
+
+			i := len(requests) - 1
+			if i < len(responseCodes) {
+				w.WriteHeader(responseCodes[i])
+			}
+			if i < len(responses) {
+				w.Write([]byte(responses[i]))
+			}
+		}))
+
+		awsConfig := aws.NewConfig().
+			WithRegion("us-east-1").
+			WithEndpoint(server.URL).
+			WithDisableSSL(true).
+			WithMaxRetries(0).
+			WithCredentials(credentials.NewStaticCredentials("access-key-id", "secret-access-key", ""))
+		cloudwatchsvc := NewCloudWatchAPI(session.New(awsConfig))
+		cacheClusterMetrics = NewCloudWatchCacheClusterMetrics(cloudwatchsvc, lagertest.NewTestLogger("cloudwatch_cache_cluster_metrics_test"))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("MemoryUsage", func() {
+		It("sums the peak memory usage of each cache node", func() {
+			responses = []string{
+				`<GetMetricStatisticsResponse><GetMetricStatisticsResult><Label>BytesUsedForCache</Label><Datapoints>` +
+					`<member><Timestamp>2016-01-01T00:00:00Z</Timestamp><Maximum>1000.0</Maximum><Unit>Bytes</Unit></member>` +
+					`<member><Timestamp>2016-01-01T00:05:00Z</Timestamp><Maximum>3000.0</Maximum><Unit>Bytes</Unit></member>` +
+					`</Datapoints></GetMetricStatisticsResult></GetMetricStatisticsResponse>`,
+				`<GetMetricStatisticsResponse><GetMetricStatisticsResult><Label>BytesUsedForCache</Label><Datapoints>` +
+					`<member><Timestamp>2016-01-01T00:00:00Z</Timestamp><Maximum>500.0</Maximum><Unit>Bytes</Unit></member>` +
+					`</Datapoints></GetMetricStatisticsResult></GetMetricStatisticsResponse>`,
+			}
+
+			memoryUsage, err := cacheClusterMetrics.MemoryUsage("cf-cluster", "redis", []string{"0001", "0002"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(memoryUsage).To(Equal(int64(3500)))
+
+			Expect(requests).To(HaveLen(2))
+			Expect(requests[0].Get("Action")).To(Equal("GetMetricStatistics"))
+			Expect(requests[0].Get("Namespace")).To(Equal("AWS/ElastiCache"))
+			Expect(requests[0].Get("MetricName")).To(Equal("BytesUsedForCache"))
+			Expect(requests[0].Get("Dimensions.member.1.Name")).To(Equal("CacheClusterId"))
+			Expect(requests[0].Get("Dimensions.member.1.Value")).To(Equal("cf-cluster"))
+			Expect(requests[0].Get("Dimensions.member.2.Name")).To(Equal("CacheNodeId"))
+			Expect(requests[0].Get("Dimensions.member.2.Value")).To(Equal("0001"))
+			Expect(requests[0].Get("Statistics.member.1")).To(Equal("Maximum"))
+			Expect(requests[0].Get("StartTime")).ToNot(BeEmpty())
+			Expect(requests[1].Get("Dimensions.member.2.Value")).To(Equal("0002"))
+		})
+
+		It("uses the memcached metric for memcached clusters", func() {
+			responses = []string{
+				`<GetMetricStatisticsResponse><GetMetricStatisticsResult><Datapoints></Datapoints></GetMetricStatisticsResult></GetMetricStatisticsResponse>`,
+			}
+
+			memoryUsage, err := cacheClusterMetrics.MemoryUsage("cf-cluster", "memcached", []string{"0001"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(memoryUsage).To(Equal(int64(0)))
+			Expect(requests[0].Get("MetricName")).To(Equal("BytesUsedForCacheItems"))
+		})
+
+		It("returns the error if the request fails", func() {
+			responseCodes = []int{400}
+			responses = []string{
+				`<ErrorResponse><Error><Type>Sender</Type><Code>InvalidParameterValue</Code><Message>Invalid dimension</Message></Error><RequestId>1</RequestId></ErrorResponse>`,
+			}
+
+			_, err := cacheClusterMetrics.MemoryUsage("cf-cluster", "redis", []string{"0001"})
+			Expect(err).To(MatchError("InvalidParameterValue: Invalid dimension"))
+		})
+
+		It("retries throttled requests", func() {
+			awsConfig := aws.NewConfig().
+				WithRegion("us-east-1").
+				WithEndpoint(server.URL).
+				WithDisableSSL(true).
+				WithMaxRetries(1).
+				WithCredentials(credentials.NewStaticCredentials("access-key-id", "secret-access-key", ""))
+			cacheClusterMetrics = NewCloudWatchCacheClusterMetrics(NewCloudWatchAPI(session.New(awsConfig)), lagertest.NewTestLogger("cloudwatch_cache_cluster_metrics_test"))
+			responseCodes = []int{400, 200}
+			responses = []string{
+				`<ErrorResponse><Error><Type>Sender</Type><Code>Throttling</Code><Message>Rate exceeded</Message></Error><RequestId>1</RequestId></ErrorResponse>`,
+				`<GetMetricStatisticsResponse><GetMetricStatisticsResult><Datapoints>` +
+					`<member><Timestamp>2016-01-01T00:00:00Z</Timestamp><Maximum>1000.0</Maximum><Unit>Bytes</Unit></member>` +
+					`</Datapoints></GetMetricStatisticsResult></GetMetricStatisticsResponse>`,
+			}
+
+			memoryUsage, err := cacheClusterMetrics.MemoryUsage("cf-cluster", "redis", []string{"0001"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(memoryUsage).To(Equal(int64(1000)))
+			Expect(requests).To(HaveLen(2))
+		})
+	})
+})
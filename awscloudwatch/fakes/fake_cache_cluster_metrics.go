@@ -0,0 +1,19 @@
+package fakes
+
+type FakeCacheClusterMetrics struct {
+	MemoryUsageCalled         bool
+	MemoryUsageCacheClusterID string
+	MemoryUsageEngine         string
+	MemoryUsageCacheNodeIDs   []string
+	MemoryUsageBytes          int64
+	MemoryUsageError          error
+}
+
+func (f *FakeCacheClusterMetrics) MemoryUsage(cacheClusterID string, engine string, cacheNodeIDs []string) (int64, error) {
+	f.MemoryUsageCalled = true
+	f.MemoryUsageCacheClusterID = cacheClusterID
+	f.MemoryUsageEngine = engine
+	f.MemoryUsageCacheNodeIDs = cacheNodeIDs
+
+	return f.MemoryUsageBytes, f.MemoryUsageError
+}
@@ -0,0 +1,13 @@
+package awsec2_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestAWSEC2(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "AWS EC2 Suite")
+}
@@ -0,0 +1,191 @@
+package awsec2
+
+import (
+	"encoding/xml"
+	"io"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/private/protocol/query/queryutil"
+	"github.com/aws/aws-sdk-go/private/protocol/xml/xmlutil"
+	"github.com/aws/aws-sdk-go/private/signer/v4"
+)
+
+// EC2API is a minimal Amazon EC2 client covering only the operations used by the broker.
+// It relies on the same request machinery as the vendored aws-sdk-go service clients, so
+// requests are signed and throttled or failed requests retried the same way, using the EC2
+// flavour of the query protocol. It is meant to be replaced by the aws-sdk-go service/ec2
+// package once that is vendored at the pinned SDK version.
+type EC2API struct {
+	*client.Client
+}
+
+const ec2ServiceName = "ec2"
+const ec2APIVersion = "2015-10-01"
+
+func NewEC2API(p client.ConfigProvider, cfgs ...*aws.Config) *EC2API {
+	c := p.ClientConfig(ec2ServiceName, cfgs...)
+
+	svc := &EC2API{
+		Client: client.New(
+			*c.Config,
+			metadata.ClientInfo{
+				ServiceName:   ec2ServiceName,
+				SigningRegion: c.SigningRegion,
+				Endpoint:      c.Endpoint,
+				APIVersion:    ec2APIVersion,
+			},
+			c.Handlers,
+		),
+	}
+
+	svc.Handlers.Sign.PushBack(v4.Sign)
+	svc.Handlers.Build.PushBack(buildEC2Request)
+	svc.Handlers.Unmarshal.PushBack(unmarshalEC2Response)
+	svc.Handlers.UnmarshalError.PushBack(unmarshalEC2Error)
+
+	return svc
+}
+
+type createSecurityGroupInput struct {
+	GroupName        *string `locationName:"GroupName" type:"string"`
+	GroupDescription *string `locationName:"GroupDescription" type:"string"`
+	VpcId            *string `locationName:"VpcId" type:"string"`
+}
+
+type createSecurityGroupOutput struct {
+	GroupId *string `locationName:"groupId" type:"string"`
+}
+
+func (c *EC2API) createSecurityGroup(input *createSecurityGroupInput) (*createSecurityGroupOutput, error) {
+	output := &createSecurityGroupOutput{}
+	err := c.send("CreateSecurityGroup", input, output)
+	return output, err
+}
+
+type authorizeSecurityGroupIngressInput struct {
+	GroupId       *string         `locationName:"GroupId" type:"string"`
+	IpPermissions []*ipPermission `locationName:"IpPermissions" locationNameList:"item" type:"list"`
+}
+
+type ipPermission struct {
+	IpProtocol *string    `locationName:"ipProtocol" type:"string"`
+	FromPort   *int64     `locationName:"fromPort" type:"integer"`
+	ToPort     *int64     `locationName:"toPort" type:"integer"`
+	IpRanges   []*ipRange `locationName:"ipRanges" locationNameList:"item" type:"list"`
+}
+
+type ipRange struct {
+	CidrIp *string `locationName:"cidrIp" type:"string"`
+}
+
+type authorizeSecurityGroupIngressOutput struct{}
+
+func (c *EC2API) authorizeSecurityGroupIngress(input *authorizeSecurityGroupIngressInput) (*authorizeSecurityGroupIngressOutput, error) {
+	output := &authorizeSecurityGroupIngressOutput{}
+	err := c.send("AuthorizeSecurityGroupIngress", input, output)
+	return output, err
+}
+
+type deleteSecurityGroupInput struct {
+	GroupId *string `locationName:"GroupId" type:"string"`
+}
+
+type deleteSecurityGroupOutput struct{}
+
+func (c *EC2API) deleteSecurityGroup(input *deleteSecurityGroupInput) (*deleteSecurityGroupOutput, error) {
+	output := &deleteSecurityGroupOutput{}
+	err := c.send("DeleteSecurityGroup", input, output)
+	return output, err
+}
+
+type describeSecurityGroupsInput struct {
+	Filters  []*filter `locationName:"Filter" locationNameList:"Filter" type:"list"`
+	GroupIds []*string `locationName:"GroupId" locationNameList:"groupId" type:"list"`
+}
+
+type filter struct {
+	Name   *string   `type:"string"`
+	Values []*string `locationName:"Value" locationNameList:"item" type:"list"`
+}
+
+type describeSecurityGroupsOutput struct {
+	SecurityGroups []*securityGroup `locationName:"securityGroupInfo" locationNameList:"item" type:"list"`
+}
+
+type securityGroup struct {
+	GroupId       *string         `locationName:"groupId" type:"string"`
+	GroupName     *string         `locationName:"groupName" type:"string"`
+	Description   *string         `locationName:"groupDescription" type:"string"`
+	VpcId         *string         `locationName:"vpcId" type:"string"`
+	IpPermissions []*ipPermission `locationName:"ipPermissions" locationNameList:"item" type:"list"`
+}
+
+func (c *EC2API) describeSecurityGroups(input *describeSecurityGroupsInput) (*describeSecurityGroupsOutput, error) {
+	output := &describeSecurityGroupsOutput{}
+	err := c.send("DescribeSecurityGroups", input, output)
+	return output, err
+}
+
+func (c *EC2API) send(operationName string, input, output interface{}) error {
+	op := &request.Operation{
+		Name:       operationName,
+		HTTPMethod: "POST",
+		HTTPPath:   "/",
+	}
+
+	return c.NewRequest(op, input, output).Send()
+}
+
+func buildEC2Request(r *request.Request) {
+	body := url.Values{
+		"Action":  {r.Operation.Name},
+		"Version": {r.ClientInfo.APIVersion},
+	}
+	if err := queryutil.Parse(body, r.Params, true); err != nil {
+		r.Error = awserr.New("SerializationError", "failed encoding EC2 Query request", err)
+		return
+	}
+
+	r.HTTPRequest.Method = "POST"
+	r.HTTPRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	r.SetBufferBody([]byte(body.Encode()))
+}
+
+func unmarshalEC2Response(r *request.Request) {
+	defer r.HTTPResponse.Body.Close()
+	if r.DataFilled() {
+		decoder := xml.NewDecoder(r.HTTPResponse.Body)
+		if err := xmlutil.UnmarshalXML(r.Data, decoder, ""); err != nil {
+			r.Error = awserr.New("SerializationError", "failed decoding EC2 Query response", err)
+		}
+	}
+}
+
+type ec2ErrorResponse struct {
+	XMLName   xml.Name `xml:"Response"`
+	Code      string   `xml:"Errors>Error>Code"`
+	Message   string   `xml:"Errors>Error>Message"`
+	RequestID string   `xml:"RequestID"`
+}
+
+func unmarshalEC2Error(r *request.Request) {
+	defer r.HTTPResponse.Body.Close()
+
+	resp := &ec2ErrorResponse{}
+	err := xml.NewDecoder(r.HTTPResponse.Body).Decode(resp)
+	if err != nil && err != io.EOF {
+		r.Error = awserr.New("SerializationError", "failed decoding EC2 Query error response", err)
+		return
+	}
+
+	r.Error = awserr.NewRequestFailure(
+		awserr.New(resp.Code, resp.Message, nil),
+		r.HTTPResponse.StatusCode,
+		resp.RequestID,
+	)
+}
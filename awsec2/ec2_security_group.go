@@ -0,0 +1,174 @@
+package awsec2
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/pivotal-golang/lager"
+)
+
+type EC2SecurityGroup struct {
+	ec2svc *EC2API
+	logger lager.Logger
+}
+
+func NewEC2SecurityGroup(
+	ec2svc *EC2API,
+	logger lager.Logger,
+) *EC2SecurityGroup {
+	return &EC2SecurityGroup{
+		ec2svc: ec2svc,
+		logger: logger.Session("ec2-security-group"),
+	}
+}
+
+func (r *EC2SecurityGroup) Describe(ID string) (SecurityGroupDetails, error) {
+	input := &describeSecurityGroupsInput{
+		GroupIds: []*string{aws.String(ID)},
+	}
+
+	return r.describe(input)
+}
+
+func (r *EC2SecurityGroup) FindByName(name string) (SecurityGroupDetails, error) {
+	input := &describeSecurityGroupsInput{
+		Filters: []*filter{
+			&filter{
+				Name:   aws.String("group-name"),
+				Values: []*string{aws.String(name)},
+			},
+		},
+	}
+
+	return r.describe(input)
+}
+
+func (r *EC2SecurityGroup) Create(name string, securityGroupDetails SecurityGroupDetails) (string, error) {
+	createInput := &createSecurityGroupInput{
+		GroupName:        aws.String(name),
+		GroupDescription: aws.String(securityGroupDetails.Description),
+	}
+	if securityGroupDetails.VpcID != "" {
+		createInput.VpcId = aws.String(securityGroupDetails.VpcID)
+	}
+	r.logger.Debug("create-security-group", lager.Data{"input": createInput})
+
+	createOutput, err := r.ec2svc.createSecurityGroup(createInput)
+	if err != nil {
+		r.logger.Error("aws-ec2-error", err)
+		return "", r.translateError(err)
+	}
+	r.logger.Debug("create-security-group", lager.Data{"output": createOutput})
+
+	ID := aws.StringValue(createOutput.GroupId)
+	if len(securityGroupDetails.IngressRules) == 0 {
+		return ID, nil
+	}
+
+	authorizeInput := &authorizeSecurityGroupIngressInput{
+		GroupId:       aws.String(ID),
+		IpPermissions: buildIPPermissions(securityGroupDetails.IngressRules),
+	}
+	r.logger.Debug("authorize-security-group-ingress", lager.Data{"input": authorizeInput})
+
+	authorizeOutput, err := r.ec2svc.authorizeSecurityGroupIngress(authorizeInput)
+	if err != nil {
+		r.logger.Error("aws-ec2-error", err)
+		if deleteErr := r.Delete(ID); deleteErr != nil {
+			r.logger.Error("rollback-security-group", deleteErr)
+		}
+		return "", r.translateError(err)
+	}
+	r.logger.Debug("authorize-security-group-ingress", lager.Data{"output": authorizeOutput})
+
+	return ID, nil
+}
+
+func (r *EC2SecurityGroup) Delete(ID string) error {
+	input := &deleteSecurityGroupInput{
+		GroupId: aws.String(ID),
+	}
+	r.logger.Debug("delete-security-group", lager.Data{"input": input})
+
+	output, err := r.ec2svc.deleteSecurityGroup(input)
+	if err != nil {
+		r.logger.Error("aws-ec2-error", err)
+		return r.translateError(err)
+	}
+	r.logger.Debug("delete-security-group", lager.Data{"output": output})
+
+	return nil
+}
+
+func (r *EC2SecurityGroup) describe(input *describeSecurityGroupsInput) (SecurityGroupDetails, error) {
+	securityGroupDetails := SecurityGroupDetails{}
+
+	r.logger.Debug("describe-security-groups", lager.Data{"input": input})
+	output, err := r.ec2svc.describeSecurityGroups(input)
+	if err != nil {
+		r.logger.Error("aws-ec2-error", err)
+		return securityGroupDetails, r.translateError(err)
+	}
+	r.logger.Debug("describe-security-groups", lager.Data{"output": output})
+
+	if len(output.SecurityGroups) == 0 {
+		return securityGroupDetails, ErrSecurityGroupDoesNotExist
+	}
+
+	return buildSecurityGroup(output.SecurityGroups[0]), nil
+}
+
+func (r *EC2SecurityGroup) translateError(err error) error {
+	if awsErr, ok := err.(awserr.Error); ok {
+		switch awsErr.Code() {
+		case "InvalidGroup.NotFound", "InvalidGroupId.NotFound", "InvalidGroupId.Malformed":
+			return ErrSecurityGroupDoesNotExist
+		case "DependencyViolation":
+			return ErrSecurityGroupInUse
+		}
+		return errors.New(awsErr.Code() + ": " + awsErr.Message())
+	}
+	return err
+}
+
+func buildIPPermissions(ingressRules []IngressRule) []*ipPermission {
+	var ipPermissions []*ipPermission
+
+	for _, ingressRule := range ingressRules {
+		permission := &ipPermission{
+			IpProtocol: aws.String(ingressRule.Protocol),
+			FromPort:   aws.Int64(ingressRule.FromPort),
+			ToPort:     aws.Int64(ingressRule.ToPort),
+		}
+		for _, cidr := range ingressRule.CIDRs {
+			permission.IpRanges = append(permission.IpRanges, &ipRange{CidrIp: aws.String(cidr)})
+		}
+		ipPermissions = append(ipPermissions, permission)
+	}
+
+	return ipPermissions
+}
+
+func buildSecurityGroup(securityGroup *securityGroup) SecurityGroupDetails {
+	securityGroupDetails := SecurityGroupDetails{
+		ID:          aws.StringValue(securityGroup.GroupId),
+		Name:        aws.StringValue(securityGroup.GroupName),
+		Description: aws.StringValue(securityGroup.Description),
+		VpcID:       aws.StringValue(securityGroup.VpcId),
+	}
+
+	for _, permission := range securityGroup.IpPermissions {
+		ingressRule := IngressRule{
+			Protocol: aws.StringValue(permission.IpProtocol),
+			FromPort: aws.Int64Value(permission.FromPort),
+			ToPort:   aws.Int64Value(permission.ToPort),
+		}
+		for _, ipRange := range permission.IpRanges {
+			ingressRule.CIDRs = append(ingressRule.CIDRs, aws.StringValue(ipRange.CidrIp))
+		}
+		securityGroupDetails.IngressRules = append(securityGroupDetails.IngressRules, ingressRule)
+	}
+
+	return securityGroupDetails
+}
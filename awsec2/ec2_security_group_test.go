@@ -0,0 +1,172 @@
+package awsec2_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/cloudfoundry-community/elasticache-broker/awsec2"
+)
+
+var _ = Describe("EC2SecurityGroup", func() {
+	var (
+		server        *httptest.Server
+		requests      []url.Values
+		responses     []string
+		responseCodes []int
+
+		securityGroup *EC2SecurityGroup
+	)
+
+	BeforeEach(func() {
+		requests = []url.Values{}
+		responses = []string{}
+		responseCodes = []int{}
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body, _ := ioutil.ReadAll(req.Body)
+			values, _ := url.ParseQuery(string(body))
+			requests = append(requests, values)
+
+			i := len(requests) - 1
+			if i < len(responseCodes) {
+				w.WriteHeader(responseCodes[i])
+			}
+			if i < len(responses) {
+				w.Write([]byte(responses[i]))
+			}
+		}))
+
+		awsConfig := aws.NewConfig().
+			WithRegion("us-east-1").
+			WithEndpoint(server.URL).
+			WithDisableSSL(true).
+			WithMaxRetries(0).
+			WithCredentials(credentials.NewStaticCredentials("access-key-id", "secret-access-key", ""))
+		ec2svc := NewEC2API(session.New(awsConfig))
+		securityGroup = NewEC2SecurityGroup(ec2svc, lagertest.NewTestLogger("ec2_security_group_test"))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("Create", func() {
+		It("creates the security group and authorizes the ingress rules", func() {
+			responses = []string{
+				`<CreateSecurityGroupResponse><requestId>1</requestId><return>true</return><groupId>sg-1234</groupId></CreateSecurityGroupResponse>`,
+				`<AuthorizeSecurityGroupIngressResponse><requestId>2</requestId><return>true</return></AuthorizeSecurityGroupIngressResponse>`,
+			}
+
+			ID, err := securityGroup.Create("cf-instance", SecurityGroupDetails{
+				Description: "description",
+				VpcID:       "vpc-1234",
+				IngressRules: []IngressRule{
+					IngressRule{Protocol: "tcp", FromPort: 6379, ToPort: 6379, CIDRs: []string{"10.0.16.0/20", "10.0.32.0/20"}},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ID).To(Equal("sg-1234"))
+
+			Expect(requests).To(HaveLen(2))
+			Expect(requests[0].Get("Action")).To(Equal("CreateSecurityGroup"))
+			Expect(requests[0].Get("GroupName")).To(Equal("cf-instance"))
+			Expect(requests[0].Get("GroupDescription")).To(Equal("description"))
+			Expect(requests[0].Get("VpcId")).To(Equal("vpc-1234"))
+			Expect(requests[1].Get("Action")).To(Equal("AuthorizeSecurityGroupIngress"))
+			Expect(requests[1].Get("GroupId")).To(Equal("sg-1234"))
+			Expect(requests[1].Get("IpPermissions.1.IpProtocol")).To(Equal("tcp"))
+			Expect(requests[1].Get("IpPermissions.1.FromPort")).To(Equal("6379"))
+			Expect(requests[1].Get("IpPermissions.1.ToPort")).To(Equal("6379"))
+			Expect(requests[1].Get("IpPermissions.1.IpRanges.1.CidrIp")).To(Equal("10.0.16.0/20"))
+			Expect(requests[1].Get("IpPermissions.1.IpRanges.2.CidrIp")).To(Equal("10.0.32.0/20"))
+		})
+
+		It("deletes the security group if the ingress rules cannot be authorized", func() {
+			responses = []string{
+				`<CreateSecurityGroupResponse><groupId>sg-1234</groupId></CreateSecurityGroupResponse>`,
+				`<Response><Errors><Error><Code>InvalidPermission.Malformed</Code><Message>Invalid CIDR</Message></Error></Errors><RequestID>2</RequestID></Response>`,
+				`<DeleteSecurityGroupResponse><return>true</return></DeleteSecurityGroupResponse>`,
+			}
+			responseCodes = []int{200, 400, 200}
+
+			_, err := securityGroup.Create("cf-instance", SecurityGroupDetails{
+				IngressRules: []IngressRule{IngressRule{Protocol: "tcp", FromPort: 6379, ToPort: 6379, CIDRs: []string{"invalid"}}},
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("InvalidPermission.Malformed: Invalid CIDR"))
+
+			Expect(requests).To(HaveLen(3))
+			Expect(requests[2].Get("Action")).To(Equal("DeleteSecurityGroup"))
+			Expect(requests[2].Get("GroupId")).To(Equal("sg-1234"))
+		})
+	})
+
+	Describe("FindByName", func() {
+		It("returns the security group details", func() {
+			responses = []string{
+				`<DescribeSecurityGroupsResponse><securityGroupInfo><item><groupId>sg-1234</groupId><groupName>cf-instance</groupName><groupDescription>description</groupDescription><vpcId>vpc-1234</vpcId><ipPermissions><item><ipProtocol>tcp</ipProtocol><fromPort>6379</fromPort><toPort>6379</toPort><ipRanges><item><cidrIp>10.0.16.0/20</cidrIp></item></ipRanges></item></ipPermissions></item></securityGroupInfo></DescribeSecurityGroupsResponse>`,
+			}
+
+			securityGroupDetails, err := securityGroup.FindByName("cf-instance")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(securityGroupDetails).To(Equal(SecurityGroupDetails{
+				ID:          "sg-1234",
+				Name:        "cf-instance",
+				Description: "description",
+				VpcID:       "vpc-1234",
+				IngressRules: []IngressRule{
+					IngressRule{Protocol: "tcp", FromPort: 6379, ToPort: 6379, CIDRs: []string{"10.0.16.0/20"}},
+				},
+			}))
+
+			Expect(requests[0].Get("Action")).To(Equal("DescribeSecurityGroups"))
+			Expect(requests[0].Get("Filter.1.Name")).To(Equal("group-name"))
+			Expect(requests[0].Get("Filter.1.Value.1")).To(Equal("cf-instance"))
+		})
+
+		It("returns the proper error if the security group does not exist", func() {
+			responses = []string{`<DescribeSecurityGroupsResponse><securityGroupInfo/></DescribeSecurityGroupsResponse>`}
+
+			_, err := securityGroup.FindByName("cf-instance")
+			Expect(err).To(Equal(ErrSecurityGroupDoesNotExist))
+		})
+	})
+
+	Describe("Delete", func() {
+		It("returns the proper error if the security group is in use", func() {
+			responses = []string{`<Response><Errors><Error><Code>DependencyViolation</Code><Message>resource has a dependent object</Message></Error></Errors></Response>`}
+			responseCodes = []int{400}
+
+			err := securityGroup.Delete("sg-1234")
+			Expect(err).To(Equal(ErrSecurityGroupInUse))
+		})
+
+		It("retries throttled requests", func() {
+			awsConfig := aws.NewConfig().
+				WithRegion("us-east-1").
+				WithEndpoint(server.URL).
+				WithDisableSSL(true).
+				WithMaxRetries(1).
+				WithCredentials(credentials.NewStaticCredentials("access-key-id", "secret-access-key", ""))
+			securityGroup = NewEC2SecurityGroup(NewEC2API(session.New(awsConfig)), lagertest.NewTestLogger("ec2_security_group_test"))
+			responses = []string{
+				`<Response><Errors><Error><Code>RequestLimitExceeded</Code><Message>Request limit exceeded.</Message></Error></Errors></Response>`,
+				`<DeleteSecurityGroupResponse><requestId>2</requestId><return>true</return></DeleteSecurityGroupResponse>`,
+			}
+			responseCodes = []int{503, 200}
+
+			Expect(securityGroup.Delete("sg-1234")).To(Succeed())
+			Expect(requests).To(HaveLen(2))
+		})
+	})
+})
@@ -0,0 +1,56 @@
+package fakes
+
+import (
+	"github.com/cloudfoundry-community/elasticache-broker/awsec2"
+)
+
+type FakeSecurityGroup struct {
+	DescribeCalled               bool
+	DescribeID                   string
+	DescribeSecurityGroupDetails awsec2.SecurityGroupDetails
+	DescribeError                error
+
+	FindByNameCalled               bool
+	FindByNameName                 string
+	FindByNameSecurityGroupDetails awsec2.SecurityGroupDetails
+	FindByNameError                error
+
+	CreateCalled               bool
+	CreateName                 string
+	CreateSecurityGroupDetails awsec2.SecurityGroupDetails
+	CreateID                   string
+	CreateError                error
+
+	DeleteCalled bool
+	DeleteID     string
+	DeleteError  error
+}
+
+func (f *FakeSecurityGroup) Describe(ID string) (awsec2.SecurityGroupDetails, error) {
+	f.DescribeCalled = true
+	f.DescribeID = ID
+
+	return f.DescribeSecurityGroupDetails, f.DescribeError
+}
+
+func (f *FakeSecurityGroup) FindByName(name string) (awsec2.SecurityGroupDetails, error) {
+	f.FindByNameCalled = true
+	f.FindByNameName = name
+
+	return f.FindByNameSecurityGroupDetails, f.FindByNameError
+}
+
+func (f *FakeSecurityGroup) Create(name string, securityGroupDetails awsec2.SecurityGroupDetails) (string, error) {
+	f.CreateCalled = true
+	f.CreateName = name
+	f.CreateSecurityGroupDetails = securityGroupDetails
+
+	return f.CreateID, f.CreateError
+}
+
+func (f *FakeSecurityGroup) Delete(ID string) error {
+	f.DeleteCalled = true
+	f.DeleteID = ID
+
+	return f.DeleteError
+}
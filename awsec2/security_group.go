@@ -0,0 +1,32 @@
+package awsec2
+
+import (
+	"errors"
+)
+
+type SecurityGroup interface {
+	Describe(ID string) (SecurityGroupDetails, error)
+	FindByName(name string) (SecurityGroupDetails, error)
+	Create(name string, securityGroupDetails SecurityGroupDetails) (string, error)
+	Delete(ID string) error
+}
+
+type SecurityGroupDetails struct {
+	ID           string
+	Name         string
+	Description  string
+	VpcID        string
+	IngressRules []IngressRule
+}
+
+type IngressRule struct {
+	Protocol string
+	FromPort int64
+	ToPort   int64
+	CIDRs    []string
+}
+
+var (
+	ErrSecurityGroupDoesNotExist = errors.New("ec2 security group does not exist")
+	ErrSecurityGroupInUse        = errors.New("ec2 security group is in use")
+)
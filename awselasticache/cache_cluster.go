@@ -2,29 +2,57 @@ package awselasticache
 
 import (
 	"errors"
+	"time"
 )
 
 type CacheCluster interface {
 	Describe(ID string) (CacheClusterDetails, error)
+	DescribeByTags(tags map[string]string) (CacheClusterDetails, error)
 	Create(ID string, cacheClusterDetails CacheClusterDetails) error
 	Modify(ID string, cacheClusterDetails CacheClusterDetails, applyImmediately bool) error
 	Delete(ID string) error
+	ListTags(ID string) (map[string]string, error)
+	UpdateTags(ID string, tags map[string]string) error
+	DescribeEvents(ID string, startTime time.Time) ([]CacheClusterEvent, error)
 }
 
 type CacheClusterDetails struct {
-	CacheClusterId              string
-	Status                      string
-	Endpoint                    string
-	Engine                      string
-	EngineVersion               string
-	CacheInstanceClass	        string
-	Port                        int64
-	NumCacheNodes               int64
-	CacheSecurityGroups         []string
-	CacheSubnetGroupName        string
-	Tags                        map[string]string
+	CacheClusterId             string
+	Status                     string
+	Endpoint                   string
+	Engine                     string
+	EngineVersion              string
+	CacheInstanceClass         string
+	Port                       int64
+	NumCacheNodes              int64
+	CacheNodeIdsToRemove       []string
+	CacheSecurityGroups        []string
+	CacheSubnetGroupName       string
+	CacheParameterGroupName    string
+	PreferredAvailabilityZone  string
+	PreferredMaintenanceWindow string
+	SnapshotName               string
+	SnapshotRetentionLimit     *int64
+	SnapshotWindow             string
+	AutoMinorVersionUpgrade    *bool
+	CacheNodes                 []CacheNodeDetails
+	Tags                       map[string]string
+}
+
+type CacheNodeDetails struct {
+	CacheNodeId string
+	Status      string
+	Address     string
+	Port        int64
+}
+
+// CacheClusterEvent is an event reported by ElastiCache about a cache cluster.
+type CacheClusterEvent struct {
+	Date    time.Time
+	Message string
 }
 
 var (
 	ErrCacheClusterDoesNotExist = errors.New("elasticache cluster does not exist")
+	ErrResourceNotFound         = errors.New("elasticache resource not found")
 )
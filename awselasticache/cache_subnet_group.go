@@ -0,0 +1,24 @@
+package awselasticache
+
+import (
+	"errors"
+)
+
+type CacheSubnetGroup interface {
+	Describe(name string) (CacheSubnetGroupDetails, error)
+	List(namePrefix string) ([]CacheSubnetGroupDetails, error)
+	Create(name string, cacheSubnetGroupDetails CacheSubnetGroupDetails) error
+	Delete(name string) error
+}
+
+type CacheSubnetGroupDetails struct {
+	Name        string
+	Description string
+	VpcID       string
+	SubnetIDs   []string
+}
+
+var (
+	ErrCacheSubnetGroupDoesNotExist = errors.New("elasticache subnet group does not exist")
+	ErrCacheSubnetGroupInUse        = errors.New("elasticache subnet group is in use")
+)
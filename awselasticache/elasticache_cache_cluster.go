@@ -3,20 +3,22 @@ package awselasticache
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
-  "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/pivotal-golang/lager"
 )
 
 type ElastiCacheCluster struct {
-	region string
-	iamsvc *iam.IAM
+	region   string
+	iamsvc   *iam.IAM
 	cachesvc *elasticache.ElastiCache
-	logger lager.Logger
+	logger   lager.Logger
 }
 
 func NewElastiCacheCluster(
@@ -26,17 +28,17 @@ func NewElastiCacheCluster(
 	logger lager.Logger,
 ) *ElastiCacheCluster {
 	return &ElastiCacheCluster{
-		region: region,
-		iamsvc: iamsvc,
+		region:   region,
+		iamsvc:   iamsvc,
 		cachesvc: cachesvc,
-		logger: logger.Session("elasticache-cluster"),
+		logger:   logger.Session("elasticache-cluster"),
 	}
 }
 
 func (r *ElastiCacheCluster) Describe(ID string) (CacheClusterDetails, error) {
 	cacheClusterDetails := CacheClusterDetails{}
 	input := &elasticache.DescribeCacheClustersInput{
-		CacheClusterId: aws.String(ID),
+		CacheClusterId:    aws.String(ID),
 		ShowCacheNodeInfo: aws.Bool(true),
 	}
 
@@ -64,6 +66,57 @@ func (r *ElastiCacheCluster) Describe(ID string) (CacheClusterDetails, error) {
 	return cacheClusterDetails, ErrCacheClusterDoesNotExist
 }
 
+// DescribeByTags returns the first cache cluster carrying all the given tags. It lists every
+// cache cluster in the region, so it is meant as a fallback when a cluster cannot be found
+// by its identifier.
+func (r *ElastiCacheCluster) DescribeByTags(tags map[string]string) (CacheClusterDetails, error) {
+	var cacheClusters []*elasticache.CacheCluster
+	input := &elasticache.DescribeCacheClustersInput{
+		ShowCacheNodeInfo: aws.Bool(true),
+	}
+
+	r.logger.Debug("describe-cache-clusters", lager.Data{"input": input})
+	err := r.cachesvc.DescribeCacheClustersPages(input, func(page *elasticache.DescribeCacheClustersOutput, lastPage bool) bool {
+		cacheClusters = append(cacheClusters, page.CacheClusters...)
+		return true
+	})
+	if err != nil {
+		r.logger.Error("aws-elasticache-error", err)
+		if awsErr, ok := err.(awserr.Error); ok {
+			return CacheClusterDetails{}, errors.New(awsErr.Code() + ": " + awsErr.Message())
+		}
+		return CacheClusterDetails{}, err
+	}
+
+	if len(cacheClusters) == 0 {
+		return CacheClusterDetails{}, ErrCacheClusterDoesNotExist
+	}
+
+	userAccount, err := UserAccount(r.iamsvc)
+	if err != nil {
+		return CacheClusterDetails{}, err
+	}
+
+	for _, cacheCluster := range cacheClusters {
+		cacheClusterARN := fmt.Sprintf("arn:aws:elasticache:%s:%s:cluster:%s", r.region, userAccount, aws.StringValue(cacheCluster.CacheClusterId))
+		cacheClusterTags, err := ListTagsForResource(cacheClusterARN, r.cachesvc, r.logger)
+		if err != nil {
+			if err == ErrResourceNotFound {
+				continue
+			}
+			return CacheClusterDetails{}, err
+		}
+
+		if hasTags(cacheClusterTags, tags) {
+			r.logger.Debug("describe-cache-clusters", lager.Data{"cache-cluster": cacheCluster})
+			cacheClusterDetails := r.buildCacheCluster(cacheCluster)
+			cacheClusterDetails.Tags = cacheClusterTags
+			return cacheClusterDetails, nil
+		}
+	}
+
+	return CacheClusterDetails{}, ErrCacheClusterDoesNotExist
+}
 
 func (r *ElastiCacheCluster) Create(ID string, cacheClusterDetails CacheClusterDetails) error {
 	input := r.buildCreateCacheClusterInput(ID, cacheClusterDetails)
@@ -82,7 +135,6 @@ func (r *ElastiCacheCluster) Create(ID string, cacheClusterDetails CacheClusterD
 	return nil
 }
 
-
 func (r *ElastiCacheCluster) Modify(ID string, cacheClusterDetails CacheClusterDetails, applyImmediately bool) error {
 	input := r.buildModifyCacheClusterInput(ID, cacheClusterDetails, applyImmediately)
 	r.logger.Debug("modify-cache-cluster", lager.Data{"input": input})
@@ -103,14 +155,10 @@ func (r *ElastiCacheCluster) Modify(ID string, cacheClusterDetails CacheClusterD
 
 	r.logger.Debug("modify-cache-cluster", lager.Data{"output": output})
 
-	if len(cacheClusterDetails.Tags) > 0 {
-		cacheClusterARN, err := r.cacheClusterARN(ID)
-		if err != nil {
-			return nil
+	if cacheClusterDetails.Tags != nil {
+		if err := r.reconcileTags(ID, cacheClusterDetails.Tags); err != nil {
+			return err
 		}
-
-		tags := BuilElastiCacheTags(cacheClusterDetails.Tags)
-		AddTagsToResource(cacheClusterARN, tags, r.cachesvc, r.logger)
 	}
 
 	return nil
@@ -139,6 +187,99 @@ func (r *ElastiCacheCluster) Delete(ID string) error {
 	return nil
 }
 
+func (r *ElastiCacheCluster) ListTags(ID string) (map[string]string, error) {
+	cacheClusterARN, err := r.cacheClusterARN(ID)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := ListTagsForResource(cacheClusterARN, r.cachesvc, r.logger)
+	if err != nil {
+		if err == ErrResourceNotFound {
+			return nil, ErrCacheClusterDoesNotExist
+		}
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// UpdateTags replaces the cache cluster tags with the given set, without modifying the cache cluster.
+func (r *ElastiCacheCluster) UpdateTags(ID string, tags map[string]string) error {
+	return r.reconcileTags(ID, tags)
+}
+
+// DescribeEvents returns the events of the cache cluster since startTime, oldest first.
+func (r *ElastiCacheCluster) DescribeEvents(ID string, startTime time.Time) ([]CacheClusterEvent, error) {
+	var events []CacheClusterEvent
+	input := &elasticache.DescribeEventsInput{
+		SourceIdentifier: aws.String(ID),
+		SourceType:       aws.String("cache-cluster"),
+		StartTime:        aws.Time(startTime),
+	}
+
+	r.logger.Debug("describe-events", lager.Data{"input": input})
+	err := r.cachesvc.DescribeEventsPages(input, func(page *elasticache.DescribeEventsOutput, lastPage bool) bool {
+		for _, event := range page.Events {
+			events = append(events, CacheClusterEvent{
+				Date:    aws.TimeValue(event.Date),
+				Message: aws.StringValue(event.Message),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		r.logger.Error("aws-elasticache-error", err)
+		if awsErr, ok := err.(awserr.Error); ok {
+			return nil, errors.New(awsErr.Code() + ": " + awsErr.Message())
+		}
+		return nil, err
+	}
+
+	sort.Sort(eventsByDate(events))
+
+	return events, nil
+}
+
+type eventsByDate []CacheClusterEvent
+
+func (e eventsByDate) Len() int           { return len(e) }
+func (e eventsByDate) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e eventsByDate) Less(i, j int) bool { return e[i].Date.Before(e[j].Date) }
+
+// reconcileTags makes the cache cluster tags match the desired set,
+// only adding, updating or removing the keys that actually differ.
+func (r *ElastiCacheCluster) reconcileTags(ID string, desiredTags map[string]string) error {
+	cacheClusterARN, err := r.cacheClusterARN(ID)
+	if err != nil {
+		return err
+	}
+
+	currentTags, err := ListTagsForResource(cacheClusterARN, r.cachesvc, r.logger)
+	if err != nil {
+		if err == ErrResourceNotFound {
+			return ErrCacheClusterDoesNotExist
+		}
+		return err
+	}
+
+	tagsToAdd, tagKeysToRemove := DiffTags(currentTags, desiredTags)
+
+	if len(tagsToAdd) > 0 {
+		if err = AddTagsToResource(cacheClusterARN, BuilElastiCacheTags(tagsToAdd), r.cachesvc, r.logger); err != nil {
+			return err
+		}
+	}
+
+	if len(tagKeysToRemove) > 0 {
+		if err = RemoveTagsFromResource(cacheClusterARN, tagKeysToRemove, r.cachesvc, r.logger); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func UserAccount(iamsvc *iam.IAM) (string, error) {
 	getUserInput := &iam.GetUserInput{}
 	getUserOutput, err := iamsvc.GetUser(getUserInput)
@@ -156,26 +297,29 @@ func (r *ElastiCacheCluster) cacheClusterARN(ID string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("arn:aws:elasticache:%s:%s:db:%s", r.region, userAccount, ID), nil
+	return fmt.Sprintf("arn:aws:elasticache:%s:%s:cluster:%s", r.region, userAccount, ID), nil
 }
 
 func (r *ElastiCacheCluster) buildCreateCacheClusterInput(ID string, cacheClusterDetails CacheClusterDetails) *elasticache.CreateCacheClusterInput {
 	input := &elasticache.CreateCacheClusterInput{
 		CacheClusterId: aws.String(ID),
-		Engine:  aws.String(cacheClusterDetails.Engine),
+		Engine:         aws.String(cacheClusterDetails.Engine),
 	}
 
-  if cacheClusterDetails.NumCacheNodes > 0 {
+	if cacheClusterDetails.NumCacheNodes > 0 {
 		input.NumCacheNodes = aws.Int64(cacheClusterDetails.NumCacheNodes)
 	}
-  if cacheClusterDetails.CacheInstanceClass != "" {
+	if cacheClusterDetails.CacheInstanceClass != "" {
 		input.CacheNodeType = aws.String(cacheClusterDetails.CacheInstanceClass)
 	}
 	if cacheClusterDetails.CacheSubnetGroupName != "" {
 		input.CacheSubnetGroupName = aws.String(cacheClusterDetails.CacheSubnetGroupName)
 	}
+	if cacheClusterDetails.CacheParameterGroupName != "" {
+		input.CacheParameterGroupName = aws.String(cacheClusterDetails.CacheParameterGroupName)
+	}
 
-  if len(cacheClusterDetails.CacheSecurityGroups) > 0 {
+	if len(cacheClusterDetails.CacheSecurityGroups) > 0 {
 		input.SecurityGroupIds = aws.StringSlice(cacheClusterDetails.CacheSecurityGroups)
 	}
 
@@ -187,6 +331,30 @@ func (r *ElastiCacheCluster) buildCreateCacheClusterInput(ID string, cacheCluste
 		input.Port = aws.Int64(cacheClusterDetails.Port)
 	}
 
+	if cacheClusterDetails.PreferredAvailabilityZone != "" {
+		input.PreferredAvailabilityZone = aws.String(cacheClusterDetails.PreferredAvailabilityZone)
+	}
+
+	if cacheClusterDetails.PreferredMaintenanceWindow != "" {
+		input.PreferredMaintenanceWindow = aws.String(cacheClusterDetails.PreferredMaintenanceWindow)
+	}
+
+	if cacheClusterDetails.SnapshotName != "" {
+		input.SnapshotName = aws.String(cacheClusterDetails.SnapshotName)
+	}
+
+	if cacheClusterDetails.SnapshotRetentionLimit != nil {
+		input.SnapshotRetentionLimit = aws.Int64(*cacheClusterDetails.SnapshotRetentionLimit)
+	}
+
+	if cacheClusterDetails.SnapshotWindow != "" {
+		input.SnapshotWindow = aws.String(cacheClusterDetails.SnapshotWindow)
+	}
+
+	if cacheClusterDetails.AutoMinorVersionUpgrade != nil {
+		input.AutoMinorVersionUpgrade = aws.Bool(*cacheClusterDetails.AutoMinorVersionUpgrade)
+	}
+
 	if len(cacheClusterDetails.Tags) > 0 {
 		input.Tags = BuilElastiCacheTags(cacheClusterDetails.Tags)
 	}
@@ -194,7 +362,6 @@ func (r *ElastiCacheCluster) buildCreateCacheClusterInput(ID string, cacheCluste
 	return input
 }
 
-
 func (r *ElastiCacheCluster) buildDeleteCacheClusterInput(ID string) *elasticache.DeleteCacheClusterInput {
 	input := &elasticache.DeleteCacheClusterInput{
 		CacheClusterId: aws.String(ID),
@@ -204,14 +371,45 @@ func (r *ElastiCacheCluster) buildDeleteCacheClusterInput(ID string) *elasticach
 
 func (r *ElastiCacheCluster) buildModifyCacheClusterInput(ID string, cacheClusterDetails CacheClusterDetails, applyImmediately bool) *elasticache.ModifyCacheClusterInput {
 	modifyDBClusterInput := &elasticache.ModifyCacheClusterInput{
-		CacheClusterId: aws.String(ID),
-		ApplyImmediately:    aws.Bool(applyImmediately),
+		CacheClusterId:   aws.String(ID),
+		ApplyImmediately: aws.Bool(applyImmediately),
+	}
+
+	if cacheClusterDetails.CacheInstanceClass != "" {
+		modifyDBClusterInput.CacheNodeType = aws.String(cacheClusterDetails.CacheInstanceClass)
+	}
+
+	if cacheClusterDetails.EngineVersion != "" {
+		modifyDBClusterInput.EngineVersion = aws.String(cacheClusterDetails.EngineVersion)
+	}
+
+	if cacheClusterDetails.PreferredMaintenanceWindow != "" {
+		modifyDBClusterInput.PreferredMaintenanceWindow = aws.String(cacheClusterDetails.PreferredMaintenanceWindow)
+	}
+
+	if cacheClusterDetails.SnapshotRetentionLimit != nil {
+		modifyDBClusterInput.SnapshotRetentionLimit = aws.Int64(*cacheClusterDetails.SnapshotRetentionLimit)
+	}
+
+	if cacheClusterDetails.SnapshotWindow != "" {
+		modifyDBClusterInput.SnapshotWindow = aws.String(cacheClusterDetails.SnapshotWindow)
+	}
+
+	if cacheClusterDetails.AutoMinorVersionUpgrade != nil {
+		modifyDBClusterInput.AutoMinorVersionUpgrade = aws.Bool(*cacheClusterDetails.AutoMinorVersionUpgrade)
+	}
+
+	if cacheClusterDetails.NumCacheNodes > 0 {
+		modifyDBClusterInput.NumCacheNodes = aws.Int64(cacheClusterDetails.NumCacheNodes)
+	}
+
+	if len(cacheClusterDetails.CacheNodeIdsToRemove) > 0 {
+		modifyDBClusterInput.CacheNodeIdsToRemove = aws.StringSlice(cacheClusterDetails.CacheNodeIdsToRemove)
 	}
 
 	return modifyDBClusterInput
 }
 
-
 func BuilElastiCacheTags(tags map[string]string) []*elasticache.Tag {
 	var elasticacheTags []*elasticache.Tag
 
@@ -244,21 +442,116 @@ func AddTagsToResource(resourceARN string, tags []*elasticache.Tag, cachesvc *el
 	return nil
 }
 
+func ListTagsForResource(resourceARN string, cachesvc *elasticache.ElastiCache, logger lager.Logger) (map[string]string, error) {
+	input := &elasticache.ListTagsForResourceInput{
+		ResourceName: aws.String(resourceARN),
+	}
+
+	logger.Debug("list-tags-for-resource", lager.Data{"input": input})
+
+	output, err := cachesvc.ListTagsForResource(input)
+	if err != nil {
+		logger.Error("aws-elasticache-error", err)
+		if awsErr, ok := err.(awserr.Error); ok {
+			if awsErr.Code() == "CacheClusterNotFound" {
+				return nil, ErrResourceNotFound
+			}
+			return nil, errors.New(awsErr.Code() + ": " + awsErr.Message())
+		}
+		return nil, err
+	}
+
+	logger.Debug("list-tags-for-resource", lager.Data{"output": output})
+
+	tags := make(map[string]string)
+	for _, tag := range output.TagList {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+
+	return tags, nil
+}
+
+func RemoveTagsFromResource(resourceARN string, tagKeys []string, cachesvc *elasticache.ElastiCache, logger lager.Logger) error {
+	input := &elasticache.RemoveTagsFromResourceInput{
+		ResourceName: aws.String(resourceARN),
+		TagKeys:      aws.StringSlice(tagKeys),
+	}
+
+	logger.Debug("remove-tags-from-resource", lager.Data{"input": input})
+
+	output, err := cachesvc.RemoveTagsFromResource(input)
+	if err != nil {
+		logger.Error("aws-elasticache-error", err)
+		if awsErr, ok := err.(awserr.Error); ok {
+			return errors.New(awsErr.Code() + ": " + awsErr.Message())
+		}
+		return err
+	}
+
+	logger.Debug("remove-tags-from-resource", lager.Data{"output": output})
+
+	return nil
+}
+
+// DiffTags returns the tags that must be added (or updated) and the tag keys that
+// must be removed to turn the current tag set into the desired one.
+func DiffTags(currentTags, desiredTags map[string]string) (map[string]string, []string) {
+	tagsToAdd := make(map[string]string)
+	for key, value := range desiredTags {
+		if currentValue, ok := currentTags[key]; !ok || currentValue != value {
+			tagsToAdd[key] = value
+		}
+	}
+
+	var tagKeysToRemove []string
+	for key := range currentTags {
+		if _, ok := desiredTags[key]; !ok {
+			tagKeysToRemove = append(tagKeysToRemove, key)
+		}
+	}
+	sort.Strings(tagKeysToRemove)
+
+	return tagsToAdd, tagKeysToRemove
+}
+
+func hasTags(tags, wantedTags map[string]string) bool {
+	for key, value := range wantedTags {
+		if tagValue, ok := tags[key]; !ok || tagValue != value {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (r *ElastiCacheCluster) buildCacheCluster(cacheCluster *elasticache.CacheCluster) CacheClusterDetails {
 	cacheClusterDetails := CacheClusterDetails{
-		CacheClusterId:   aws.StringValue(cacheCluster.CacheClusterId),
-		Status:           aws.StringValue(cacheCluster.CacheClusterStatus),
-		Engine:           aws.StringValue(cacheCluster.Engine),
-		EngineVersion:    aws.StringValue(cacheCluster.EngineVersion),
-		NumCacheNodes:    aws.Int64Value(cacheCluster.NumCacheNodes),
+		CacheClusterId:     aws.StringValue(cacheCluster.CacheClusterId),
+		Status:             aws.StringValue(cacheCluster.CacheClusterStatus),
+		Engine:             aws.StringValue(cacheCluster.Engine),
+		EngineVersion:      aws.StringValue(cacheCluster.EngineVersion),
+		CacheInstanceClass: aws.StringValue(cacheCluster.CacheNodeType),
+		NumCacheNodes:      aws.Int64Value(cacheCluster.NumCacheNodes),
 	}
 
-	if len(cacheCluster.CacheNodes) > 0 {
+	if len(cacheCluster.CacheNodes) > 0 && cacheCluster.CacheNodes[0].Endpoint != nil {
 		node := cacheCluster.CacheNodes[0]
 
 		cacheClusterDetails.Endpoint = aws.StringValue(node.Endpoint.Address)
 		cacheClusterDetails.Port = aws.Int64Value(node.Endpoint.Port)
 	}
 
+	for _, node := range cacheCluster.CacheNodes {
+		cacheNodeDetails := CacheNodeDetails{
+			CacheNodeId: aws.StringValue(node.CacheNodeId),
+			Status:      aws.StringValue(node.CacheNodeStatus),
+		}
+		if node.Endpoint != nil {
+			cacheNodeDetails.Address = aws.StringValue(node.Endpoint.Address)
+			cacheNodeDetails.Port = aws.Int64Value(node.Endpoint.Port)
+		}
+		cacheClusterDetails.CacheNodes = append(cacheClusterDetails.CacheNodes, cacheNodeDetails)
+	}
+
 	return cacheClusterDetails
 }
@@ -0,0 +1,138 @@
+package awselasticache
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/pivotal-golang/lager"
+)
+
+type ElastiCacheSubnetGroup struct {
+	cachesvc *elasticache.ElastiCache
+	logger   lager.Logger
+}
+
+func NewElastiCacheSubnetGroup(
+	cachesvc *elasticache.ElastiCache,
+	logger lager.Logger,
+) *ElastiCacheSubnetGroup {
+	return &ElastiCacheSubnetGroup{
+		cachesvc: cachesvc,
+		logger:   logger.Session("elasticache-subnet-group"),
+	}
+}
+
+func (r *ElastiCacheSubnetGroup) Describe(name string) (CacheSubnetGroupDetails, error) {
+	cacheSubnetGroupDetails := CacheSubnetGroupDetails{}
+	input := &elasticache.DescribeCacheSubnetGroupsInput{
+		CacheSubnetGroupName: aws.String(name),
+	}
+
+	r.logger.Debug("describe-cache-subnet-groups", lager.Data{"input": input})
+	cacheSubnetGroups, err := r.cachesvc.DescribeCacheSubnetGroups(input)
+	if err != nil {
+		r.logger.Error("aws-elasticache-error", err)
+		if awsErr, ok := err.(awserr.Error); ok {
+			if awsErr.Code() == "CacheSubnetGroupNotFoundFault" {
+				return cacheSubnetGroupDetails, ErrCacheSubnetGroupDoesNotExist
+			}
+			return cacheSubnetGroupDetails, errors.New(awsErr.Code() + ": " + awsErr.Message())
+		}
+		return cacheSubnetGroupDetails, err
+	}
+
+	for _, cacheSubnetGroup := range cacheSubnetGroups.CacheSubnetGroups {
+		if aws.StringValue(cacheSubnetGroup.CacheSubnetGroupName) == name {
+			r.logger.Debug("describe-cache-subnet-groups", lager.Data{"cache-subnet-group": cacheSubnetGroup})
+			return r.buildCacheSubnetGroup(cacheSubnetGroup), nil
+		}
+	}
+	return cacheSubnetGroupDetails, ErrCacheSubnetGroupDoesNotExist
+}
+
+func (r *ElastiCacheSubnetGroup) List(namePrefix string) ([]CacheSubnetGroupDetails, error) {
+	var cacheSubnetGroupsDetails []CacheSubnetGroupDetails
+	input := &elasticache.DescribeCacheSubnetGroupsInput{}
+
+	r.logger.Debug("describe-cache-subnet-groups", lager.Data{"input": input})
+	err := r.cachesvc.DescribeCacheSubnetGroupsPages(input, func(page *elasticache.DescribeCacheSubnetGroupsOutput, lastPage bool) bool {
+		for _, cacheSubnetGroup := range page.CacheSubnetGroups {
+			if strings.HasPrefix(aws.StringValue(cacheSubnetGroup.CacheSubnetGroupName), namePrefix) {
+				cacheSubnetGroupsDetails = append(cacheSubnetGroupsDetails, r.buildCacheSubnetGroup(cacheSubnetGroup))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		r.logger.Error("aws-elasticache-error", err)
+		if awsErr, ok := err.(awserr.Error); ok {
+			return cacheSubnetGroupsDetails, errors.New(awsErr.Code() + ": " + awsErr.Message())
+		}
+		return cacheSubnetGroupsDetails, err
+	}
+
+	return cacheSubnetGroupsDetails, nil
+}
+
+func (r *ElastiCacheSubnetGroup) Create(name string, cacheSubnetGroupDetails CacheSubnetGroupDetails) error {
+	input := &elasticache.CreateCacheSubnetGroupInput{
+		CacheSubnetGroupName:        aws.String(name),
+		CacheSubnetGroupDescription: aws.String(cacheSubnetGroupDetails.Description),
+		SubnetIds:                   aws.StringSlice(cacheSubnetGroupDetails.SubnetIDs),
+	}
+	r.logger.Debug("create-cache-subnet-group", lager.Data{"input": input})
+
+	output, err := r.cachesvc.CreateCacheSubnetGroup(input)
+	if err != nil {
+		r.logger.Error("aws-elasticache-error", err)
+		if awsErr, ok := err.(awserr.Error); ok {
+			return errors.New(awsErr.Code() + ": " + awsErr.Message())
+		}
+		return err
+	}
+	r.logger.Debug("create-cache-subnet-group", lager.Data{"output": output})
+
+	return nil
+}
+
+func (r *ElastiCacheSubnetGroup) Delete(name string) error {
+	input := &elasticache.DeleteCacheSubnetGroupInput{
+		CacheSubnetGroupName: aws.String(name),
+	}
+	r.logger.Debug("delete-cache-subnet-group", lager.Data{"input": input})
+
+	output, err := r.cachesvc.DeleteCacheSubnetGroup(input)
+	if err != nil {
+		r.logger.Error("aws-elasticache-error", err)
+		if awsErr, ok := err.(awserr.Error); ok {
+			switch awsErr.Code() {
+			case "CacheSubnetGroupNotFoundFault":
+				return ErrCacheSubnetGroupDoesNotExist
+			case "CacheSubnetGroupInUse":
+				return ErrCacheSubnetGroupInUse
+			}
+			return errors.New(awsErr.Code() + ": " + awsErr.Message())
+		}
+		return err
+	}
+	r.logger.Debug("delete-cache-subnet-group", lager.Data{"output": output})
+
+	return nil
+}
+
+func (r *ElastiCacheSubnetGroup) buildCacheSubnetGroup(cacheSubnetGroup *elasticache.CacheSubnetGroup) CacheSubnetGroupDetails {
+	cacheSubnetGroupDetails := CacheSubnetGroupDetails{
+		Name:        aws.StringValue(cacheSubnetGroup.CacheSubnetGroupName),
+		Description: aws.StringValue(cacheSubnetGroup.CacheSubnetGroupDescription),
+		VpcID:       aws.StringValue(cacheSubnetGroup.VpcId),
+	}
+
+	for _, subnet := range cacheSubnetGroup.Subnets {
+		cacheSubnetGroupDetails.SubnetIDs = append(cacheSubnetGroupDetails.SubnetIDs, aws.StringValue(subnet.SubnetIdentifier))
+	}
+
+	return cacheSubnetGroupDetails
+}
@@ -0,0 +1,115 @@
+package awselasticache
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/pivotal-golang/lager"
+)
+
+type ElastiCacheOfferings struct {
+	cachesvc *elasticache.ElastiCache
+	logger   lager.Logger
+}
+
+func NewElastiCacheOfferings(
+	cachesvc *elasticache.ElastiCache,
+	logger lager.Logger,
+) *ElastiCacheOfferings {
+	return &ElastiCacheOfferings{
+		cachesvc: cachesvc,
+		logger:   logger.Session("elasticache-offerings"),
+	}
+}
+
+func (r *ElastiCacheOfferings) EngineVersionExists(engine string, engineVersion string) (bool, error) {
+	input := &elasticache.DescribeCacheEngineVersionsInput{
+		Engine: aws.String(engine),
+	}
+	if engineVersion != "" {
+		input.EngineVersion = aws.String(engineVersion)
+	}
+
+	r.logger.Debug("describe-cache-engine-versions", lager.Data{"input": input})
+	output, err := r.cachesvc.DescribeCacheEngineVersions(input)
+	if err != nil {
+		r.logger.Error("aws-elasticache-error", err)
+		return false, r.translateError(err)
+	}
+	r.logger.Debug("describe-cache-engine-versions", lager.Data{"output": output})
+
+	for _, cacheEngineVersion := range output.CacheEngineVersions {
+		if aws.StringValue(cacheEngineVersion.Engine) != engine {
+			continue
+		}
+		if engineVersion == "" || aws.StringValue(cacheEngineVersion.EngineVersion) == engineVersion {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// CacheNodeTypeOffered relies on the reserved cache node offerings, as they are the only
+// ElastiCache API listing the cache node types available for an engine in a region.
+func (r *ElastiCacheOfferings) CacheNodeTypeOffered(engine string, cacheNodeType string) (bool, error) {
+	input := &elasticache.DescribeReservedCacheNodesOfferingsInput{
+		CacheNodeType:      aws.String(cacheNodeType),
+		ProductDescription: aws.String(engine),
+	}
+
+	offered := false
+	r.logger.Debug("describe-reserved-cache-nodes-offerings", lager.Data{"input": input})
+	err := r.cachesvc.DescribeReservedCacheNodesOfferingsPages(input, func(page *elasticache.DescribeReservedCacheNodesOfferingsOutput, lastPage bool) bool {
+		for _, offering := range page.ReservedCacheNodesOfferings {
+			if aws.StringValue(offering.CacheNodeType) == cacheNodeType {
+				offered = true
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		r.logger.Error("aws-elasticache-error", err)
+		return false, r.translateError(err)
+	}
+
+	return offered, nil
+}
+
+func (r *ElastiCacheOfferings) CacheParameterGroupExists(name string) (bool, error) {
+	input := &elasticache.DescribeCacheParameterGroupsInput{
+		CacheParameterGroupName: aws.String(name),
+	}
+
+	r.logger.Debug("describe-cache-parameter-groups", lager.Data{"input": input})
+	output, err := r.cachesvc.DescribeCacheParameterGroups(input)
+	if err != nil {
+		r.logger.Error("aws-elasticache-error", err)
+		if err = r.translateError(err); err == ErrCacheParameterGroupDoesNotExist {
+			return false, nil
+		}
+		return false, err
+	}
+	r.logger.Debug("describe-cache-parameter-groups", lager.Data{"output": output})
+
+	for _, cacheParameterGroup := range output.CacheParameterGroups {
+		if aws.StringValue(cacheParameterGroup.CacheParameterGroupName) == name {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *ElastiCacheOfferings) translateError(err error) error {
+	if awsErr, ok := err.(awserr.Error); ok {
+		if awsErr.Code() == "CacheParameterGroupNotFound" {
+			return ErrCacheParameterGroupDoesNotExist
+		}
+		return errors.New(awsErr.Code() + ": " + awsErr.Message())
+	}
+	return err
+}
@@ -1,38 +1,90 @@
 package fakes
 
 import (
+	"time"
+
 	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
 )
 
 type FakeCacheCluster struct {
-	DescribeCalled           bool
-	DescribeID               string
+	DescribeCalled              bool
+	DescribeID                  string
 	DescribeCacheClusterDetails awselasticache.CacheClusterDetails
-	DescribeError            error
+	DescribeError               error
+	DescribeIDs                 []string
+	DescribeCacheClusters       map[string]awselasticache.CacheClusterDetails
+
+	DescribeByTagsCalled bool
+	DescribeByTagsTags   map[string]string
 
-	CreateCalled           bool
-	CreateID               string
+	CreateCalled              bool
+	CreateID                  string
 	CreateCacheClusterDetails awselasticache.CacheClusterDetails
-	CreateError            error
+	CreateError               error
 
-	ModifyCalled           bool
-	ModifyID               string
+	ModifyCalled              bool
+	ModifyID                  string
 	ModifyCacheClusterDetails awselasticache.CacheClusterDetails
-	ModifyApplyImmediately bool
-	ModifyError            error
+	ModifyApplyImmediately    bool
+	ModifyError               error
+
+	DeleteCalled bool
+	DeleteID     string
+	DeleteError  error
+
+	ListTagsCalled bool
+	ListTagsID     string
+	ListTagsTags   map[string]string
+	ListTagsError  error
+
+	UpdateTagsCalled bool
+	UpdateTagsID     string
+	UpdateTagsTags   map[string]string
+	UpdateTagsError  error
 
-	DeleteCalled            bool
-	DeleteID                string
-	DeleteError             error
+	DescribeEventsCalled    bool
+	DescribeEventsID        string
+	DescribeEventsStartTime time.Time
+	DescribeEventsEvents    []awselasticache.CacheClusterEvent
+	DescribeEventsError     error
 }
 
 func (f *FakeCacheCluster) Describe(ID string) (awselasticache.CacheClusterDetails, error) {
 	f.DescribeCalled = true
 	f.DescribeID = ID
+	f.DescribeIDs = append(f.DescribeIDs, ID)
+
+	if f.DescribeCacheClusters != nil {
+		cacheClusterDetails, ok := f.DescribeCacheClusters[ID]
+		if !ok {
+			return cacheClusterDetails, awselasticache.ErrCacheClusterDoesNotExist
+		}
+		return cacheClusterDetails, nil
+	}
 
 	return f.DescribeCacheClusterDetails, f.DescribeError
 }
 
+// DescribeByTags looks for a matching cache cluster in DescribeCacheClusters.
+func (f *FakeCacheCluster) DescribeByTags(tags map[string]string) (awselasticache.CacheClusterDetails, error) {
+	f.DescribeByTagsCalled = true
+	f.DescribeByTagsTags = tags
+
+	for _, cacheClusterDetails := range f.DescribeCacheClusters {
+		matches := true
+		for key, value := range tags {
+			if cacheClusterDetails.Tags[key] != value {
+				matches = false
+			}
+		}
+		if matches {
+			return cacheClusterDetails, nil
+		}
+	}
+
+	return awselasticache.CacheClusterDetails{}, awselasticache.ErrCacheClusterDoesNotExist
+}
+
 func (f *FakeCacheCluster) Create(ID string, cacheClusterDetails awselasticache.CacheClusterDetails) error {
 	f.CreateCalled = true
 	f.CreateID = ID
@@ -56,3 +108,30 @@ func (f *FakeCacheCluster) Delete(ID string) error {
 
 	return f.DeleteError
 }
+
+func (f *FakeCacheCluster) ListTags(ID string) (map[string]string, error) {
+	f.ListTagsCalled = true
+	f.ListTagsID = ID
+
+	if cacheClusterDetails, ok := f.DescribeCacheClusters[ID]; ok && f.ListTagsTags == nil {
+		return cacheClusterDetails.Tags, f.ListTagsError
+	}
+
+	return f.ListTagsTags, f.ListTagsError
+}
+
+func (f *FakeCacheCluster) UpdateTags(ID string, tags map[string]string) error {
+	f.UpdateTagsCalled = true
+	f.UpdateTagsID = ID
+	f.UpdateTagsTags = tags
+
+	return f.UpdateTagsError
+}
+
+func (f *FakeCacheCluster) DescribeEvents(ID string, startTime time.Time) ([]awselasticache.CacheClusterEvent, error) {
+	f.DescribeEventsCalled = true
+	f.DescribeEventsID = ID
+	f.DescribeEventsStartTime = startTime
+
+	return f.DescribeEventsEvents, f.DescribeEventsError
+}
@@ -0,0 +1,55 @@
+package fakes
+
+import (
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+)
+
+type FakeCacheSubnetGroup struct {
+	DescribeCalled                  bool
+	DescribeName                    string
+	DescribeCacheSubnetGroupDetails awselasticache.CacheSubnetGroupDetails
+	DescribeError                   error
+
+	ListCalled                   bool
+	ListNamePrefix               string
+	ListCacheSubnetGroupsDetails []awselasticache.CacheSubnetGroupDetails
+	ListError                    error
+
+	CreateCalled                  bool
+	CreateName                    string
+	CreateCacheSubnetGroupDetails awselasticache.CacheSubnetGroupDetails
+	CreateError                   error
+
+	DeleteCalled bool
+	DeleteNames  []string
+	DeleteError  error
+}
+
+func (f *FakeCacheSubnetGroup) Describe(name string) (awselasticache.CacheSubnetGroupDetails, error) {
+	f.DescribeCalled = true
+	f.DescribeName = name
+
+	return f.DescribeCacheSubnetGroupDetails, f.DescribeError
+}
+
+func (f *FakeCacheSubnetGroup) List(namePrefix string) ([]awselasticache.CacheSubnetGroupDetails, error) {
+	f.ListCalled = true
+	f.ListNamePrefix = namePrefix
+
+	return f.ListCacheSubnetGroupsDetails, f.ListError
+}
+
+func (f *FakeCacheSubnetGroup) Create(name string, cacheSubnetGroupDetails awselasticache.CacheSubnetGroupDetails) error {
+	f.CreateCalled = true
+	f.CreateName = name
+	f.CreateCacheSubnetGroupDetails = cacheSubnetGroupDetails
+
+	return f.CreateError
+}
+
+func (f *FakeCacheSubnetGroup) Delete(name string) error {
+	f.DeleteCalled = true
+	f.DeleteNames = append(f.DeleteNames, name)
+
+	return f.DeleteError
+}
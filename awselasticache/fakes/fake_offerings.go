@@ -0,0 +1,43 @@
+package fakes
+
+type FakeOfferings struct {
+	EngineVersionExistsCalled        bool
+	EngineVersionExistsEngine        string
+	EngineVersionExistsEngineVersion string
+	EngineVersionExistsResult        bool
+	EngineVersionExistsError         error
+
+	CacheNodeTypeOfferedCalled        bool
+	CacheNodeTypeOfferedEngine        string
+	CacheNodeTypeOfferedCacheNodeType string
+	CacheNodeTypeOfferedResult        bool
+	CacheNodeTypeOfferedError         error
+
+	CacheParameterGroupExistsCalled bool
+	CacheParameterGroupExistsName   string
+	CacheParameterGroupExistsResult bool
+	CacheParameterGroupExistsError  error
+}
+
+func (f *FakeOfferings) EngineVersionExists(engine string, engineVersion string) (bool, error) {
+	f.EngineVersionExistsCalled = true
+	f.EngineVersionExistsEngine = engine
+	f.EngineVersionExistsEngineVersion = engineVersion
+
+	return f.EngineVersionExistsResult, f.EngineVersionExistsError
+}
+
+func (f *FakeOfferings) CacheNodeTypeOffered(engine string, cacheNodeType string) (bool, error) {
+	f.CacheNodeTypeOfferedCalled = true
+	f.CacheNodeTypeOfferedEngine = engine
+	f.CacheNodeTypeOfferedCacheNodeType = cacheNodeType
+
+	return f.CacheNodeTypeOfferedResult, f.CacheNodeTypeOfferedError
+}
+
+func (f *FakeOfferings) CacheParameterGroupExists(name string) (bool, error) {
+	f.CacheParameterGroupExistsCalled = true
+	f.CacheParameterGroupExistsName = name
+
+	return f.CacheParameterGroupExistsResult, f.CacheParameterGroupExistsError
+}
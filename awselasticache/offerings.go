@@ -0,0 +1,16 @@
+package awselasticache
+
+import (
+	"errors"
+)
+
+// Offerings answers questions about what ElastiCache offers in the configured region.
+type Offerings interface {
+	EngineVersionExists(engine string, engineVersion string) (bool, error)
+	CacheNodeTypeOffered(engine string, cacheNodeType string) (bool, error)
+	CacheParameterGroupExists(name string) (bool, error)
+}
+
+var (
+	ErrCacheParameterGroupDoesNotExist = errors.New("elasticache parameter group does not exist")
+)
@@ -0,0 +1,142 @@
+package broker
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/frodenas/brokerapi"
+	"github.com/pivotal-golang/lager"
+
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+// keepClusterTagKey marks adopted cache clusters that are released instead of deleted on deprovision.
+const keepClusterTagKey = "Keep Cluster"
+
+// adoptCacheCluster turns an existing cache cluster into the cache cluster of a service
+// instance. The cache cluster is not modified, only tagged with the broker ownership tags and
+// recorded in the store, along with its tags before adoption, which is how it is found later
+// on by Bind, Update, LastOperation and Deprovision.
+func (b *ElastiCacheBroker) adoptCacheCluster(instanceID string, servicePlan ServicePlan, provisionParameters ProvisionParameters, details brokerapi.ProvisionDetails) error {
+	cacheClusterID := provisionParameters.AdoptClusterID
+
+	if servicePlan.Adoption == nil {
+		err := fmt.Errorf("Service Plan '%s' does not allow adopting cache clusters", servicePlan.Name)
+		return api.NewUnprocessableEntityResponse(err, "adoption-not-allowed")
+	}
+
+	if !containsString(servicePlan.Adoption.AllowedOrganizationIDs, details.OrganizationGUID) {
+		err := fmt.Errorf("Organization '%s' is not allowed to adopt cache clusters with Service Plan '%s'", details.OrganizationGUID, servicePlan.Name)
+		return api.NewUnprocessableEntityResponse(err, "adoption-not-allowed")
+	}
+
+	cacheClusterDetails, err := b.cacheCluster.Describe(cacheClusterID)
+	if err != nil {
+		if err == awselasticache.ErrCacheClusterDoesNotExist {
+			err = fmt.Errorf("Cache Cluster '%s' does not exist", cacheClusterID)
+			return api.NewUnprocessableEntityResponse(err, "adoption-not-allowed")
+		}
+		return err
+	}
+
+	if cacheClusterDetails.Engine != servicePlan.ElastiCacheProperties.Engine {
+		err = fmt.Errorf("Cache Cluster '%s' uses the '%s' engine, but Service Plan '%s' uses the '%s' engine", cacheClusterID, cacheClusterDetails.Engine, servicePlan.Name, servicePlan.ElastiCacheProperties.Engine)
+		return api.NewUnprocessableEntityResponse(err, "adoption-not-allowed")
+	}
+
+	currentTags, err := b.cacheCluster.ListTags(cacheClusterID)
+	if err != nil {
+		return err
+	}
+
+	if owner, ok := currentTags["Instance ID"]; ok {
+		err = fmt.Errorf("Cache Cluster '%s' is already managed as service instance '%s'", cacheClusterID, owner)
+		return api.NewUnprocessableEntityResponse(err, "adoption-not-allowed")
+	}
+
+	keepCluster := true
+	if provisionParameters.KeepCluster != nil {
+		keepCluster = *provisionParameters.KeepCluster
+	}
+
+	err = b.lockInstance(instanceID, "", store.OperationProvision, func(instance *store.Instance) {
+		instance.ServiceID = details.ServiceID
+		instance.PlanID = details.PlanID
+		instance.OrganizationID = details.OrganizationGUID
+		instance.SpaceID = details.SpaceGUID
+		instance.CacheClusterID = cacheClusterID
+		instance.Parameters = details.Parameters
+		instance.AdoptedTags = currentTags
+	})
+	if err != nil {
+		return err
+	}
+
+	tags := make(map[string]string)
+	for key, value := range currentTags {
+		tags[key] = value
+	}
+	for key, value := range provisionParameters.Tags {
+		tags[key] = value
+	}
+	for key, value := range b.cacheTags("Adopted", details.ServiceID, details.PlanID, details.OrganizationGUID, details.SpaceGUID) {
+		tags[key] = value
+	}
+	for key, value := range b.ownershipTags(instanceID) {
+		tags[key] = value
+	}
+	tags[keepClusterTagKey] = strconv.FormatBool(keepCluster)
+
+	if err = b.cacheCluster.UpdateTags(cacheClusterID, tags); err != nil {
+		if forgetErr := b.forgetInstance(instanceID); forgetErr != nil {
+			b.logger.Error("forget-instance", forgetErr, lager.Data{instanceIDLogKey: instanceID})
+		}
+		return err
+	}
+
+	if err = b.finishOperation(instanceID); err != nil {
+		return err
+	}
+
+	b.logger.Info("adopted-cache-cluster", lager.Data{instanceIDLogKey: instanceID, "cache-cluster-id": cacheClusterID})
+
+	return nil
+}
+
+// releaseCacheCluster removes the tags added by the broker from an adopted cache cluster, and
+// restores the tags it had before its adoption. Tags added by others in the meantime are kept.
+// Cache clusters adopted before their tags were recorded only lose the broker tags.
+func (b *ElastiCacheBroker) releaseCacheCluster(instanceID string, cacheClusterID string, currentTags map[string]string) error {
+	var adoptedTags map[string]string
+	var userTagKeys []string
+	if instance, err := b.store.GetInstance(instanceID); err == nil {
+		adoptedTags = instance.AdoptedTags
+		if userTags, ok := instance.Parameters["tags"].(map[string]interface{}); ok {
+			for key := range userTags {
+				userTagKeys = append(userTagKeys, key)
+			}
+		}
+	}
+
+	tags := make(map[string]string)
+	for key, value := range currentTags {
+		if adoptedValue, ok := adoptedTags[key]; ok {
+			tags[key] = adoptedValue
+		} else if !b.isBrokerTagKey(key) && (adoptedTags == nil || !containsString(userTagKeys, key)) {
+			tags[key] = value
+		}
+	}
+	for key, value := range adoptedTags {
+		tags[key] = value
+	}
+
+	if err := b.cacheCluster.UpdateTags(cacheClusterID, tags); err != nil {
+		return err
+	}
+
+	b.logger.Info("released-cache-cluster", lager.Data{instanceIDLogKey: instanceID, "cache-cluster-id": cacheClusterID})
+
+	return nil
+}
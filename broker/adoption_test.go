@@ -0,0 +1,239 @@
+package broker_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/frodenas/brokerapi"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+	cwfakes "github.com/cloudfoundry-community/elasticache-broker/awscloudwatch/fakes"
+	ec2fakes "github.com/cloudfoundry-community/elasticache-broker/awsec2/fakes"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache/fakes"
+	. "github.com/cloudfoundry-community/elasticache-broker/broker"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+var _ = Describe("Cache Cluster Adoption", func() {
+	const instanceID = "ce71b484-d542-40f7-9dd4-5526e38c81ba"
+
+	var (
+		config           Config
+		cacheCluster     *fakes.FakeCacheCluster
+		brokerStore      store.Store
+		provisionDetails brokerapi.ProvisionDetails
+
+		elastiCacheBroker *ElastiCacheBroker
+	)
+
+	BeforeEach(func() {
+		config = Config{
+			Region:                       "elasticache-region",
+			CachePrefix:                  "cf",
+			AllowUserProvisionParameters: true,
+			Catalog: Catalog{
+				Services: []Service{
+					Service{
+						ID:       "Service-1",
+						Bindable: true,
+						Plans: []ServicePlan{
+							ServicePlan{
+								ID:   "Plan-1",
+								Name: "Plan 1",
+								ElastiCacheProperties: ElastiCacheProperties{
+									CacheInstanceClass: "cache.t2.micro",
+									Engine:             "redis",
+								},
+								Adoption: &Adoption{
+									AllowedOrganizationIDs: []string{"organization-id"},
+								},
+							},
+							ServicePlan{
+								ID:   "Plan-2",
+								Name: "Plan 2",
+								ElastiCacheProperties: ElastiCacheProperties{
+									CacheInstanceClass: "cache.t2.micro",
+									Engine:             "redis",
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		cacheCluster = &fakes.FakeCacheCluster{
+			DescribeCacheClusters: map[string]awselasticache.CacheClusterDetails{
+				"hand-built": awselasticache.CacheClusterDetails{
+					CacheClusterId: "hand-built",
+					Status:         "available",
+					Engine:         "redis",
+					Tags:           map[string]string{"Team": "a-team"},
+				},
+			},
+		}
+		brokerStore = store.NewMemoryStore()
+		provisionDetails = brokerapi.ProvisionDetails{
+			ServiceID:        "Service-1",
+			PlanID:           "Plan-1",
+			OrganizationGUID: "organization-id",
+			SpaceGUID:        "space-id",
+			Parameters:       map[string]interface{}{"adopt_cluster_id": "hand-built"},
+		}
+	})
+
+	JustBeforeEach(func() {
+		elastiCacheBroker = New(config, cacheCluster, &fakes.FakeCacheSubnetGroup{}, &ec2fakes.FakeSecurityGroup{}, &cwfakes.FakeCacheClusterMetrics{}, nil, brokerStore, lagertest.NewTestLogger("broker_test"))
+	})
+
+	provision := func() (bool, error) {
+		_, asynch, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+		return asynch, err
+	}
+
+	It("tags the cache cluster instead of creating one", func() {
+		asynch, err := provision()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(asynch).To(BeFalse())
+		Expect(cacheCluster.CreateCalled).To(BeFalse())
+		Expect(cacheCluster.UpdateTagsID).To(Equal("hand-built"))
+		Expect(cacheCluster.UpdateTagsTags).To(HaveKeyWithValue("Team", "a-team"))
+		Expect(cacheCluster.UpdateTagsTags).To(HaveKeyWithValue("Instance ID", instanceID))
+		Expect(cacheCluster.UpdateTagsTags).To(HaveKeyWithValue("Broker ID", "cf"))
+		Expect(cacheCluster.UpdateTagsTags).To(HaveKeyWithValue("Adopted by", "AWS ElastiCache Service Broker"))
+		Expect(cacheCluster.UpdateTagsTags).To(HaveKeyWithValue("Keep Cluster", "true"))
+	})
+
+	It("records the cache cluster and its tags before adoption", func() {
+		_, err := provision()
+		Expect(err).ToNot(HaveOccurred())
+
+		instance, err := brokerStore.GetInstance(instanceID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(instance.CacheClusterID).To(Equal("hand-built"))
+		Expect(instance.AdoptedTags).To(Equal(map[string]string{"Team": "a-team"}))
+		Expect(instance.Operation).To(BeNil())
+	})
+
+	It("forgets the service instance when the cache cluster cannot be tagged", func() {
+		cacheCluster.UpdateTagsError = errors.New("AccessDenied: not authorized")
+
+		_, err := provision()
+		Expect(err).To(MatchError("AccessDenied: not authorized"))
+
+		_, err = brokerStore.GetInstance(instanceID)
+		Expect(err).To(Equal(store.ErrInstanceNotFound))
+	})
+
+	It("rejects plans not allowing adoption", func() {
+		provisionDetails.PlanID = "Plan-2"
+
+		_, err := provision()
+		Expect(err).To(MatchError("Service Plan 'Plan 2' does not allow adopting cache clusters"))
+		Expect(err.(*api.FailureResponse).StatusCode()).To(Equal(422))
+		Expect(cacheCluster.UpdateTagsCalled).To(BeFalse())
+	})
+
+	It("rejects organizations not allowed to adopt", func() {
+		provisionDetails.OrganizationGUID = "other-organization-id"
+
+		_, err := provision()
+		Expect(err).To(MatchError("Organization 'other-organization-id' is not allowed to adopt cache clusters with Service Plan 'Plan 1'"))
+		Expect(cacheCluster.UpdateTagsCalled).To(BeFalse())
+	})
+
+	It("rejects cache clusters that do not exist", func() {
+		provisionDetails.Parameters["adopt_cluster_id"] = "missing"
+
+		_, err := provision()
+		Expect(err).To(MatchError("Cache Cluster 'missing' does not exist"))
+	})
+
+	It("rejects cache clusters with another engine", func() {
+		cacheClusterDetails := cacheCluster.DescribeCacheClusters["hand-built"]
+		cacheClusterDetails.Engine = "memcached"
+		cacheCluster.DescribeCacheClusters["hand-built"] = cacheClusterDetails
+
+		_, err := provision()
+		Expect(err).To(MatchError("Cache Cluster 'hand-built' uses the 'memcached' engine, but Service Plan 'Plan 1' uses the 'redis' engine"))
+	})
+
+	It("rejects cache clusters already managed as a service instance", func() {
+		cacheCluster.DescribeCacheClusters["hand-built"].Tags["Instance ID"] = "other-instance-id"
+
+		_, err := provision()
+		Expect(err).To(MatchError("Cache Cluster 'hand-built' is already managed as service instance 'other-instance-id'"))
+	})
+
+	It("rejects keep_cluster without adopt_cluster_id", func() {
+		provisionDetails.Parameters = map[string]interface{}{"keep_cluster": true}
+
+		_, err := provision()
+		Expect(err).To(MatchError("Parameter 'keep_cluster' requires 'adopt_cluster_id'"))
+		Expect(cacheCluster.CreateCalled).To(BeFalse())
+	})
+
+	Context("when the cache cluster has been adopted", func() {
+		BeforeEach(func() {
+			cacheCluster.DescribeCacheClusters["hand-built"].Tags["Instance ID"] = instanceID
+			cacheCluster.DescribeCacheClusters["hand-built"].Tags["Broker ID"] = "cf"
+			cacheCluster.DescribeCacheClusters["hand-built"].Tags["Adopted by"] = "AWS ElastiCache Service Broker"
+			cacheCluster.DescribeCacheClusters["hand-built"].Tags["Keep Cluster"] = "true"
+			Expect(brokerStore.PutInstance(store.Instance{
+				ID:             instanceID,
+				ServiceID:      "Service-1",
+				PlanID:         "Plan-1",
+				OrganizationID: "organization-id",
+				SpaceID:        "space-id",
+				CacheClusterID: "hand-built",
+			})).To(Succeed())
+		})
+
+		It("binds to the cache cluster", func() {
+			bindingResponse, err := elastiCacheBroker.Bind(instanceID, "binding-id", brokerapi.BindDetails{ServiceID: "Service-1", PlanID: "Plan-1"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bindingResponse.Credentials.(*brokerapi.CredentialsHash).Name).To(Equal("hand-built"))
+		})
+
+		It("releases the cache cluster on deprovision", func() {
+			asynch, err := elastiCacheBroker.Deprovision(instanceID, brokerapi.DeprovisionDetails{ServiceID: "Service-1", PlanID: "Plan-1"}, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(asynch).To(BeFalse())
+			Expect(cacheCluster.DeleteCalled).To(BeFalse())
+			Expect(cacheCluster.UpdateTagsID).To(Equal("hand-built"))
+			Expect(cacheCluster.UpdateTagsTags).To(Equal(map[string]string{"Team": "a-team"}))
+		})
+
+		Context("when its tags before adoption are recorded", func() {
+			BeforeEach(func() {
+				cacheCluster.DescribeCacheClusters["hand-built"].Tags["Owner"] = "Cloud Foundry"
+				cacheCluster.DescribeCacheClusters["hand-built"].Tags["Cost Center"] = "cc-1234"
+				cacheCluster.DescribeCacheClusters["hand-built"].Tags["Backup"] = "daily"
+
+				instance, err := brokerStore.GetInstance(instanceID)
+				Expect(err).ToNot(HaveOccurred())
+				instance.Parameters = map[string]interface{}{"tags": map[string]interface{}{"Cost Center": "cc-1234"}}
+				instance.AdoptedTags = map[string]string{"Team": "a-team", "Owner": "ops"}
+				Expect(brokerStore.PutInstance(instance)).To(Succeed())
+			})
+
+			It("only removes the tags added by the broker on deprovision", func() {
+				_, err := elastiCacheBroker.Deprovision(instanceID, brokerapi.DeprovisionDetails{ServiceID: "Service-1", PlanID: "Plan-1"}, true)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cacheCluster.UpdateTagsTags).To(Equal(map[string]string{"Team": "a-team", "Owner": "ops", "Backup": "daily"}))
+			})
+		})
+
+		It("deletes the cache cluster on deprovision when it is not to be kept", func() {
+			cacheCluster.DescribeCacheClusters["hand-built"].Tags["Keep Cluster"] = "false"
+
+			asynch, err := elastiCacheBroker.Deprovision(instanceID, brokerapi.DeprovisionDetails{ServiceID: "Service-1", PlanID: "Plan-1"}, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(asynch).To(BeTrue())
+			Expect(cacheCluster.DeleteID).To(Equal("hand-built"))
+		})
+	})
+})
@@ -0,0 +1,176 @@
+package broker
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/pivotal-golang/lager"
+
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/cloudcontroller"
+)
+
+// lookupHost resolves the cache node addresses to IPs. It is a variable so tests can stub it.
+var lookupHost = net.LookupHost
+
+// ensureApplicationSecurityGroup creates (or updates) a space-scoped Application Security Group
+// that allows egress traffic from the application space only to the cache cluster nodes.
+func (b *ElastiCacheBroker) ensureApplicationSecurityGroup(instanceID string, appGUID string, cacheClusterDetails awselasticache.CacheClusterDetails) error {
+	app, err := b.cloudController.GetApp(appGUID)
+	if err != nil {
+		return fmt.Errorf("Retrieving application '%s': %s", appGUID, err)
+	}
+
+	rules, err := b.applicationSecurityGroupRules(cacheClusterDetails)
+	if err != nil {
+		return err
+	}
+
+	securityGroupName := b.applicationSecurityGroupName(instanceID)
+	securityGroup, err := b.cloudController.FindSecurityGroup(securityGroupName)
+	if err != nil {
+		if err != cloudcontroller.ErrResourceNotFound {
+			return err
+		}
+
+		securityGroup = cloudcontroller.SecurityGroup{
+			Name:       securityGroupName,
+			Rules:      rules,
+			SpaceGUIDs: []string{app.SpaceGUID},
+		}
+		if _, err = b.cloudController.CreateSecurityGroup(securityGroup); err != nil {
+			return err
+		}
+		b.logger.Info("created-application-security-group", lager.Data{instanceIDLogKey: instanceID, "space-guid": app.SpaceGUID})
+
+		return nil
+	}
+
+	securityGroup.Rules = rules
+	if err = b.cloudController.UpdateSecurityGroup(securityGroup); err != nil {
+		return err
+	}
+
+	return b.cloudController.BindSecurityGroupToSpace(securityGroup.GUID, app.SpaceGUID)
+}
+
+// releaseApplicationSecurityGroup releases the Application Security Group of a service instance
+// from a binding being removed: it is deleted once there are no other bindings left, and unbound
+// from the space of the binding application once no other bound application is in that space.
+func (b *ElastiCacheBroker) releaseApplicationSecurityGroup(instanceID string, bindingID string) error {
+	serviceBindings, err := b.cloudController.ListServiceBindings(instanceID)
+	if err != nil && err != cloudcontroller.ErrResourceNotFound {
+		return err
+	}
+
+	var appGUID string
+	if binding, err := b.store.GetBinding(instanceID, bindingID); err == nil {
+		appGUID = binding.AppGUID
+	}
+
+	var otherAppGUIDs []string
+	for _, serviceBinding := range serviceBindings {
+		if serviceBinding.GUID == bindingID {
+			if appGUID == "" {
+				appGUID = serviceBinding.AppGUID
+			}
+			continue
+		}
+		otherAppGUIDs = append(otherAppGUIDs, serviceBinding.AppGUID)
+	}
+
+	securityGroup, err := b.cloudController.FindSecurityGroup(b.applicationSecurityGroupName(instanceID))
+	if err != nil {
+		if err == cloudcontroller.ErrResourceNotFound {
+			return nil
+		}
+		return err
+	}
+
+	if len(otherAppGUIDs) == 0 {
+		if err = b.cloudController.DeleteSecurityGroup(securityGroup.GUID); err != nil && err != cloudcontroller.ErrResourceNotFound {
+			return err
+		}
+		b.logger.Info("deleted-application-security-group", lager.Data{instanceIDLogKey: instanceID})
+
+		return nil
+	}
+
+	if appGUID == "" {
+		return nil
+	}
+
+	app, err := b.cloudController.GetApp(appGUID)
+	if err != nil {
+		if err == cloudcontroller.ErrResourceNotFound {
+			b.logger.Info("unknown-application-space", lager.Data{instanceIDLogKey: instanceID, "app-guid": appGUID})
+			return nil
+		}
+		return fmt.Errorf("Retrieving application '%s': %s", appGUID, err)
+	}
+
+	for _, otherAppGUID := range otherAppGUIDs {
+		otherApp, err := b.cloudController.GetApp(otherAppGUID)
+		if err != nil {
+			if err == cloudcontroller.ErrResourceNotFound {
+				continue
+			}
+			return fmt.Errorf("Retrieving application '%s': %s", otherAppGUID, err)
+		}
+		if otherApp.SpaceGUID == app.SpaceGUID {
+			return nil
+		}
+	}
+
+	if err = b.cloudController.UnbindSecurityGroupFromSpace(securityGroup.GUID, app.SpaceGUID); err != nil && err != cloudcontroller.ErrResourceNotFound {
+		return err
+	}
+	b.logger.Info("unbound-application-security-group", lager.Data{instanceIDLogKey: instanceID, "space-guid": app.SpaceGUID})
+
+	return nil
+}
+
+func (b *ElastiCacheBroker) applicationSecurityGroupRules(cacheClusterDetails awselasticache.CacheClusterDetails) ([]cloudcontroller.SecurityGroupRule, error) {
+	destinations := make(map[string]string)
+	for _, cacheNode := range cacheClusterDetails.CacheNodes {
+		if cacheNode.Address == "" {
+			continue
+		}
+
+		IPs, err := lookupHost(cacheNode.Address)
+		if err != nil {
+			return nil, fmt.Errorf("Resolving cache node '%s' address: %s", cacheNode.CacheNodeId, err)
+		}
+
+		for _, IP := range IPs {
+			destinations[IP] = strconv.FormatInt(cacheNode.Port, 10)
+		}
+	}
+
+	if len(destinations) == 0 {
+		return nil, fmt.Errorf("Cache Cluster Instance '%s' has no cache node endpoints", cacheClusterDetails.CacheClusterId)
+	}
+
+	IPs := make([]string, 0, len(destinations))
+	for IP := range destinations {
+		IPs = append(IPs, IP)
+	}
+	sort.Strings(IPs)
+
+	var rules []cloudcontroller.SecurityGroupRule
+	for _, IP := range IPs {
+		rules = append(rules, cloudcontroller.SecurityGroupRule{
+			Protocol:    "tcp",
+			Destination: IP,
+			Ports:       destinations[IP],
+		})
+	}
+
+	return rules, nil
+}
+
+func (b *ElastiCacheBroker) applicationSecurityGroupName(instanceID string) string {
+	return fmt.Sprintf("%s-%s", b.cachePrefix, instanceID)
+}
@@ -0,0 +1,153 @@
+package broker_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/frodenas/brokerapi"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	cwfakes "github.com/cloudfoundry-community/elasticache-broker/awscloudwatch/fakes"
+	ec2fakes "github.com/cloudfoundry-community/elasticache-broker/awsec2/fakes"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache/fakes"
+	. "github.com/cloudfoundry-community/elasticache-broker/broker"
+	"github.com/cloudfoundry-community/elasticache-broker/cloudcontroller"
+	ccfakes "github.com/cloudfoundry-community/elasticache-broker/cloudcontroller/fakes"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+var _ = Describe("Application Security Groups", func() {
+	const instanceID = "ce71b484-d542-40f7-9dd4-5526e38c81ba"
+
+	var (
+		cacheCluster      *fakes.FakeCacheCluster
+		cloudController   *ccfakes.FakeClient
+		brokerStore       store.Store
+		elastiCacheBroker *ElastiCacheBroker
+
+		bindDetails brokerapi.BindDetails
+	)
+
+	BeforeEach(func() {
+		cacheCluster = &fakes.FakeCacheCluster{
+			DescribeCacheClusterDetails: awselasticache.CacheClusterDetails{
+				CacheClusterId: "cf-instance",
+				Endpoint:       "10.0.0.1",
+				Port:           6379,
+				CacheNodes: []awselasticache.CacheNodeDetails{
+					awselasticache.CacheNodeDetails{CacheNodeId: "0001", Address: "10.0.0.2", Port: 6379},
+					awselasticache.CacheNodeDetails{CacheNodeId: "0002", Address: "10.0.0.1", Port: 6379},
+				},
+			},
+		}
+		cloudController = &ccfakes.FakeClient{
+			GetAppApp:              cloudcontroller.App{GUID: "app-guid", SpaceGUID: "space-guid"},
+			FindSecurityGroupError: cloudcontroller.ErrResourceNotFound,
+		}
+
+		config := Config{
+			CachePrefix:                     "cf",
+			ManageApplicationSecurityGroups: true,
+			Catalog: Catalog{
+				Services: []Service{
+					Service{ID: "Service-1", Bindable: true, Plans: []ServicePlan{ServicePlan{ID: "Plan-1"}}},
+				},
+			},
+		}
+		brokerStore = store.NewMemoryStore()
+		elastiCacheBroker = New(config, cacheCluster, &fakes.FakeCacheSubnetGroup{}, &ec2fakes.FakeSecurityGroup{}, &cwfakes.FakeCacheClusterMetrics{}, cloudController, brokerStore, lagertest.NewTestLogger("broker_test"))
+
+		bindDetails = brokerapi.BindDetails{ServiceID: "Service-1", PlanID: "Plan-1", AppGUID: "app-guid"}
+	})
+
+	Describe("Bind", func() {
+		It("creates a space-scoped security group allowing egress to the cache nodes", func() {
+			_, err := elastiCacheBroker.Bind(instanceID, "binding-id", bindDetails)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(cloudController.GetAppGUID).To(Equal("app-guid"))
+			Expect(cloudController.FindSecurityGroupName).To(Equal("cf-" + instanceID))
+			Expect(cloudController.CreateSecurityGroupSecurityGroup).To(Equal(cloudcontroller.SecurityGroup{
+				Name: "cf-" + instanceID,
+				Rules: []cloudcontroller.SecurityGroupRule{
+					cloudcontroller.SecurityGroupRule{Protocol: "tcp", Destination: "10.0.0.1", Ports: "6379"},
+					cloudcontroller.SecurityGroupRule{Protocol: "tcp", Destination: "10.0.0.2", Ports: "6379"},
+				},
+				SpaceGUIDs: []string{"space-guid"},
+			}))
+		})
+
+		It("updates an existing security group and binds it to the app space", func() {
+			cloudController.FindSecurityGroupError = nil
+			cloudController.FindSecurityGroupSecurityGroup = cloudcontroller.SecurityGroup{GUID: "sg-guid", Name: "cf-" + instanceID}
+
+			_, err := elastiCacheBroker.Bind(instanceID, "binding-id", bindDetails)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(cloudController.CreateSecurityGroupCalled).To(BeFalse())
+			Expect(cloudController.UpdateSecurityGroupSecurityGroup.Rules).To(HaveLen(2))
+			Expect(cloudController.BindSecurityGroupToSpaceSecurityGroupGUID).To(Equal("sg-guid"))
+			Expect(cloudController.BindSecurityGroupToSpaceSpaceGUID).To(Equal("space-guid"))
+		})
+
+		It("does not manage security groups for bindings without an app", func() {
+			bindDetails.AppGUID = ""
+
+			_, err := elastiCacheBroker.Bind(instanceID, "binding-id", bindDetails)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cloudController.GetAppCalled).To(BeFalse())
+		})
+	})
+
+	Describe("Unbind", func() {
+		BeforeEach(func() {
+			cloudController.FindSecurityGroupError = nil
+			cloudController.FindSecurityGroupSecurityGroup = cloudcontroller.SecurityGroup{GUID: "sg-guid", Name: "cf-" + instanceID}
+			Expect(brokerStore.PutBinding(store.Binding{ID: "binding-id", InstanceID: instanceID, AppGUID: "app-guid"})).To(Succeed())
+		})
+
+		It("deletes the security group when no bindings remain", func() {
+			cloudController.ListServiceBindingsServiceBindings = []cloudcontroller.ServiceBinding{
+				cloudcontroller.ServiceBinding{GUID: "binding-id"},
+			}
+
+			err := elastiCacheBroker.Unbind(instanceID, "binding-id", brokerapi.UnbindDetails{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cloudController.DeleteSecurityGroupGUID).To(Equal("sg-guid"))
+		})
+
+		It("keeps the security group while other bindings remain", func() {
+			cloudController.ListServiceBindingsServiceBindings = []cloudcontroller.ServiceBinding{
+				cloudcontroller.ServiceBinding{GUID: "binding-id", AppGUID: "app-guid"},
+				cloudcontroller.ServiceBinding{GUID: "other-binding-id", AppGUID: "other-app-guid"},
+			}
+
+			err := elastiCacheBroker.Unbind(instanceID, "binding-id", brokerapi.UnbindDetails{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cloudController.DeleteSecurityGroupCalled).To(BeFalse())
+			Expect(cloudController.UnbindSecurityGroupFromSpaceCalled).To(BeFalse())
+		})
+
+		It("unbinds the security group from a space once its last binding is removed", func() {
+			cloudController.GetAppApps = map[string]cloudcontroller.App{
+				"other-app-guid": cloudcontroller.App{GUID: "other-app-guid", SpaceGUID: "other-space-guid"},
+			}
+			cloudController.ListServiceBindingsServiceBindings = []cloudcontroller.ServiceBinding{
+				cloudcontroller.ServiceBinding{GUID: "binding-id", AppGUID: "app-guid"},
+				cloudcontroller.ServiceBinding{GUID: "other-binding-id", AppGUID: "other-app-guid"},
+			}
+
+			err := elastiCacheBroker.Unbind(instanceID, "binding-id", brokerapi.UnbindDetails{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cloudController.DeleteSecurityGroupCalled).To(BeFalse())
+			Expect(cloudController.UnbindSecurityGroupFromSpaceSecurityGroupGUID).To(Equal("sg-guid"))
+			Expect(cloudController.UnbindSecurityGroupFromSpaceSpaceGUID).To(Equal("space-guid"))
+		})
+
+		It("returns ErrBindingDoesNotExist when the binding is not recorded", func() {
+			err := elastiCacheBroker.Unbind(instanceID, "other-binding-id", brokerapi.UnbindDetails{})
+			Expect(err).To(Equal(brokerapi.ErrBindingDoesNotExist))
+		})
+	})
+})
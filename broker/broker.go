@@ -2,16 +2,22 @@ package broker
 
 import (
 	"encoding/json"
-//	"errors"
+	"errors"
 	"fmt"
-	"strings"
+	"sort"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/frodenas/brokerapi"
-	"github.com/mitchellh/mapstructure"
 	"github.com/pivotal-golang/lager"
 
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+	"github.com/cloudfoundry-community/elasticache-broker/awscloudwatch"
+	"github.com/cloudfoundry-community/elasticache-broker/awsec2"
 	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/cloudcontroller"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+	"github.com/cloudfoundry-community/elasticache-broker/workflow"
 )
 
 const instanceIDLogKey = "instance-id"
@@ -19,6 +25,11 @@ const bindingIDLogKey = "binding-id"
 const detailsLogKey = "details"
 const acceptsIncompleteLogKey = "acceptsIncomplete"
 
+var (
+	ErrUserProvisionParametersNotAllowed = errors.New("User provision parameters are not allowed")
+	ErrUserUpdateParametersNotAllowed    = errors.New("User update parameters are not allowed")
+)
+
 var elastiCacheStatus2State = map[string]string{
 	"available":                      brokerapi.LastOperationSucceeded,
 	"backing-up":                     brokerapi.LastOperationInProgress,
@@ -26,35 +37,82 @@ var elastiCacheStatus2State = map[string]string{
 	"deleting":                       brokerapi.LastOperationInProgress,
 	"deleted":                        brokerapi.LastOperationInProgress,
 	"incompatible-network":           brokerapi.LastOperationInProgress,
-	"modifying":                    	brokerapi.LastOperationInProgress,
+	"modifying":                      brokerapi.LastOperationInProgress,
 	"rebooting cache cluster nodes,": brokerapi.LastOperationInProgress,
 	"restore-failed":                 brokerapi.LastOperationInProgress,
-	"snapshotting": 									brokerapi.LastOperationInProgress,
+	"snapshotting":                   brokerapi.LastOperationInProgress,
 }
 
 type ElastiCacheBroker struct {
-	cachePrefix                  string
-	allowUserProvisionParameters bool
-	allowUserUpdateParameters    bool
-	allowUserBindParameters      bool
-	catalog                      Catalog
-	cacheCluster                 awselasticache.CacheCluster
-	logger                       lager.Logger
+	cachePrefix                     string
+	brokerID                        string
+	allowUserProvisionParameters    bool
+	allowUserUpdateParameters       bool
+	allowUserBindParameters         bool
+	catalog                         Catalog
+	cacheCluster                    awselasticache.CacheCluster
+	cacheSubnetGroup                awselasticache.CacheSubnetGroup
+	securityGroup                   awsec2.SecurityGroup
+	cacheClusterMetrics             awscloudwatch.CacheClusterMetrics
+	cloudController                 cloudcontroller.Client
+	store                           store.Store
+	workflows                       *workflow.Engine
+	manageApplicationSecurityGroups bool
+	costAllocationTags              map[string]string
+	allowedUserTagKeys              []string
+	allowedUnownedCacheClusterIDs   []string
+	pendingDeleteInterval           time.Duration
+	logger                          lager.Logger
 }
 
 func New(
 	config Config,
 	cacheCluster awselasticache.CacheCluster,
+	cacheSubnetGroup awselasticache.CacheSubnetGroup,
+	securityGroup awsec2.SecurityGroup,
+	cacheClusterMetrics awscloudwatch.CacheClusterMetrics,
+	cloudController cloudcontroller.Client,
+	stateStore store.Store,
 	logger lager.Logger,
 ) *ElastiCacheBroker {
-	return &ElastiCacheBroker{
-		cachePrefix:                  config.CachePrefix,
-		allowUserProvisionParameters: config.AllowUserProvisionParameters,
-		allowUserUpdateParameters:    config.AllowUserUpdateParameters,
-		catalog:                      config.Catalog,
-		cacheCluster:                 cacheCluster,
-		logger:                       logger.Session("broker"),
+	brokerID := config.BrokerID
+	if brokerID == "" {
+		brokerID = config.CachePrefix
+	}
+
+	pendingDeleteInterval := defaultPendingDeleteInterval
+	if config.PendingDeleteInterval > 0 {
+		pendingDeleteInterval = time.Duration(config.PendingDeleteInterval) * time.Second
+	}
+
+	broker := &ElastiCacheBroker{
+		cachePrefix:                     config.CachePrefix,
+		brokerID:                        brokerID,
+		allowUserProvisionParameters:    config.AllowUserProvisionParameters,
+		allowUserUpdateParameters:       config.AllowUserUpdateParameters,
+		catalog:                         config.Catalog,
+		cacheCluster:                    cacheCluster,
+		cacheSubnetGroup:                cacheSubnetGroup,
+		securityGroup:                   securityGroup,
+		cacheClusterMetrics:             cacheClusterMetrics,
+		cloudController:                 cloudController,
+		store:                           stateStore,
+		manageApplicationSecurityGroups: config.ManageApplicationSecurityGroups,
+		costAllocationTags:              config.CostAllocationTags,
+		allowedUserTagKeys:              config.AllowedUserTagKeys,
+		allowedUnownedCacheClusterIDs:   config.AllowedUnownedCacheClusterIDs,
+		pendingDeleteInterval:           pendingDeleteInterval,
+		logger:                          logger.Session("broker"),
+	}
+
+	broker.workflows = workflow.NewEngine(stateStore, broker.logger)
+	broker.workflows.Register(provisionWorkflow, broker.provisionSteps)
+
+	if !stateStore.Durable() {
+		broker.logger.Info("non-durable-store", lager.Data{"warning": "deprovisions of service instances whose cache cluster is being created are rejected with a 422 ConcurrencyError error: configure a file or sql store to defer them"})
 	}
+
+	return broker
 }
 
 func (b *ElastiCacheBroker) Services() brokerapi.CatalogResponse {
@@ -89,21 +147,64 @@ func (b *ElastiCacheBroker) Provision(instanceID string, details brokerapi.Provi
 		return provisioningResponse, false, brokerapi.ErrAsyncRequired
 	}
 
+	servicePlan, ok := b.catalog.FindServicePlan(details.PlanID)
+	if !ok {
+		return provisioningResponse, false, fmt.Errorf("Service Plan '%s' not found", details.PlanID)
+	}
+
 	provisionParameters := ProvisionParameters{}
-	if b.allowUserProvisionParameters {
-		if err := mapstructure.Decode(details.Parameters, &provisionParameters); err != nil {
+	if len(details.Parameters) > 0 {
+		if !b.allowUserProvisionParameters {
+			return provisioningResponse, false, api.NewBadRequestResponse(ErrUserProvisionParametersNotAllowed, "invalid-parameters")
+		}
+		if err := decodeParameters(details.Parameters, provisionParameterDefinitions, servicePlan, &provisionParameters); err != nil {
 			return provisioningResponse, false, err
 		}
 	}
 
-	servicePlan, ok := b.catalog.FindServicePlan(details.PlanID)
-	if !ok {
-		return provisioningResponse, false, fmt.Errorf("Service Plan '%s' not found", details.PlanID)
+	if err := validateParameters(b.planSchemas(servicePlan).ServiceInstance.Create, details.Parameters); err != nil {
+		return provisioningResponse, false, err
 	}
 
-	var err error
-	instance := b.createCacheCluster(instanceID, servicePlan, provisionParameters, details)
-	if err = b.cacheCluster.Create(b.cacheClusterIdentifier(instanceID), *instance); err != nil {
+	if err := b.validateUserTags(provisionParameters.Tags); err != nil {
+		return provisioningResponse, false, err
+	}
+
+	exists, inProgress, err := b.existingInstance(instanceID, details)
+	if err != nil {
+		return provisioningResponse, false, err
+	}
+	if exists {
+		if inProgress {
+			return provisioningResponse, true, nil
+		}
+		return provisioningResponse, false, api.ErrInstanceAlreadyProvisioned
+	}
+
+	if provisionParameters.AdoptClusterID != "" {
+		return provisioningResponse, false, b.adoptCacheCluster(instanceID, servicePlan, provisionParameters, details)
+	}
+
+	if provisionParameters.KeepCluster != nil {
+		return provisioningResponse, false, api.NewBadRequestResponse(errors.New("Parameter 'keep_cluster' requires 'adopt_cluster_id'"), "invalid-parameters")
+	}
+
+	err = b.lockInstance(instanceID, "", store.OperationProvision, func(instance *store.Instance) {
+		instance.ServiceID = details.ServiceID
+		instance.PlanID = details.PlanID
+		instance.OrganizationID = details.OrganizationGUID
+		instance.SpaceID = details.SpaceGUID
+		instance.CacheClusterID = b.cacheClusterIdentifier(instanceID)
+		instance.Parameters = details.Parameters
+	})
+	if err != nil {
+		return provisioningResponse, false, err
+	}
+
+	if err = b.workflows.Start(instanceID, provisionWorkflow); err != nil {
+		if failErr := b.failProvision(instanceID, fmt.Sprintf("Provision workflow could not be started: %s", err)); failErr != nil {
+			b.logger.Error("fail-provision", failErr, lager.Data{instanceIDLogKey: instanceID})
+		}
 		return provisioningResponse, false, err
 	}
 
@@ -121,35 +222,105 @@ func (b *ElastiCacheBroker) Update(instanceID string, details brokerapi.UpdateDe
 		return false, brokerapi.ErrAsyncRequired
 	}
 
+	service, ok := b.catalog.FindService(details.ServiceID)
+	if !ok {
+		return false, fmt.Errorf("Service '%s' not found", details.ServiceID)
+	}
+
+	servicePlan, ok := b.catalog.FindServicePlan(details.PlanID)
+	if !ok {
+		return false, fmt.Errorf("Service Plan '%s' not found", details.PlanID)
+	}
+
+	previousPlanID, err := b.previousPlanID(instanceID, details)
+	if err != nil {
+		return false, err
+	}
+
+	previousPlan := servicePlan
+	if previousPlanID != "" && previousPlanID != details.PlanID {
+		if previousPlan, ok = b.catalog.FindServicePlan(previousPlanID); !ok {
+			return false, fmt.Errorf("Service Plan '%s' not found", previousPlanID)
+		}
+	}
+
+	if !previousPlan.IsUpdateable(service) {
+		return false, brokerapi.ErrInstanceNotUpdateable
+	}
+
 	updateParameters := UpdateParameters{}
-	if b.allowUserUpdateParameters {
-		if err := mapstructure.Decode(details.Parameters, &updateParameters); err != nil {
+	if len(details.Parameters) > 0 {
+		if !b.allowUserUpdateParameters {
+			return false, api.NewBadRequestResponse(ErrUserUpdateParametersNotAllowed, "invalid-parameters")
+		}
+		if err := decodeParameters(details.Parameters, updateParameterDefinitions, servicePlan, &updateParameters); err != nil {
 			return false, err
 		}
 	}
 
-	service, ok := b.catalog.FindService(details.ServiceID)
-	if !ok {
-		return false, fmt.Errorf("Service '%s' not found", details.ServiceID)
+	if err := validateParameters(b.planSchemas(servicePlan).ServiceInstance.Update, details.Parameters); err != nil {
+		return false, err
 	}
 
-	if !service.PlanUpdateable {
-		return false, brokerapi.ErrInstanceNotUpdateable
+	if err := b.validateUserTags(updateParameters.Tags); err != nil {
+		return false, err
 	}
 
-	servicePlan, ok := b.catalog.FindServicePlan(details.PlanID)
-	if !ok {
-		return false, fmt.Errorf("Service Plan '%s' not found", details.PlanID)
+	cacheClusterID, cacheClusterDetails, err := b.recoverCacheCluster(instanceID)
+	if err != nil {
+		if err == awselasticache.ErrCacheClusterDoesNotExist {
+			return false, brokerapi.ErrInstanceDoesNotExist
+		}
+		return false, err
 	}
 
-	instance := b.modifyCacheCluster(instanceID, servicePlan, updateParameters, details)
-	if err := b.cacheCluster.Modify(b.cacheClusterIdentifier(instanceID), *instance, updateParameters.ApplyImmediately); err != nil {
+	if previousPlan.ID != servicePlan.ID {
+		if err = b.checkPlanUpdate(cacheClusterID, cacheClusterDetails, previousPlan, servicePlan); err != nil {
+			return false, err
+		}
+	}
+
+	currentTags, err := b.cacheCluster.ListTags(cacheClusterID)
+	if err != nil {
 		if err == awselasticache.ErrCacheClusterDoesNotExist {
 			return false, brokerapi.ErrInstanceDoesNotExist
 		}
 		return false, err
 	}
 
+	if err = b.checkOwnership(instanceID, cacheClusterID, currentTags); err != nil {
+		return false, err
+	}
+
+	if err = b.lockInstance(instanceID, cacheClusterDetails.Status, store.OperationUpdate, nil); err != nil {
+		return false, err
+	}
+
+	instance := b.modifyCacheCluster(instanceID, servicePlan, cacheClusterDetails, updateParameters, details, currentTags)
+	if err = b.cacheCluster.Modify(cacheClusterID, *instance, updateParameters.ApplyImmediately); err != nil {
+		b.unlockInstance(instanceID)
+		if err == awselasticache.ErrCacheClusterDoesNotExist {
+			return false, brokerapi.ErrInstanceDoesNotExist
+		}
+		return false, err
+	}
+
+	err = b.storeInstance(instanceID, func(instance *store.Instance) {
+		instance.ServiceID = details.ServiceID
+		instance.PlanID = details.PlanID
+		if instance.OrganizationID == "" {
+			instance.OrganizationID = details.PreviousValues.OrganizationID
+		}
+		if instance.SpaceID == "" {
+			instance.SpaceID = details.PreviousValues.SpaceID
+		}
+		instance.CacheClusterID = cacheClusterID
+		instance.Parameters = mergeParameters(instance.Parameters, details.Parameters)
+	})
+	if err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
 
@@ -164,13 +335,51 @@ func (b *ElastiCacheBroker) Deprovision(instanceID string, details brokerapi.Dep
 		return false, brokerapi.ErrAsyncRequired
 	}
 
-	if err := b.cacheCluster.Delete(b.cacheClusterIdentifier(instanceID)); err != nil {
+	if workflowName := b.runningWorkflow(instanceID); workflowName != "" {
+		err := fmt.Errorf("Cannot deprovision service instance '%s': %s workflow in progress", instanceID, workflowName)
+		return false, api.NewConcurrencyErrorResponse(err, "concurrent-operation")
+	}
+
+	asyncDeprovision := false
+	cacheClusterID, cacheClusterDetails, err := b.recoverCacheCluster(instanceID)
+	if err == nil {
+		if cacheClusterDetails.Status == cacheClusterStatusDeleting {
+			asyncDeprovision = true
+		} else if cacheClusterDetails.Status == cacheClusterStatusCreating || b.isDeletePending(instanceID) {
+			if err = b.scheduleDelete(instanceID, cacheClusterID); err == nil {
+				return true, nil
+			}
+		} else if err = b.lockInstance(instanceID, cacheClusterDetails.Status, store.OperationDeprovision, nil); err == nil {
+			if asyncDeprovision, err = b.deleteCacheCluster(instanceID, cacheClusterID); err != nil {
+				b.unlockInstance(instanceID)
+			}
+		}
+	}
+	if err != nil {
 		if err == awselasticache.ErrCacheClusterDoesNotExist {
+			if err = b.deleteInstanceSecurityGroup(instanceID); err != nil && err != awsec2.ErrSecurityGroupInUse {
+				return false, err
+			}
+			if err = b.forgetInstance(instanceID); err != nil {
+				return false, err
+			}
 			return false, brokerapi.ErrInstanceDoesNotExist
 		}
 		return false, err
 	}
 
+	if !asyncDeprovision {
+		return false, b.forgetInstance(instanceID)
+	}
+
+	err = b.storeInstance(instanceID, func(instance *store.Instance) {
+		instance.CacheClusterID = cacheClusterID
+		startOperation(instance, store.OperationDeprovision)
+	})
+	if err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
 
@@ -188,14 +397,28 @@ func (b *ElastiCacheBroker) Bind(instanceID, bindingID string, details brokerapi
 		return bindingResponse, fmt.Errorf("Service '%s' not found", details.ServiceID)
 	}
 
-	if !service.Bindable {
+	servicePlan, ok := b.catalog.FindServicePlan(details.PlanID)
+	if !ok {
+		return bindingResponse, fmt.Errorf("Service Plan '%s' not found", details.PlanID)
+	}
+
+	if !servicePlan.IsBindable(service) {
 		return bindingResponse, brokerapi.ErrInstanceNotBindable
 	}
 
+	if err := validateParameters(b.planSchemas(servicePlan).ServiceBinding.Create, details.Parameters); err != nil {
+		return bindingResponse, err
+	}
+
+	bound, err := b.existingBinding(instanceID, bindingID, details)
+	if err != nil {
+		return bindingResponse, err
+	}
+
 	var cacheEndpoint string
 	var cachePort int64
 
-	cacheClusterDetails, err := b.cacheCluster.Describe(b.cacheClusterIdentifier(instanceID))
+	cacheClusterID, cacheClusterDetails, err := b.describeCacheCluster(instanceID)
 	if err != nil {
 		if err == awselasticache.ErrCacheClusterDoesNotExist {
 			return bindingResponse, brokerapi.ErrInstanceDoesNotExist
@@ -203,13 +426,35 @@ func (b *ElastiCacheBroker) Bind(instanceID, bindingID string, details brokerapi
 		return bindingResponse, err
 	}
 
+	if b.manageApplicationSecurityGroups && details.AppGUID != "" {
+		if err = b.ensureApplicationSecurityGroup(instanceID, details.AppGUID, cacheClusterDetails); err != nil {
+			return bindingResponse, err
+		}
+	}
+
 	cacheEndpoint = cacheClusterDetails.Endpoint
 	cachePort = cacheClusterDetails.Port
 
 	bindingResponse.Credentials = &brokerapi.CredentialsHash{
-		Host:     cacheEndpoint,
-		Port:     cachePort,
-		Name:     b.cacheClusterIdentifier(instanceID),
+		Host: cacheEndpoint,
+		Port: cachePort,
+		Name: cacheClusterID,
+	}
+
+	if bound {
+		return bindingResponse, api.ErrBindingAlreadyBound
+	}
+
+	binding := store.Binding{
+		ID:          bindingID,
+		InstanceID:  instanceID,
+		AppGUID:     details.AppGUID,
+		Parameters:  details.Parameters,
+		Credentials: &store.Credentials{Host: cacheEndpoint, Port: cachePort, Name: cacheClusterID},
+		CreatedAt:   time.Now(),
+	}
+	if err = b.store.PutBinding(binding); err != nil {
+		return bindingResponse, err
 	}
 
 	return bindingResponse, nil
@@ -222,6 +467,19 @@ func (b *ElastiCacheBroker) Unbind(instanceID, bindingID string, details brokera
 		detailsLogKey:    details,
 	})
 
+	if b.manageApplicationSecurityGroups {
+		if err := b.releaseApplicationSecurityGroup(instanceID, bindingID); err != nil {
+			return err
+		}
+	}
+
+	if err := b.store.DeleteBinding(instanceID, bindingID); err != nil {
+		if err == store.ErrBindingNotFound {
+			return brokerapi.ErrBindingDoesNotExist
+		}
+		return err
+	}
+
 	return nil
 }
 
@@ -232,49 +490,201 @@ func (b *ElastiCacheBroker) LastOperation(instanceID string) (brokerapi.LastOper
 
 	lastOperationResponse := brokerapi.LastOperationResponse{State: brokerapi.LastOperationFailed}
 
-	cacheClusterDetails, err := b.cacheCluster.Describe(b.cacheClusterIdentifier(instanceID))
+	if instance, err := b.store.GetInstance(instanceID); err == nil && instance.Failure != nil && instance.Operation == nil {
+		if !instance.Failure.CleanedUp {
+			if err = b.deleteFailedProvisionResources(instanceID); err != nil {
+				return lastOperationResponse, err
+			}
+		}
+		lastOperationResponse.Description = instance.Failure.Reason
+		return lastOperationResponse, nil
+	}
+
+	if workflowResponse, ok := b.workflowProgress(instanceID); ok {
+		if workflowResponse.State == brokerapi.LastOperationFailed {
+			if err := b.failProvision(instanceID, workflowResponse.Description); err != nil {
+				return workflowResponse, err
+			}
+		}
+		return workflowResponse, nil
+	}
+
+	cacheClusterID, cacheClusterDetails, err := b.describeCacheCluster(instanceID)
 	if err != nil {
 		if err == awselasticache.ErrCacheClusterDoesNotExist {
+			if err = b.deleteInstanceSecurityGroup(instanceID); err != nil {
+				if err == awsec2.ErrSecurityGroupInUse {
+					lastOperationResponse.State = brokerapi.LastOperationInProgress
+					lastOperationResponse.Description = fmt.Sprintf("Waiting for Cache Cluster Instance '%s' security group to be released", cacheClusterID)
+					return lastOperationResponse, nil
+				}
+				return lastOperationResponse, err
+			}
+			if err = b.forgetInstance(instanceID); err != nil {
+				return lastOperationResponse, err
+			}
 			return lastOperationResponse, brokerapi.ErrInstanceDoesNotExist
 		}
 		return lastOperationResponse, err
 	}
 
-	lastOperationResponse.Description = fmt.Sprintf("Cache Cluster Instance '%s' status is '%s'", b.cacheClusterIdentifier(instanceID), cacheClusterDetails.Status)
+	lastOperationResponse.Description = fmt.Sprintf("Cache Cluster Instance '%s' status is '%s'", cacheClusterID, cacheClusterDetails.Status)
+
+	if b.isProvisioning(instanceID) {
+		if reason, failed := failedProvisionReason(cacheClusterID, cacheClusterDetails); failed {
+			if err = b.failProvision(instanceID, reason); err != nil {
+				return lastOperationResponse, err
+			}
+			lastOperationResponse.Description = reason
+			return lastOperationResponse, nil
+		}
+	}
+
+	if state := elastiCacheStatus2State[cacheClusterDetails.Status]; state == brokerapi.LastOperationInProgress || b.isDeletePending(instanceID) {
+		description, timedOut, err := b.timedOutOperation(instanceID, cacheClusterID, cacheClusterDetails)
+		if err != nil {
+			return lastOperationResponse, err
+		}
+		if timedOut {
+			lastOperationResponse.Description = description
+			return lastOperationResponse, nil
+		}
+	}
+
+	if b.isDeletePending(instanceID) {
+		lastOperationResponse.State = brokerapi.LastOperationInProgress
+		lastOperationResponse.Description = fmt.Sprintf("Cache Cluster Instance '%s' status is '%s', waiting to delete it", cacheClusterID, cacheClusterDetails.Status)
+		return lastOperationResponse, nil
+	}
 
 	if state, ok := elastiCacheStatus2State[cacheClusterDetails.Status]; ok {
 		lastOperationResponse.State = state
 	}
 
-//	if lastOperationResponse.State == brokerapi.LastOperationSucceeded && cacheClusterDetails.PendingModifications {
-//		lastOperationResponse.State = brokerapi.LastOperationInProgress
-//		lastOperationResponse.Description = fmt.Sprintf("Cache Cluster Instance '%s' has pending modifications", b.cacheClusterIdentifier(instanceID))
-//	}
+	if lastOperationResponse.State != brokerapi.LastOperationInProgress {
+		if err = b.finishOperation(instanceID); err != nil {
+			return lastOperationResponse, err
+		}
+	}
 
-	return lastOperationResponse, nil
-}
+	//	if lastOperationResponse.State == brokerapi.LastOperationSucceeded && cacheClusterDetails.PendingModifications {
+	//		lastOperationResponse.State = brokerapi.LastOperationInProgress
+	//		lastOperationResponse.Description = fmt.Sprintf("Cache Cluster Instance '%s' has pending modifications", cacheClusterID)
+	//	}
 
-func (b *ElastiCacheBroker) cacheClusterIdentifier(instanceID string) string {
-	id := fmt.Sprintf("%s-%s", b.cachePrefix, strings.Replace(instanceID, "-", "", -1))[:20]
-	return id
+	return lastOperationResponse, nil
 }
 
 func (b *ElastiCacheBroker) createCacheCluster(instanceID string, servicePlan ServicePlan, provisionParameters ProvisionParameters, details brokerapi.ProvisionDetails) *awselasticache.CacheClusterDetails {
 	cacheClusterDetails := b.cacheClusterFromPlan(servicePlan)
 
+	if provisionParameters.CacheNodeType != "" {
+		cacheClusterDetails.CacheInstanceClass = provisionParameters.CacheNodeType
+	}
+	if provisionParameters.EngineVersion != "" {
+		cacheClusterDetails.EngineVersion = provisionParameters.EngineVersion
+	}
+	if provisionParameters.NumCacheNodes > 0 {
+		cacheClusterDetails.NumCacheNodes = provisionParameters.NumCacheNodes
+	}
+	if provisionParameters.Port > 0 {
+		cacheClusterDetails.Port = provisionParameters.Port
+	}
+	if provisionParameters.PreferredAvailabilityZone != "" {
+		cacheClusterDetails.PreferredAvailabilityZone = provisionParameters.PreferredAvailabilityZone
+	}
+	if provisionParameters.PreferredMaintenanceWindow != "" {
+		cacheClusterDetails.PreferredMaintenanceWindow = provisionParameters.PreferredMaintenanceWindow
+	}
+	if provisionParameters.SnapshotName != "" {
+		cacheClusterDetails.SnapshotName = provisionParameters.SnapshotName
+	}
+	if provisionParameters.SnapshotRetentionLimit != nil {
+		cacheClusterDetails.SnapshotRetentionLimit = provisionParameters.SnapshotRetentionLimit
+	}
+	if provisionParameters.SnapshotWindow != "" {
+		cacheClusterDetails.SnapshotWindow = provisionParameters.SnapshotWindow
+	}
+	if provisionParameters.AutoMinorVersionUpgrade != nil {
+		cacheClusterDetails.AutoMinorVersionUpgrade = provisionParameters.AutoMinorVersionUpgrade
+	}
 
-	cacheClusterDetails.Tags = b.cacheTags("Created", details.ServiceID, details.PlanID, details.OrganizationGUID, details.SpaceGUID)
+	cacheClusterDetails.Tags = make(map[string]string)
+	for key, value := range provisionParameters.Tags {
+		cacheClusterDetails.Tags[key] = value
+	}
+	for key, value := range b.cacheTags("Created", details.ServiceID, details.PlanID, details.OrganizationGUID, details.SpaceGUID) {
+		cacheClusterDetails.Tags[key] = value
+	}
+	for key, value := range b.ownershipTags(instanceID) {
+		cacheClusterDetails.Tags[key] = value
+	}
 	return cacheClusterDetails
 }
 
-func (b *ElastiCacheBroker) modifyCacheCluster(instanceID string, servicePlan ServicePlan, updateParameters UpdateParameters, details brokerapi.UpdateDetails) *awselasticache.CacheClusterDetails {
-	cacheClusterDetails := b.cacheClusterFromPlan(servicePlan)
+// deleteCacheCluster deletes the cache cluster of a service instance, or releases it if it
+// is an adopted cache cluster to be kept. It reports whether the deletion is asynchronous.
+func (b *ElastiCacheBroker) deleteCacheCluster(instanceID string, cacheClusterID string) (bool, error) {
+	tags, err := b.cacheCluster.ListTags(cacheClusterID)
+	if err != nil {
+		return false, err
+	}
+
+	if err = b.checkOwnership(instanceID, cacheClusterID, tags); err != nil {
+		return false, err
+	}
+
+	if tags[keepClusterTagKey] == "true" {
+		return false, b.releaseCacheCluster(instanceID, cacheClusterID, tags)
+	}
+
+	if err = b.cacheCluster.Delete(cacheClusterID); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (b *ElastiCacheBroker) modifyCacheCluster(instanceID string, servicePlan ServicePlan, currentDetails awselasticache.CacheClusterDetails, updateParameters UpdateParameters, details brokerapi.UpdateDetails, currentTags map[string]string) *awselasticache.CacheClusterDetails {
+	cacheClusterDetails := &awselasticache.CacheClusterDetails{
+		EngineVersion:              updateParameters.EngineVersion,
+		PreferredMaintenanceWindow: updateParameters.PreferredMaintenanceWindow,
+		SnapshotRetentionLimit:     updateParameters.SnapshotRetentionLimit,
+		SnapshotWindow:             updateParameters.SnapshotWindow,
+		AutoMinorVersionUpgrade:    updateParameters.AutoMinorVersionUpgrade,
+	}
 
+	// Node type and number of nodes follow the plan, as they do when creating the cache cluster.
+	if cacheInstanceClass := servicePlan.ElastiCacheProperties.CacheInstanceClass; cacheInstanceClass != "" && cacheInstanceClass != currentDetails.CacheInstanceClass {
+		cacheClusterDetails.CacheInstanceClass = cacheInstanceClass
+	}
+	if numCacheNodes := servicePlan.ElastiCacheProperties.NumCacheNodes; numCacheNodes > 0 && numCacheNodes != currentDetails.NumCacheNodes {
+		cacheClusterDetails.NumCacheNodes = numCacheNodes
+		cacheClusterDetails.CacheNodeIdsToRemove = cacheNodeIDsToRemove(currentDetails.CacheNodes, numCacheNodes)
+	}
 
-	cacheClusterDetails.Tags = b.cacheTags("Updated", details.ServiceID, details.PlanID, "", "")
+	brokerTags := b.cacheTags("Updated", details.ServiceID, details.PlanID, details.PreviousValues.OrganizationID, details.PreviousValues.SpaceID)
+	for key, value := range b.ownershipTags(instanceID) {
+		brokerTags[key] = value
+	}
+	cacheClusterDetails.Tags = b.desiredTags(currentTags, brokerTags, updateParameters.Tags)
 	return cacheClusterDetails
 }
 
+// cacheNodeIDsToRemove returns the most recently added cache nodes exceeding numCacheNodes.
+func cacheNodeIDsToRemove(cacheNodes []awselasticache.CacheNodeDetails, numCacheNodes int64) []string {
+	var cacheNodeIDs []string
+	for _, cacheNode := range cacheNodes {
+		cacheNodeIDs = append(cacheNodeIDs, cacheNode.CacheNodeId)
+	}
+	if int64(len(cacheNodeIDs)) <= numCacheNodes {
+		return nil
+	}
+	sort.Strings(cacheNodeIDs)
+
+	return cacheNodeIDs[numCacheNodes:]
+}
+
 func (b *ElastiCacheBroker) cacheClusterFromPlan(servicePlan ServicePlan) *awselasticache.CacheClusterDetails {
 	cacheClusterDetails := &awselasticache.CacheClusterDetails{
 		Engine: servicePlan.ElastiCacheProperties.Engine,
@@ -294,9 +704,22 @@ func (b *ElastiCacheBroker) cacheClusterFromPlan(servicePlan ServicePlan) *awsel
 	if servicePlan.ElastiCacheProperties.CacheSubnetGroupName != "" {
 		cacheClusterDetails.CacheSubnetGroupName = servicePlan.ElastiCacheProperties.CacheSubnetGroupName
 	}
+	if servicePlan.ElastiCacheProperties.CacheParameterGroupName != "" {
+		cacheClusterDetails.CacheParameterGroupName = servicePlan.ElastiCacheProperties.CacheParameterGroupName
+	}
 	if len(servicePlan.ElastiCacheProperties.CacheSecurityGroups) > 0 {
 		cacheClusterDetails.CacheSecurityGroups = servicePlan.ElastiCacheProperties.CacheSecurityGroups
 	}
+	if servicePlan.ElastiCacheProperties.PreferredMaintenanceWindow != "" {
+		cacheClusterDetails.PreferredMaintenanceWindow = servicePlan.ElastiCacheProperties.PreferredMaintenanceWindow
+	}
+	if servicePlan.ElastiCacheProperties.SnapshotRetentionLimit > 0 {
+		cacheClusterDetails.SnapshotRetentionLimit = aws.Int64(servicePlan.ElastiCacheProperties.SnapshotRetentionLimit)
+	}
+	if servicePlan.ElastiCacheProperties.SnapshotWindow != "" {
+		cacheClusterDetails.SnapshotWindow = servicePlan.ElastiCacheProperties.SnapshotWindow
+	}
+	cacheClusterDetails.AutoMinorVersionUpgrade = aws.Bool(servicePlan.ElastiCacheProperties.AutoMinorVersionUpgrade)
 
 	return cacheClusterDetails
 }
@@ -304,9 +727,13 @@ func (b *ElastiCacheBroker) cacheClusterFromPlan(servicePlan ServicePlan) *awsel
 func (b *ElastiCacheBroker) cacheTags(action, serviceID, planID, organizationID, spaceID string) map[string]string {
 	tags := make(map[string]string)
 
+	for key, value := range b.costAllocationTags {
+		tags[key] = value
+	}
+
 	tags["Owner"] = "Cloud Foundry"
 
-	tags[action+" by"] = "AWS ElastiCache Service Broker"
+	tags[action+" by"] = createdByTagValue
 
 	tags[action+" at"] = time.Now().Format(time.RFC822Z)
 
@@ -325,5 +752,32 @@ func (b *ElastiCacheBroker) cacheTags(action, serviceID, planID, organizationID,
 	if spaceID != "" {
 		tags["Space ID"] = spaceID
 	}
+
+	if b.cloudController != nil {
+		b.addCloudFoundryNameTags(tags, organizationID, spaceID)
+	}
+
 	return tags
 }
+
+// addCloudFoundryNameTags resolves the organization and space names using the Cloud Controller.
+// Names are informational only, so resolution errors are logged but do not fail the operation.
+func (b *ElastiCacheBroker) addCloudFoundryNameTags(tags map[string]string, organizationID, spaceID string) {
+	if organizationID != "" {
+		organization, err := b.cloudController.GetOrganization(organizationID)
+		if err != nil {
+			b.logger.Error("resolve-organization-name", err, lager.Data{"organization-id": organizationID})
+		} else {
+			tags["Organization"] = sanitizeTagValue(organization.Name)
+		}
+	}
+
+	if spaceID != "" {
+		space, err := b.cloudController.GetSpace(spaceID)
+		if err != nil {
+			b.logger.Error("resolve-space-name", err, lager.Data{"space-id": spaceID})
+		} else {
+			tags["Space"] = sanitizeTagValue(space.Name)
+		}
+	}
+}
@@ -0,0 +1,817 @@
+package broker_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/frodenas/brokerapi"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+	cwfakes "github.com/cloudfoundry-community/elasticache-broker/awscloudwatch/fakes"
+	ec2fakes "github.com/cloudfoundry-community/elasticache-broker/awsec2/fakes"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache/fakes"
+	. "github.com/cloudfoundry-community/elasticache-broker/broker"
+	"github.com/cloudfoundry-community/elasticache-broker/cloudcontroller"
+	ccfakes "github.com/cloudfoundry-community/elasticache-broker/cloudcontroller/fakes"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+var _ = Describe("ElastiCache Broker", func() {
+	const instanceID = "ce71b484-d542-40f7-9dd4-5526e38c81ba"
+
+	var (
+		config              Config
+		cacheCluster        *fakes.FakeCacheCluster
+		cacheSubnetGroup    *fakes.FakeCacheSubnetGroup
+		securityGroup       *ec2fakes.FakeSecurityGroup
+		cacheClusterMetrics *cwfakes.FakeCacheClusterMetrics
+		cloudController     *ccfakes.FakeClient
+		brokerStore         *store.MemoryStore
+
+		elastiCacheBroker *ElastiCacheBroker
+	)
+
+	BeforeEach(func() {
+		config = Config{
+			Region:      "elasticache-region",
+			CachePrefix: "cf",
+			Catalog: Catalog{
+				Services: []Service{
+					Service{
+						ID:             "Service-1",
+						Name:           "Service 1",
+						Description:    "Service 1 description",
+						Bindable:       true,
+						PlanUpdateable: true,
+						Plans: []ServicePlan{
+							ServicePlan{
+								ID:          "Plan-1",
+								Name:        "Plan 1",
+								Description: "Plan 1 description",
+								ElastiCacheProperties: ElastiCacheProperties{
+									CacheInstanceClass: "cache.t2.micro",
+									Engine:             "redis",
+									EngineVersion:      "2.8.24",
+									NumCacheNodes:      1,
+								},
+							},
+							ServicePlan{
+								ID:          "Plan-2",
+								Name:        "Plan 2",
+								Description: "Plan 2 description",
+								ElastiCacheProperties: ElastiCacheProperties{
+									CacheInstanceClass: "cache.m3.medium",
+									Engine:             "redis",
+									EngineVersion:      "2.8.24",
+									NumCacheNodes:      1,
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		cacheCluster = &fakes.FakeCacheCluster{
+			ListTagsTags: map[string]string{"Instance ID": instanceID, "Broker ID": "cf"},
+		}
+		cacheSubnetGroup = &fakes.FakeCacheSubnetGroup{}
+		securityGroup = &ec2fakes.FakeSecurityGroup{}
+		cacheClusterMetrics = &cwfakes.FakeCacheClusterMetrics{}
+		brokerStore = store.NewMemoryStore()
+		cloudController = &ccfakes.FakeClient{
+			GetOrganizationOrganization: cloudcontroller.Organization{GUID: "organization-id", Name: "my-org"},
+			GetSpaceSpace:               cloudcontroller.Space{GUID: "space-id", Name: "my-space"},
+		}
+	})
+
+	JustBeforeEach(func() {
+		elastiCacheBroker = New(config, cacheCluster, cacheSubnetGroup, securityGroup, cacheClusterMetrics, cloudController, brokerStore, lagertest.NewTestLogger("broker_test"))
+	})
+
+	Describe("Catalog", func() {
+		It("publishes the plans without the ElastiCache properties", func() {
+			catalog := elastiCacheBroker.Catalog()
+
+			encoded, err := json.Marshal(catalog)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(encoded)).ToNot(ContainSubstring("elasticache_properties"))
+			Expect(catalog.Services[0].Plans).To(HaveLen(2))
+		})
+
+		It("does not advertise retrievable service instances and bindings with a memory store", func() {
+			catalog := elastiCacheBroker.Catalog()
+
+			Expect(catalog.Services[0].InstancesRetrievable).To(BeFalse())
+			Expect(catalog.Services[0].BindingsRetrievable).To(BeFalse())
+		})
+
+		It("advertises retrievable service instances and bindings with a durable store", func() {
+			storeDir, err := ioutil.TempDir("", "broker")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(storeDir)
+			fileStore, err := store.NewFileStore(filepath.Join(storeDir, "state.json"), lagertest.NewTestLogger("broker_test"))
+			Expect(err).ToNot(HaveOccurred())
+			elastiCacheBroker = New(config, cacheCluster, cacheSubnetGroup, securityGroup, cacheClusterMetrics, cloudController, fileStore, lagertest.NewTestLogger("broker_test"))
+
+			catalog := elastiCacheBroker.Catalog()
+			Expect(catalog.Services[0].InstancesRetrievable).To(BeTrue())
+			Expect(catalog.Services[0].BindingsRetrievable).To(BeTrue())
+		})
+
+		It("generates closed schemas when user parameters are not allowed", func() {
+			schemas := elastiCacheBroker.Catalog().Services[0].Plans[0].Schemas
+
+			Expect(schemas.ServiceInstance.Create.Parameters).To(HaveKeyWithValue("additionalProperties", false))
+			Expect(schemas.ServiceInstance.Create.Parameters["properties"]).To(BeEmpty())
+			Expect(schemas.ServiceBinding.Create.Parameters["properties"]).To(BeEmpty())
+		})
+
+		Context("when user parameters are allowed", func() {
+			BeforeEach(func() {
+				maximum := int64(3)
+				config.AllowUserProvisionParameters = true
+				config.AllowUserUpdateParameters = true
+				config.Catalog.Services[0].Plans[0].AllowedParameters = map[string]ParameterConstraints{
+					"num_cache_nodes":   ParameterConstraints{Maximum: &maximum},
+					"apply_immediately": ParameterConstraints{},
+				}
+			})
+
+			It("generates the schemas from the allowed parameters", func() {
+				schemas := elastiCacheBroker.Catalog().Services[0].Plans[0].Schemas
+
+				createProperties := schemas.ServiceInstance.Create.Parameters["properties"].(map[string]interface{})
+				Expect(createProperties).To(HaveLen(1))
+				Expect(createProperties["num_cache_nodes"]).To(HaveKeyWithValue("type", "integer"))
+				Expect(createProperties["num_cache_nodes"]).To(HaveKeyWithValue("maximum", int64(3)))
+
+				updateProperties := schemas.ServiceInstance.Update.Parameters["properties"].(map[string]interface{})
+				Expect(updateProperties).To(HaveLen(1))
+				Expect(updateProperties).To(HaveKey("apply_immediately"))
+			})
+
+			It("generates the schemas from all parameters when the plan does not restrict them", func() {
+				schemas := elastiCacheBroker.Catalog().Services[0].Plans[1].Schemas
+
+				createProperties := schemas.ServiceInstance.Create.Parameters["properties"].(map[string]interface{})
+				Expect(createProperties).To(HaveKey("tags"))
+				Expect(createProperties).To(HaveKey("snapshot_window"))
+			})
+		})
+
+		Context("when the plan has schemas", func() {
+			BeforeEach(func() {
+				config.Catalog.Services[0].Plans[0].Schemas = &api.ServiceSchemas{
+					ServiceInstance: api.ServiceInstanceSchema{
+						Create: &api.InputParametersSchema{
+							Parameters: map[string]interface{}{"type": "object"},
+						},
+					},
+				}
+			})
+
+			It("publishes the plan schemas", func() {
+				schemas := elastiCacheBroker.Catalog().Services[0].Plans[0].Schemas
+
+				Expect(schemas.ServiceInstance.Create.Parameters).To(Equal(map[string]interface{}{"type": "object"}))
+				Expect(schemas.ServiceInstance.Update).ToNot(BeNil())
+			})
+		})
+	})
+
+	Describe("Provision", func() {
+		var provisionDetails brokerapi.ProvisionDetails
+
+		BeforeEach(func() {
+			provisionDetails = brokerapi.ProvisionDetails{
+				OrganizationGUID: "organization-id",
+				PlanID:           "Plan-1",
+				ServiceID:        "Service-1",
+				SpaceGUID:        "space-id",
+			}
+			cacheCluster.DescribeError = awselasticache.ErrCacheClusterDoesNotExist
+		})
+
+		It("creates the cache cluster", func() {
+			_, asynch, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(asynch).To(BeTrue())
+			Expect(elastiCacheBroker.ResumeWorkflows()).To(Succeed())
+			Expect(cacheCluster.CreateCalled).To(BeTrue())
+			Expect(cacheCluster.CreateCacheClusterDetails.Engine).To(Equal("redis"))
+		})
+
+		It("tags the cache cluster with the organization and space names", func() {
+			_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(elastiCacheBroker.ResumeWorkflows()).To(Succeed())
+
+			tags := cacheCluster.CreateCacheClusterDetails.Tags
+			Expect(tags).To(HaveKeyWithValue("Organization ID", "organization-id"))
+			Expect(tags).To(HaveKeyWithValue("Organization", "my-org"))
+			Expect(tags).To(HaveKeyWithValue("Space ID", "space-id"))
+			Expect(tags).To(HaveKeyWithValue("Space", "my-space"))
+		})
+
+		It("replaces the characters not allowed in tags and truncates the organization and space names", func() {
+			cloudController.GetOrganizationOrganization.Name = "R&D (prod)"
+			cloudController.GetSpaceSpace.Name = strings.Repeat("s", 300)
+
+			_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(elastiCacheBroker.ResumeWorkflows()).To(Succeed())
+
+			tags := cacheCluster.CreateCacheClusterDetails.Tags
+			Expect(tags).To(HaveKeyWithValue("Organization", "R-D -prod-"))
+			Expect(tags).To(HaveKeyWithValue("Space", strings.Repeat("s", 256)))
+		})
+
+		Context("when there are cost allocation tags", func() {
+			BeforeEach(func() {
+				config.CostAllocationTags = map[string]string{"Cost Center": "1234", "Owner": "me"}
+			})
+
+			It("tags the cache cluster with the cost allocation tags", func() {
+				_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(elastiCacheBroker.ResumeWorkflows()).To(Succeed())
+
+				tags := cacheCluster.CreateCacheClusterDetails.Tags
+				Expect(tags).To(HaveKeyWithValue("Cost Center", "1234"))
+				Expect(tags).To(HaveKeyWithValue("Owner", "Cloud Foundry"))
+			})
+		})
+
+		Context("when the user supplies parameters", func() {
+			BeforeEach(func() {
+				config.AllowUserProvisionParameters = true
+			})
+
+			It("overrides the plan properties", func() {
+				provisionDetails.Parameters = map[string]interface{}{
+					"cache_node_type":          "cache.m3.large",
+					"num_cache_nodes":          float64(2),
+					"snapshot_retention_limit": float64(0),
+					"snapshot_window":          "05:00-09:00",
+				}
+
+				_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(elastiCacheBroker.ResumeWorkflows()).To(Succeed())
+
+				instance := cacheCluster.CreateCacheClusterDetails
+				Expect(instance.CacheInstanceClass).To(Equal("cache.m3.large"))
+				Expect(instance.NumCacheNodes).To(Equal(int64(2)))
+				Expect(*instance.SnapshotRetentionLimit).To(Equal(int64(0)))
+				Expect(instance.SnapshotWindow).To(Equal("05:00-09:00"))
+				Expect(instance.EngineVersion).To(Equal("2.8.24"))
+			})
+
+			It("returns a 400 error when a parameter is unknown", func() {
+				provisionDetails.Parameters = map[string]interface{}{"node_type": "cache.m3.large"}
+
+				_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).To(MatchError("Unknown parameter 'node_type'"))
+				Expect(err.(*api.FailureResponse).StatusCode()).To(Equal(http.StatusBadRequest))
+				Expect(cacheCluster.CreateCalled).To(BeFalse())
+			})
+
+			It("returns a 400 error when a parameter has the wrong type", func() {
+				provisionDetails.Parameters = map[string]interface{}{"num_cache_nodes": "two"}
+
+				_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).To(MatchError(ContainSubstring("Invalid parameters")))
+				Expect(err.(*api.FailureResponse).StatusCode()).To(Equal(http.StatusBadRequest))
+			})
+
+			It("returns a 400 error when an integer parameter is not whole", func() {
+				provisionDetails.Parameters = map[string]interface{}{"num_cache_nodes": 1.5}
+
+				_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).To(MatchError("Parameter 'num_cache_nodes' must be an integer"))
+			})
+
+			Context("and the plan restricts the allowed parameters", func() {
+				BeforeEach(func() {
+					minimum, maximum := int64(1), int64(3)
+					config.Catalog.Services[0].Plans[0].AllowedParameters = map[string]ParameterConstraints{
+						"num_cache_nodes": ParameterConstraints{Minimum: &minimum, Maximum: &maximum},
+						"cache_node_type": ParameterConstraints{Values: []string{"cache.t2.micro", "cache.t2.small"}},
+					}
+				})
+
+				It("accepts parameters within the constraints", func() {
+					provisionDetails.Parameters = map[string]interface{}{
+						"num_cache_nodes": float64(3),
+						"cache_node_type": "cache.t2.small",
+					}
+
+					_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("returns a 400 error when a parameter is not allowed", func() {
+					provisionDetails.Parameters = map[string]interface{}{"port": float64(6380)}
+
+					_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+					Expect(err).To(MatchError("Parameter 'port' is not allowed by Service Plan 'Plan 1'"))
+				})
+
+				It("returns a 400 error when a parameter is out of range", func() {
+					provisionDetails.Parameters = map[string]interface{}{"num_cache_nodes": float64(4)}
+
+					_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+					Expect(err).To(MatchError("Parameter 'num_cache_nodes' must be less than or equal to 3"))
+				})
+
+				It("returns a 400 error when a parameter value is not allowed", func() {
+					provisionDetails.Parameters = map[string]interface{}{"cache_node_type": "cache.r3.8xlarge"}
+
+					_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+					Expect(err).To(MatchError("Parameter 'cache_node_type' must be one of: cache.t2.micro, cache.t2.small"))
+				})
+			})
+		})
+
+		Context("when the plan has a create schema", func() {
+			BeforeEach(func() {
+				config.AllowUserProvisionParameters = true
+				config.Catalog.Services[0].Plans[0].Schemas = &api.ServiceSchemas{
+					ServiceInstance: api.ServiceInstanceSchema{
+						Create: &api.InputParametersSchema{
+							Parameters: map[string]interface{}{
+								"type":     "object",
+								"required": []interface{}{"tags"},
+							},
+						},
+					},
+				}
+			})
+
+			It("returns a 400 error when the parameters do not match the schema", func() {
+				provisionDetails.Parameters = map[string]interface{}{"port": float64(6380)}
+
+				_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).To(MatchError("parameters.tags: is required"))
+				Expect(err.(*api.FailureResponse).StatusCode()).To(Equal(http.StatusBadRequest))
+				Expect(cacheCluster.CreateCalled).To(BeFalse())
+			})
+
+			It("returns a 400 error when no parameters are sent", func() {
+				provisionDetails.Parameters = nil
+
+				_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).To(MatchError("parameters.tags: is required"))
+				Expect(err.(*api.FailureResponse).StatusCode()).To(Equal(http.StatusBadRequest))
+				Expect(brokerStore.ListInstances()).To(BeEmpty())
+			})
+		})
+
+		Context("when user parameters are not allowed", func() {
+			It("returns a 400 error when parameters are supplied", func() {
+				provisionDetails.Parameters = map[string]interface{}{"port": float64(6380)}
+
+				_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).To(MatchError(ErrUserProvisionParametersNotAllowed.Error()))
+				Expect(err.(*api.FailureResponse).StatusCode()).To(Equal(http.StatusBadRequest))
+			})
+		})
+
+		Context("when the user supplies tags", func() {
+			BeforeEach(func() {
+				config.AllowUserProvisionParameters = true
+				config.AllowedUserTagKeys = []string{"Cost Center"}
+			})
+
+			It("tags the cache cluster with the user tags", func() {
+				provisionDetails.Parameters = map[string]interface{}{
+					"tags": map[string]interface{}{"Cost Center": "cc-1234"},
+				}
+
+				_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(elastiCacheBroker.ResumeWorkflows()).To(Succeed())
+
+				tags := cacheCluster.CreateCacheClusterDetails.Tags
+				Expect(tags).To(HaveKeyWithValue("Cost Center", "cc-1234"))
+				Expect(tags).To(HaveKeyWithValue("Owner", "Cloud Foundry"))
+			})
+
+			It("returns a 400 error when a tag overrides a broker tag", func() {
+				config.AllowedUserTagKeys = nil
+				provisionDetails.Parameters = map[string]interface{}{
+					"tags": map[string]interface{}{"Owner": "me"},
+				}
+
+				_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).To(MatchError("Tag key 'Owner' is managed by the broker"))
+				Expect(err.(*api.FailureResponse).StatusCode()).To(Equal(http.StatusBadRequest))
+				Expect(cacheCluster.CreateCalled).To(BeFalse())
+			})
+
+			It("returns a 400 error when a tag key is not allowed", func() {
+				provisionDetails.Parameters = map[string]interface{}{
+					"tags": map[string]interface{}{"Team": "a-team"},
+				}
+
+				_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).To(MatchError(ContainSubstring("Tag key 'Team' is not allowed")))
+				Expect(err.(*api.FailureResponse).StatusCode()).To(Equal(http.StatusBadRequest))
+			})
+
+			It("returns a 400 error when a tag uses the reserved AWS prefix", func() {
+				config.AllowedUserTagKeys = nil
+				provisionDetails.Parameters = map[string]interface{}{
+					"tags": map[string]interface{}{"aws:createdBy": "me"},
+				}
+
+				_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).To(MatchError(ContainSubstring("reserved 'aws:' prefix")))
+			})
+
+			It("returns a 400 error when a tag value is too long", func() {
+				provisionDetails.Parameters = map[string]interface{}{
+					"tags": map[string]interface{}{"Cost Center": strings.Repeat("a", 257)},
+				}
+
+				_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).To(MatchError("Tag 'Cost Center' value exceeds 256 characters"))
+			})
+		})
+
+		Context("when there is no Cloud Controller", func() {
+			It("does not tag the cache cluster with the organization and space names", func() {
+				elastiCacheBroker = New(config, cacheCluster, cacheSubnetGroup, securityGroup, cacheClusterMetrics, nil, brokerStore, lagertest.NewTestLogger("broker_test"))
+
+				_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(elastiCacheBroker.ResumeWorkflows()).To(Succeed())
+
+				tags := cacheCluster.CreateCacheClusterDetails.Tags
+				Expect(tags).To(HaveKeyWithValue("Organization ID", "organization-id"))
+				Expect(tags).ToNot(HaveKey("Organization"))
+			})
+		})
+	})
+
+	Describe("Update", func() {
+		var updateDetails brokerapi.UpdateDetails
+
+		BeforeEach(func() {
+			updateDetails = brokerapi.UpdateDetails{
+				ServiceID: "Service-1",
+				PlanID:    "Plan-1",
+				PreviousValues: brokerapi.PreviousValues{
+					PlanID:         "Plan-1",
+					ServiceID:      "Service-1",
+					OrganizationID: "organization-id",
+					SpaceID:        "space-id",
+				},
+			}
+		})
+
+		It("preserves the organization and space tags", func() {
+			_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+			Expect(err).ToNot(HaveOccurred())
+
+			tags := cacheCluster.ModifyCacheClusterDetails.Tags
+			Expect(tags).To(HaveKeyWithValue("Organization ID", "organization-id"))
+			Expect(tags).To(HaveKeyWithValue("Organization", "my-org"))
+			Expect(tags).To(HaveKeyWithValue("Space ID", "space-id"))
+			Expect(tags).To(HaveKeyWithValue("Space", "my-space"))
+		})
+
+		Context("when the plan changes", func() {
+			BeforeEach(func() {
+				updateDetails.PlanID = "Plan-2"
+				cacheCluster.DescribeCacheClusterDetails = awselasticache.CacheClusterDetails{
+					Engine:             "redis",
+					CacheInstanceClass: "cache.t2.micro",
+					NumCacheNodes:      1,
+					CacheNodes:         []awselasticache.CacheNodeDetails{awselasticache.CacheNodeDetails{CacheNodeId: "0001"}},
+				}
+			})
+
+			It("modifies the cache node type", func() {
+				_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(cacheCluster.ModifyCacheClusterDetails.CacheInstanceClass).To(Equal("cache.m3.medium"))
+				Expect(cacheCluster.ModifyCacheClusterDetails.NumCacheNodes).To(BeZero())
+			})
+
+			Context("to a plan with fewer cache nodes", func() {
+				BeforeEach(func() {
+					config.Catalog.Services[0].Plans[0].ElastiCacheProperties.Engine = "memcached"
+					config.Catalog.Services[0].Plans[1].ElastiCacheProperties.Engine = "memcached"
+					config.Catalog.Services[0].Plans[1].ElastiCacheProperties.CacheInstanceClass = "cache.t2.micro"
+					config.Catalog.Services[0].Plans[1].ElastiCacheProperties.NumCacheNodes = 2
+					cacheCluster.DescribeCacheClusterDetails = awselasticache.CacheClusterDetails{
+						Engine:             "memcached",
+						CacheInstanceClass: "cache.t2.micro",
+						NumCacheNodes:      3,
+						CacheNodes: []awselasticache.CacheNodeDetails{
+							awselasticache.CacheNodeDetails{CacheNodeId: "0003"},
+							awselasticache.CacheNodeDetails{CacheNodeId: "0001"},
+							awselasticache.CacheNodeDetails{CacheNodeId: "0002"},
+						},
+					}
+				})
+
+				It("removes the most recent cache nodes", func() {
+					_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(cacheCluster.ModifyCacheClusterDetails.CacheInstanceClass).To(BeEmpty())
+					Expect(cacheCluster.ModifyCacheClusterDetails.NumCacheNodes).To(Equal(int64(2)))
+					Expect(cacheCluster.ModifyCacheClusterDetails.CacheNodeIdsToRemove).To(Equal([]string{"0003"}))
+				})
+			})
+		})
+
+		Context("when the cache cluster already has tags", func() {
+			BeforeEach(func() {
+				cacheCluster.ListTagsTags = map[string]string{
+					"Owner":           "Cloud Foundry",
+					"Instance ID":     instanceID,
+					"Broker ID":       "cf",
+					"Created by":      "AWS ElastiCache Service Broker",
+					"Created at":      "01 Jan 16 00:00 +0000",
+					"Updated by":      "AWS ElastiCache Service Broker",
+					"Updated at":      "02 Jan 16 00:00 +0000",
+					"Service ID":      "Service-1",
+					"Plan ID":         "Plan-1",
+					"Organization ID": "organization-id",
+					"Organization":    "my-org",
+					"Space ID":        "space-id",
+					"Space":           "my-space",
+					"Cost Center":     "cc-1234",
+				}
+			})
+
+			It("keeps the creation and user tags", func() {
+				_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+				Expect(err).ToNot(HaveOccurred())
+
+				tags := cacheCluster.ModifyCacheClusterDetails.Tags
+				Expect(tags).To(HaveKeyWithValue("Created at", "01 Jan 16 00:00 +0000"))
+				Expect(tags).To(HaveKeyWithValue("Cost Center", "cc-1234"))
+			})
+
+			It("does not touch the update timestamp when nothing else changed", func() {
+				_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(cacheCluster.ModifyCacheClusterDetails.Tags).To(Equal(cacheCluster.ListTagsTags))
+			})
+
+			It("refreshes the update timestamp when the plan changes", func() {
+				updateDetails.PlanID = "Plan-2"
+
+				_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+				Expect(err).ToNot(HaveOccurred())
+
+				tags := cacheCluster.ModifyCacheClusterDetails.Tags
+				Expect(tags).To(HaveKeyWithValue("Plan ID", "Plan-2"))
+				Expect(tags["Updated at"]).ToNot(Equal("02 Jan 16 00:00 +0000"))
+			})
+		})
+
+		Context("when the user supplies parameters", func() {
+			BeforeEach(func() {
+				config.AllowUserUpdateParameters = true
+			})
+
+			It("modifies the cache cluster", func() {
+				updateDetails.Parameters = map[string]interface{}{
+					"apply_immediately":            true,
+					"preferred_maintenance_window": "sun:05:00-sun:09:00",
+				}
+
+				_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cacheCluster.ModifyApplyImmediately).To(BeTrue())
+				Expect(cacheCluster.ModifyCacheClusterDetails.PreferredMaintenanceWindow).To(Equal("sun:05:00-sun:09:00"))
+			})
+
+			It("returns a 400 error when a parameter is only supported on provision", func() {
+				updateDetails.Parameters = map[string]interface{}{"port": float64(6380)}
+
+				_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+				Expect(err).To(MatchError("Unknown parameter 'port'"))
+				Expect(cacheCluster.ModifyCalled).To(BeFalse())
+			})
+		})
+
+		Context("when the user supplies tags", func() {
+			BeforeEach(func() {
+				config.AllowUserUpdateParameters = true
+				cacheCluster.ListTagsTags = map[string]string{
+					"Owner":       "Cloud Foundry",
+					"Instance ID": instanceID,
+					"Broker ID":   "cf",
+					"Cost Center": "cc-1234",
+					"Team":        "a-team",
+				}
+			})
+
+			It("replaces the user tags", func() {
+				updateDetails.Parameters = map[string]interface{}{
+					"tags": map[string]interface{}{"Cost Center": "cc-5678"},
+				}
+
+				_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+				Expect(err).ToNot(HaveOccurred())
+
+				tags := cacheCluster.ModifyCacheClusterDetails.Tags
+				Expect(tags).To(HaveKeyWithValue("Cost Center", "cc-5678"))
+				Expect(tags).ToNot(HaveKey("Team"))
+				Expect(tags).To(HaveKeyWithValue("Owner", "Cloud Foundry"))
+			})
+
+			It("returns a 400 error when a tag violates policy", func() {
+				updateDetails.Parameters = map[string]interface{}{
+					"tags": map[string]interface{}{"Plan ID": "free"},
+				}
+
+				_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+				Expect(err).To(MatchError("Tag key 'Plan ID' is managed by the broker"))
+				Expect(err.(*api.FailureResponse).StatusCode()).To(Equal(http.StatusBadRequest))
+				Expect(cacheCluster.ModifyCalled).To(BeFalse())
+			})
+		})
+
+		Context("when the plan is not updateable", func() {
+			BeforeEach(func() {
+				planUpdateable := false
+				config.Catalog.Services[0].Plans[0].PlanUpdateable = &planUpdateable
+			})
+
+			It("returns the proper error", func() {
+				updateDetails.PlanID = "Plan-2"
+
+				_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+				Expect(err).To(Equal(brokerapi.ErrInstanceNotUpdateable))
+				Expect(cacheCluster.ModifyCalled).To(BeFalse())
+			})
+		})
+
+		Context("when the service is not updateable but the plan is", func() {
+			BeforeEach(func() {
+				planUpdateable := true
+				config.Catalog.Services[0].PlanUpdateable = false
+				config.Catalog.Services[0].Plans[0].PlanUpdateable = &planUpdateable
+			})
+
+			It("modifies the cache cluster", func() {
+				updateDetails.PlanID = "Plan-2"
+
+				_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cacheCluster.ModifyCalled).To(BeTrue())
+			})
+		})
+
+		Context("when the plan restricts the plan updates", func() {
+			BeforeEach(func() {
+				config.Catalog.Services[0].Plans[1].AllowedPlanUpdates = []string{}
+			})
+
+			It("returns a 422 error", func() {
+				updateDetails.PlanID = "Plan-1"
+				updateDetails.PreviousValues.PlanID = "Plan-2"
+
+				_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+				Expect(err).To(MatchError("Service Plan 'Plan 2' cannot be updated to Service Plan 'Plan 1'"))
+				Expect(err.(*api.FailureResponse).StatusCode()).To(Equal(422))
+				Expect(cacheCluster.ModifyCalled).To(BeFalse())
+			})
+
+			It("falls back to the recorded plan when no previous values are sent", func() {
+				Expect(brokerStore.PutInstance(store.Instance{ID: instanceID, PlanID: "Plan-2"})).To(Succeed())
+				updateDetails.PlanID = "Plan-1"
+				updateDetails.PreviousValues = brokerapi.PreviousValues{}
+
+				_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+				Expect(err).To(MatchError("Service Plan 'Plan 2' cannot be updated to Service Plan 'Plan 1'"))
+				Expect(cacheCluster.ModifyCalled).To(BeFalse())
+			})
+		})
+
+		Context("when the plan uses a different engine", func() {
+			BeforeEach(func() {
+				config.Catalog.Services[0].Plans[1].ElastiCacheProperties.Engine = "memcached"
+			})
+
+			It("returns a 422 error", func() {
+				updateDetails.PlanID = "Plan-2"
+
+				_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+				Expect(err).To(MatchError("Service Plan 'Plan 1' uses the 'redis' engine and cannot be updated to Service Plan 'Plan 2' using the 'memcached' engine"))
+				Expect(err.(*api.FailureResponse).StatusCode()).To(Equal(422))
+			})
+		})
+
+		Context("when downgrading to a plan with less memory", func() {
+			BeforeEach(func() {
+				updateDetails.PlanID = "Plan-1"
+				updateDetails.PreviousValues.PlanID = "Plan-2"
+				cacheCluster.DescribeCacheClusterDetails = awselasticache.CacheClusterDetails{
+					Engine:     "redis",
+					CacheNodes: []awselasticache.CacheNodeDetails{awselasticache.CacheNodeDetails{CacheNodeId: "0001"}},
+				}
+			})
+
+			It("checks the cache cluster memory usage", func() {
+				cacheClusterMetrics.MemoryUsageBytes = 100 * 1024 * 1024
+
+				_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cacheClusterMetrics.MemoryUsageCacheClusterID).To(Equal("cf-aso4rtfujlvj"))
+				Expect(cacheClusterMetrics.MemoryUsageEngine).To(Equal("redis"))
+				Expect(cacheClusterMetrics.MemoryUsageCacheNodeIDs).To(Equal([]string{"0001"}))
+				Expect(cacheCluster.ModifyCalled).To(BeTrue())
+			})
+
+			It("returns a 422 error when the memory usage exceeds the plan memory", func() {
+				cacheClusterMetrics.MemoryUsageBytes = 1024 * 1024 * 1024
+
+				_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+				Expect(err).To(MatchError("Service Plan 'Plan 1' provides 568 MiB of memory, but the cache cluster is using 1024 MiB"))
+				Expect(err.(*api.FailureResponse).StatusCode()).To(Equal(422))
+				Expect(cacheCluster.ModifyCalled).To(BeFalse())
+			})
+
+			Context("when the plan sets its cache node memory", func() {
+				BeforeEach(func() {
+					config.Catalog.Services[0].Plans[0].ElastiCacheProperties.CacheInstanceClass = "cache.r6g.large"
+					config.Catalog.Services[0].Plans[0].ElastiCacheProperties.CacheNodeMemory = 0.25
+				})
+
+				It("checks the memory usage against it", func() {
+					cacheClusterMetrics.MemoryUsageBytes = 300 * 1024 * 1024
+
+					_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+					Expect(err).To(MatchError("Service Plan 'Plan 1' provides 256 MiB of memory, but the cache cluster is using 300 MiB"))
+				})
+			})
+		})
+
+		Context("when the cache cluster tags cannot be listed", func() {
+			It("returns the proper error", func() {
+				cacheCluster.ListTagsError = awselasticache.ErrCacheClusterDoesNotExist
+
+				_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+				Expect(err).To(Equal(brokerapi.ErrInstanceDoesNotExist))
+				Expect(cacheCluster.ModifyCalled).To(BeFalse())
+			})
+		})
+
+		Context("when tagging the cache cluster fails", func() {
+			It("returns the error", func() {
+				cacheCluster.ModifyError = errors.New("AccessDenied: not authorized")
+
+				_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+				Expect(err).To(MatchError("AccessDenied: not authorized"))
+			})
+		})
+	})
+
+	Describe("Bind", func() {
+		var bindDetails brokerapi.BindDetails
+
+		BeforeEach(func() {
+			bindDetails = brokerapi.BindDetails{ServiceID: "Service-1", PlanID: "Plan-1"}
+		})
+
+		It("returns the proper error when the plan is not bindable", func() {
+			bindable := false
+			config.Catalog.Services[0].Plans[0].Bindable = &bindable
+			elastiCacheBroker = New(config, cacheCluster, cacheSubnetGroup, securityGroup, cacheClusterMetrics, cloudController, brokerStore, lagertest.NewTestLogger("broker_test"))
+
+			_, err := elastiCacheBroker.Bind(instanceID, "binding-id", bindDetails)
+			Expect(err).To(Equal(brokerapi.ErrInstanceNotBindable))
+			Expect(cacheCluster.DescribeCalled).To(BeFalse())
+		})
+
+		It("returns a 400 error when parameters are supplied", func() {
+			bindDetails.Parameters = map[string]interface{}{"read_only": true}
+
+			_, err := elastiCacheBroker.Bind(instanceID, "binding-id", bindDetails)
+			Expect(err).To(MatchError("parameters.read_only: is not allowed"))
+			Expect(err.(*api.FailureResponse).StatusCode()).To(Equal(http.StatusBadRequest))
+			Expect(cacheCluster.DescribeCalled).To(BeFalse())
+		})
+	})
+})
@@ -1,7 +1,14 @@
 package broker
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"strings"
+
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+	"github.com/cloudfoundry-community/elasticache-broker/jsonschema"
 )
 
 type Catalog struct {
@@ -19,6 +26,8 @@ type Service struct {
 	PlanUpdateable  bool             `json:"plan_updateable"`
 	Plans           []ServicePlan    `json:"plans,omitempty"`
 	DashboardClient *DashboardClient `json:"dashboard_client,omitempty"`
+
+	ElastiCacheProperties *ElastiCacheProperties `json:"elasticache_properties,omitempty"`
 }
 
 type ServiceMetadata struct {
@@ -31,12 +40,33 @@ type ServiceMetadata struct {
 }
 
 type ServicePlan struct {
-	ID            string               `json:"id"`
-	Name          string               `json:"name"`
-	Description   string               `json:"description"`
-	Metadata      *ServicePlanMetadata `json:"metadata,omitempty"`
-	Free          bool                 `json:"free"`
-	ElastiCacheProperties ElastiCacheProperties        `json:"elasticache_properties,omitempty"`
+	ID                    string                          `json:"id"`
+	Name                  string                          `json:"name"`
+	Description           string                          `json:"description"`
+	Metadata              *ServicePlanMetadata            `json:"metadata,omitempty"`
+	Free                  bool                            `json:"free"`
+	Bindable              *bool                           `json:"bindable,omitempty"`
+	PlanUpdateable        *bool                           `json:"plan_updateable,omitempty"`
+	AllowedPlanUpdates    []string                        `json:"allowed_plan_updates,omitempty"`
+	ElastiCacheProperties ElastiCacheProperties           `json:"elasticache_properties,omitempty"`
+	AllowedParameters     map[string]ParameterConstraints `json:"allowed_parameters,omitempty"`
+	Schemas               *api.ServiceSchemas             `json:"schemas,omitempty"`
+	Adoption              *Adoption                       `json:"adoption,omitempty"`
+	OperationTimeouts     *OperationTimeouts              `json:"operation_timeouts,omitempty"`
+}
+
+// Adoption allows a service plan to adopt existing cache clusters instead of creating new ones.
+// Only the organizations explicitly allowed may adopt cache clusters.
+type Adoption struct {
+	AllowedOrganizationIDs []string `json:"allowed_organization_ids,omitempty"`
+}
+
+// OperationTimeouts are the deadlines, in seconds, of the asynchronous operations on the service
+// instances of a plan. Operations without a deadline are polled until the platform gives up.
+type OperationTimeouts struct {
+	Provision   int `json:"provision,omitempty"`
+	Update      int `json:"update,omitempty"`
+	Deprovision int `json:"deprovision,omitempty"`
 }
 
 type ServicePlanMetadata struct {
@@ -57,20 +87,28 @@ type Cost struct {
 }
 
 type ElastiCacheProperties struct {
-	CacheInstanceClass          string   `json:"cache_instance_class"`
-	Engine                      string   `json:"engine"`
-	EngineVersion               string   `json:"engine_version"`
-	AutoMinorVersionUpgrade     bool     `json:"auto_minor_version_upgrade,omitempty"`
-	Port                        int64    `json:"port,omitempty"`
-	NumCacheNodes								int64    `json:"num_cache_nodes,omitempty"`
-	CacheSecurityGroups         []string `json:"cache_security_groups,omitempty"`
-	CacheSubnetGroupName        string   `json:"cache_subnet_group_name,omitempty"`
+	CacheInstanceClass         string   `json:"cache_instance_class"`
+	CacheNodeMemory            float64  `json:"cache_node_memory,omitempty"`
+	Engine                     string   `json:"engine"`
+	EngineVersion              string   `json:"engine_version"`
+	AutoMinorVersionUpgrade    bool     `json:"auto_minor_version_upgrade,omitempty"`
+	Port                       int64    `json:"port,omitempty"`
+	NumCacheNodes              int64    `json:"num_cache_nodes,omitempty"`
+	CacheSecurityGroups        []string `json:"cache_security_groups,omitempty"`
+	CacheSubnetGroupName       string   `json:"cache_subnet_group_name,omitempty"`
+	CacheParameterGroupName    string   `json:"cache_parameter_group_name,omitempty"`
+	SubnetIDs                  []string `json:"subnet_ids,omitempty"`
+	InstanceSecurityGroup      bool     `json:"instance_security_group,omitempty"`
+	InstanceSecurityGroupCIDRs []string `json:"instance_security_group_cidrs,omitempty"`
+	PreferredMaintenanceWindow string   `json:"preferred_maintenance_window,omitempty"`
+	SnapshotRetentionLimit     int64    `json:"snapshot_retention_limit,omitempty"`
+	SnapshotWindow             string   `json:"snapshot_window,omitempty"`
 }
 
 func (c Catalog) Validate() error {
 	for _, service := range c.Services {
 		if err := service.Validate(); err != nil {
-			return fmt.Errorf("Validating Services configuration: %s", err)
+			return fmt.Errorf("Validating Services configuration: Service '%s': %s", service.Name, err)
 		}
 	}
 
@@ -99,6 +137,48 @@ func (c Catalog) FindServicePlan(planID string) (plan ServicePlan, found bool) {
 	return plan, false
 }
 
+func (c Catalog) usesInstanceSecurityGroups() bool {
+	for _, service := range c.Services {
+		for _, plan := range service.Plans {
+			if plan.ElastiCacheProperties.InstanceSecurityGroup {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// UnmarshalJSON decodes each plan over a copy of the service ElastiCacheProperties, so plans
+// inherit the service defaults and only need to set the properties they override.
+func (s *Service) UnmarshalJSON(data []byte) error {
+	type service Service
+	var rawService struct {
+		service
+		Plans []json.RawMessage `json:"plans,omitempty"`
+	}
+
+	if err := json.Unmarshal(data, &rawService); err != nil {
+		return err
+	}
+
+	*s = Service(rawService.service)
+	for _, rawPlan := range rawService.Plans {
+		servicePlan := ServicePlan{}
+		if s.ElastiCacheProperties != nil {
+			servicePlan.ElastiCacheProperties = s.ElastiCacheProperties.clone()
+		}
+
+		if err := json.Unmarshal(rawPlan, &servicePlan); err != nil {
+			return err
+		}
+
+		s.Plans = append(s.Plans, servicePlan)
+	}
+
+	return nil
+}
+
 func (s Service) Validate() error {
 	if s.ID == "" {
 		return fmt.Errorf("Must provide a non-empty ID (%+v)", s)
@@ -114,13 +194,52 @@ func (s Service) Validate() error {
 
 	for _, servicePlan := range s.Plans {
 		if err := servicePlan.Validate(); err != nil {
-			return fmt.Errorf("Validating Plans configuration: %s", err)
+			return fmt.Errorf("Validating Plans configuration: Service Plan '%s': %s", servicePlan.Name, err)
+		}
+
+		for _, planID := range servicePlan.AllowedPlanUpdates {
+			allowedPlan, ok := s.findServicePlan(planID)
+			if !ok {
+				return fmt.Errorf("Validating Plans configuration: Service Plan '%s': AllowedPlanUpdates references unknown Service Plan '%s'", servicePlan.Name, planID)
+			}
+
+			if allowedPlan.ElastiCacheProperties.Engine != servicePlan.ElastiCacheProperties.Engine {
+				return fmt.Errorf("Validating Plans configuration: Service Plan '%s': AllowedPlanUpdates references Service Plan '%s' using a different engine", servicePlan.Name, allowedPlan.Name)
+			}
 		}
 	}
 
 	return nil
 }
 
+func (s Service) findServicePlan(planID string) (plan ServicePlan, found bool) {
+	for _, plan := range s.Plans {
+		if plan.ID == planID {
+			return plan, true
+		}
+	}
+
+	return plan, false
+}
+
+// IsBindable returns the plan Bindable flag, defaulting to the service one.
+func (sp ServicePlan) IsBindable(service Service) bool {
+	if sp.Bindable != nil {
+		return *sp.Bindable
+	}
+
+	return service.Bindable
+}
+
+// IsUpdateable returns the plan PlanUpdateable flag, defaulting to the service one.
+func (sp ServicePlan) IsUpdateable(service Service) bool {
+	if sp.PlanUpdateable != nil {
+		return *sp.PlanUpdateable
+	}
+
+	return service.PlanUpdateable
+}
+
 func (sp ServicePlan) Validate() error {
 	if sp.ID == "" {
 		return fmt.Errorf("Must provide a non-empty ID (%+v)", sp)
@@ -138,10 +257,162 @@ func (sp ServicePlan) Validate() error {
 		return fmt.Errorf("Validating ElastiCache Properties configuration: %s", err)
 	}
 
+	for name, constraints := range sp.AllowedParameters {
+		if err := constraints.Validate(name); err != nil {
+			return fmt.Errorf("Validating Allowed Parameters configuration: %s", err)
+		}
+	}
+
+	if sp.OperationTimeouts != nil {
+		if err := sp.OperationTimeouts.Validate(); err != nil {
+			return fmt.Errorf("Validating Operation Timeouts configuration: %s", err)
+		}
+	}
+
+	if sp.Schemas != nil {
+		for _, schema := range []*api.InputParametersSchema{sp.Schemas.ServiceInstance.Create, sp.Schemas.ServiceInstance.Update, sp.Schemas.ServiceBinding.Create} {
+			if schema == nil {
+				continue
+			}
+			if err := jsonschema.CheckSchema(schema.Parameters); err != nil {
+				return fmt.Errorf("Validating Schemas configuration: %s", err)
+			}
+		}
+	}
+
+	if sp.Adoption != nil {
+		if err := sp.Adoption.Validate(); err != nil {
+			return fmt.Errorf("Validating Adoption configuration: %s", err)
+		}
+	}
+
 	return nil
 }
 
+func (a Adoption) Validate() error {
+	if len(a.AllowedOrganizationIDs) == 0 {
+		return errors.New("Must provide at least one AllowedOrganizationID")
+	}
+
+	return nil
+}
+
+func (ot OperationTimeouts) Validate() error {
+	if ot.Provision < 0 {
+		return fmt.Errorf("Invalid Provision '%d': must not be negative", ot.Provision)
+	}
+
+	if ot.Update < 0 {
+		return fmt.Errorf("Invalid Update '%d': must not be negative", ot.Update)
+	}
+
+	if ot.Deprovision < 0 {
+		return fmt.Errorf("Invalid Deprovision '%d': must not be negative", ot.Deprovision)
+	}
+
+	return nil
+}
+
+// clone returns a copy of the properties that does not share slices with the original.
+func (eq ElastiCacheProperties) clone() ElastiCacheProperties {
+	eq.CacheSecurityGroups = append([]string(nil), eq.CacheSecurityGroups...)
+	eq.SubnetIDs = append([]string(nil), eq.SubnetIDs...)
+	eq.InstanceSecurityGroupCIDRs = append([]string(nil), eq.InstanceSecurityGroupCIDRs...)
+
+	return eq
+}
+
 func (eq ElastiCacheProperties) Validate() error {
+	if eq.Engine == "" {
+		return errors.New("Must provide a non-empty Engine")
+	}
+
+	if !containsString(supportedEngines, eq.Engine) {
+		return fmt.Errorf("Invalid Engine '%s', must be one of: %s", eq.Engine, strings.Join(supportedEngines, ", "))
+	}
+
+	if eq.EngineVersion != "" && !engineVersionPattern.MatchString(eq.EngineVersion) {
+		return fmt.Errorf("Invalid EngineVersion '%s'", eq.EngineVersion)
+	}
+
+	if eq.CacheInstanceClass == "" {
+		return errors.New("Must provide a non-empty CacheInstanceClass")
+	}
+
+	if !cacheNodeTypePattern.MatchString(eq.CacheInstanceClass) {
+		return fmt.Errorf("Invalid CacheInstanceClass '%s', must be of the form 'cache.<family>.<size>'", eq.CacheInstanceClass)
+	}
+
+	if eq.CacheNodeMemory < 0 {
+		return fmt.Errorf("Invalid CacheNodeMemory '%v', must not be negative", eq.CacheNodeMemory)
+	}
+
+	if eq.Port < 0 || eq.Port > 65535 {
+		return fmt.Errorf("Invalid Port '%d', must be between 1 and 65535", eq.Port)
+	}
+
+	if eq.NumCacheNodes < 0 {
+		return fmt.Errorf("Invalid NumCacheNodes '%d'", eq.NumCacheNodes)
+	}
+
+	switch eq.Engine {
+	case engineMemcached:
+		if eq.NumCacheNodes > maxMemcachedCacheNodes {
+			return fmt.Errorf("Invalid NumCacheNodes '%d', memcached clusters support at most %d nodes", eq.NumCacheNodes, maxMemcachedCacheNodes)
+		}
+
+		if eq.SnapshotRetentionLimit != 0 || eq.SnapshotWindow != "" {
+			return errors.New("SnapshotRetentionLimit and SnapshotWindow are only supported by the redis engine")
+		}
+	case engineRedis:
+		if eq.NumCacheNodes > 1 {
+			return fmt.Errorf("Invalid NumCacheNodes '%d', non-replicated redis clusters must have 1 node", eq.NumCacheNodes)
+		}
+	}
+
+	if eq.SnapshotRetentionLimit < 0 {
+		return fmt.Errorf("Invalid SnapshotRetentionLimit '%d'", eq.SnapshotRetentionLimit)
+	}
+
+	if eq.PreferredMaintenanceWindow != "" && !maintenanceWindowPattern.MatchString(eq.PreferredMaintenanceWindow) {
+		return fmt.Errorf("Invalid PreferredMaintenanceWindow '%s', must be in the format ddd:hh24:mi-ddd:hh24:mi", eq.PreferredMaintenanceWindow)
+	}
+
+	if eq.SnapshotWindow != "" && !snapshotWindowPattern.MatchString(eq.SnapshotWindow) {
+		return fmt.Errorf("Invalid SnapshotWindow '%s', must be in the format hh24:mi-hh24:mi", eq.SnapshotWindow)
+	}
+
+	if eq.CacheSubnetGroupName != "" && len(eq.SubnetIDs) > 0 {
+		return errors.New("Must provide either a CacheSubnetGroupName or SubnetIDs, not both")
+	}
+
+	for _, securityGroupID := range eq.CacheSecurityGroups {
+		if !strings.HasPrefix(securityGroupID, "sg-") {
+			return fmt.Errorf("Invalid CacheSecurityGroup '%s', must be a VPC security group ID", securityGroupID)
+		}
+	}
+
+	for _, subnetID := range eq.SubnetIDs {
+		if !strings.HasPrefix(subnetID, "subnet-") {
+			return fmt.Errorf("Invalid SubnetID '%s'", subnetID)
+		}
+	}
+
+	if eq.InstanceSecurityGroup {
+		if eq.CacheSubnetGroupName == "" && len(eq.SubnetIDs) == 0 {
+			return errors.New("Must provide a CacheSubnetGroupName or SubnetIDs when using an InstanceSecurityGroup")
+		}
+
+		if len(eq.InstanceSecurityGroupCIDRs) == 0 {
+			return errors.New("Must provide at least one InstanceSecurityGroupCIDR when using an InstanceSecurityGroup")
+		}
+	}
+
+	for _, cidr := range eq.InstanceSecurityGroupCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("Invalid InstanceSecurityGroupCIDR '%s'", cidr)
+		}
+	}
 
 	return nil
 }
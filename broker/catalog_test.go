@@ -1,9 +1,12 @@
 package broker_test
 
 import (
+	"encoding/json"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/cloudfoundry-community/elasticache-broker/api"
 	. "github.com/cloudfoundry-community/elasticache-broker/broker"
 )
 
@@ -140,6 +143,106 @@ var _ = Describe("Service", func() {
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("Validating Plans configuration"))
 		})
+
+		It("points at the offending Plan", func() {
+			service.Plans = []ServicePlan{
+				ServicePlan{
+					ID:                    "Plan-1",
+					Name:                  "small",
+					Description:           "Plan-1 description",
+					ElastiCacheProperties: ElastiCacheProperties{CacheInstanceClass: "cache.t2.micro", Engine: "reddis"},
+				},
+			}
+
+			err := service.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Service Plan 'small': Validating ElastiCache Properties configuration: Invalid Engine 'reddis'"))
+		})
+
+		Context("when plans restrict the plan updates", func() {
+			BeforeEach(func() {
+				service.Plans = []ServicePlan{
+					ServicePlan{
+						ID:                    "Plan-1",
+						Name:                  "small",
+						Description:           "Plan-1 description",
+						AllowedPlanUpdates:    []string{"Plan-2"},
+						ElastiCacheProperties: ElastiCacheProperties{CacheInstanceClass: "cache.t2.micro", Engine: "redis"},
+					},
+					ServicePlan{
+						ID:                    "Plan-2",
+						Name:                  "medium",
+						Description:           "Plan-2 description",
+						ElastiCacheProperties: ElastiCacheProperties{CacheInstanceClass: "cache.m3.medium", Engine: "redis"},
+					},
+				}
+			})
+
+			It("does not return error if the allowed plans are valid", func() {
+				Expect(service.Validate()).To(Succeed())
+			})
+
+			It("returns error if an allowed plan does not exist", func() {
+				service.Plans[0].AllowedPlanUpdates = []string{"Plan-3"}
+
+				err := service.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Service Plan 'small': AllowedPlanUpdates references unknown Service Plan 'Plan-3'")))
+			})
+
+			It("returns error if an allowed plan uses a different engine", func() {
+				service.Plans[1].ElastiCacheProperties.Engine = "memcached"
+
+				err := service.Validate()
+				Expect(err).To(MatchError(ContainSubstring("Service Plan 'small': AllowedPlanUpdates references Service Plan 'medium' using a different engine")))
+			})
+		})
+	})
+
+	Describe("UnmarshalJSON", func() {
+		It("merges the plan ElastiCache properties over the service defaults", func() {
+			err := json.Unmarshal([]byte(`{
+				"id": "Service-1",
+				"elasticache_properties": {
+					"engine": "redis",
+					"port": 6379,
+					"auto_minor_version_upgrade": true,
+					"cache_subnet_group_name": "subnet-group",
+					"cache_security_groups": ["sg-1"]
+				},
+				"plans": [
+					{"id": "Plan-1", "elasticache_properties": {"cache_instance_class": "cache.t2.micro"}},
+					{"id": "Plan-2", "elasticache_properties": {"cache_instance_class": "cache.m3.medium", "auto_minor_version_upgrade": false, "cache_security_groups": ["sg-2"]}}
+				]
+			}`), &service)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(service.Plans).To(HaveLen(2))
+			Expect(service.Plans[0].ElastiCacheProperties).To(Equal(ElastiCacheProperties{
+				CacheInstanceClass:      "cache.t2.micro",
+				Engine:                  "redis",
+				Port:                    6379,
+				AutoMinorVersionUpgrade: true,
+				CacheSubnetGroupName:    "subnet-group",
+				CacheSecurityGroups:     []string{"sg-1"},
+			}))
+			Expect(service.Plans[1].ElastiCacheProperties).To(Equal(ElastiCacheProperties{
+				CacheInstanceClass:      "cache.m3.medium",
+				Engine:                  "redis",
+				Port:                    6379,
+				AutoMinorVersionUpgrade: false,
+				CacheSubnetGroupName:    "subnet-group",
+				CacheSecurityGroups:     []string{"sg-2"},
+			}))
+			Expect(service.ElastiCacheProperties.CacheSecurityGroups).To(Equal([]string{"sg-1"}))
+		})
+
+		It("keeps the plan ElastiCache properties when the service has no defaults", func() {
+			err := json.Unmarshal([]byte(`{"id": "Service-1", "plans": [{"id": "Plan-1", "elasticache_properties": {"engine": "memcached"}}]}`), &service)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(service.ElastiCacheProperties).To(BeNil())
+			Expect(service.Plans[0].ElastiCacheProperties).To(Equal(ElastiCacheProperties{Engine: "memcached"}))
+		})
 	})
 })
 
@@ -148,12 +251,15 @@ var _ = Describe("ServicePlan", func() {
 		servicePlan ServicePlan
 
 		validServicePlan = ServicePlan{
-			ID:            "Plan-1",
-			Name:          "Plan 1",
-			Description:   "Plan-1 description",
-			Metadata:      &ServicePlanMetadata{},
-			Free:          true,
-			ElastiCacheProperties: ElastiCacheProperties{},
+			ID:                    "Plan-1",
+			Name:                  "Plan 1",
+			Description:           "Plan-1 description",
+			Metadata:              &ServicePlanMetadata{},
+			Free:                  true,
+			ElastiCacheProperties: ElastiCacheProperties{
+				CacheInstanceClass: "cache.t2.micro",
+				Engine:             "redis",
+			},
 		}
 	)
 
@@ -161,6 +267,34 @@ var _ = Describe("ServicePlan", func() {
 		servicePlan = validServicePlan
 	})
 
+	Describe("IsBindable", func() {
+		It("defaults to the service Bindable flag", func() {
+			Expect(servicePlan.IsBindable(Service{Bindable: true})).To(BeTrue())
+			Expect(servicePlan.IsBindable(Service{Bindable: false})).To(BeFalse())
+		})
+
+		It("uses the plan Bindable flag when set", func() {
+			bindable := false
+			servicePlan.Bindable = &bindable
+
+			Expect(servicePlan.IsBindable(Service{Bindable: true})).To(BeFalse())
+		})
+	})
+
+	Describe("IsUpdateable", func() {
+		It("defaults to the service PlanUpdateable flag", func() {
+			Expect(servicePlan.IsUpdateable(Service{PlanUpdateable: true})).To(BeTrue())
+			Expect(servicePlan.IsUpdateable(Service{PlanUpdateable: false})).To(BeFalse())
+		})
+
+		It("uses the plan PlanUpdateable flag when set", func() {
+			planUpdateable := true
+			servicePlan.PlanUpdateable = &planUpdateable
+
+			Expect(servicePlan.IsUpdateable(Service{PlanUpdateable: false})).To(BeTrue())
+		})
+	})
+
 	Describe("Validate", func() {
 		It("does not return error if all fields are valid", func() {
 			err := servicePlan.Validate()
@@ -190,5 +324,254 @@ var _ = Describe("ServicePlan", func() {
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("Must provide a non-empty Description"))
 		})
+
+		It("returns error if ElastiCacheProperties are not valid", func() {
+			servicePlan.ElastiCacheProperties = ElastiCacheProperties{
+				CacheSubnetGroupName: "subnet-group",
+				SubnetIDs:            []string{"subnet-1"},
+			}
+
+			err := servicePlan.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Validating ElastiCache Properties configuration"))
+		})
+
+		It("returns error if Schemas are not valid", func() {
+			servicePlan.Schemas = &api.ServiceSchemas{
+				ServiceInstance: api.ServiceInstanceSchema{
+					Create: &api.InputParametersSchema{Parameters: map[string]interface{}{"type": "text"}},
+				},
+			}
+
+			err := servicePlan.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Validating Schemas configuration: parameters: unknown type 'text'"))
+		})
+
+		It("returns error if Adoption does not allow any organization", func() {
+			servicePlan.Adoption = &Adoption{}
+
+			err := servicePlan.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Validating Adoption configuration: Must provide at least one AllowedOrganizationID"))
+		})
+
+		It("returns error if an OperationTimeout is negative", func() {
+			servicePlan.OperationTimeouts = &OperationTimeouts{Update: -1}
+
+			err := servicePlan.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Validating Operation Timeouts configuration: Invalid Update '-1': must not be negative"))
+		})
+
+		It("returns error if an AllowedParameter is unknown", func() {
+			servicePlan.AllowedParameters = map[string]ParameterConstraints{"node_type": ParameterConstraints{}}
+
+			err := servicePlan.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Validating Allowed Parameters configuration: Unknown parameter 'node_type'"))
+		})
+
+		It("returns error if an AllowedParameter range is set on a non integer parameter", func() {
+			minimum := int64(1)
+			servicePlan.AllowedParameters = map[string]ParameterConstraints{"cache_node_type": ParameterConstraints{Minimum: &minimum}}
+
+			err := servicePlan.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Parameter 'cache_node_type' is not an integer"))
+		})
+
+		It("returns error if an AllowedParameter Minimum is greater than its Maximum", func() {
+			minimum, maximum := int64(3), int64(1)
+			servicePlan.AllowedParameters = map[string]ParameterConstraints{"num_cache_nodes": ParameterConstraints{Minimum: &minimum, Maximum: &maximum}}
+
+			err := servicePlan.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Parameter 'num_cache_nodes' Minimum must not be greater than Maximum"))
+		})
+	})
+})
+
+var _ = Describe("ElastiCacheProperties", func() {
+	var (
+		elastiCacheProperties ElastiCacheProperties
+	)
+
+	BeforeEach(func() {
+		elastiCacheProperties = ElastiCacheProperties{
+			CacheInstanceClass: "cache.t2.micro",
+			Engine:             "redis",
+			EngineVersion:      "2.8.24",
+			NumCacheNodes:      1,
+		}
+	})
+
+	Describe("Validate", func() {
+		It("does not return error if all fields are valid", func() {
+			elastiCacheProperties.SubnetIDs = []string{"subnet-1", "subnet-2"}
+
+			err := elastiCacheProperties.Validate()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns error if Engine is empty", func() {
+			elastiCacheProperties.Engine = ""
+
+			err := elastiCacheProperties.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Must provide a non-empty Engine"))
+		})
+
+		It("returns error if Engine is not supported", func() {
+			elastiCacheProperties.Engine = "reddis"
+
+			err := elastiCacheProperties.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid Engine 'reddis', must be one of: memcached, redis"))
+		})
+
+		It("returns error if EngineVersion is not valid", func() {
+			elastiCacheProperties.EngineVersion = "latest"
+
+			err := elastiCacheProperties.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid EngineVersion 'latest'"))
+		})
+
+		It("returns error if CacheInstanceClass is empty", func() {
+			elastiCacheProperties.CacheInstanceClass = ""
+
+			err := elastiCacheProperties.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Must provide a non-empty CacheInstanceClass"))
+		})
+
+		It("returns error if CacheInstanceClass is not valid", func() {
+			elastiCacheProperties.CacheInstanceClass = "t2.micro"
+
+			err := elastiCacheProperties.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid CacheInstanceClass 't2.micro'"))
+		})
+
+		It("accepts any CacheInstanceClass node type family", func() {
+			for _, cacheInstanceClass := range []string{"cache.m5.large", "cache.r5.12xlarge", "cache.t3.micro", "cache.r6g.large"} {
+				elastiCacheProperties.CacheInstanceClass = cacheInstanceClass
+				Expect(elastiCacheProperties.Validate()).To(Succeed())
+			}
+		})
+
+		It("returns error if CacheNodeMemory is negative", func() {
+			elastiCacheProperties.CacheNodeMemory = -1
+
+			err := elastiCacheProperties.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid CacheNodeMemory '-1'"))
+		})
+
+		It("returns error if Port is out of range", func() {
+			elastiCacheProperties.Port = 70000
+
+			err := elastiCacheProperties.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid Port '70000'"))
+		})
+
+		It("returns error if a redis plan has more than 1 node", func() {
+			elastiCacheProperties.NumCacheNodes = 2
+
+			err := elastiCacheProperties.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("non-replicated redis clusters must have 1 node"))
+		})
+
+		It("returns error if a memcached plan has snapshot settings", func() {
+			elastiCacheProperties.Engine = "memcached"
+			elastiCacheProperties.EngineVersion = "1.4.24"
+			elastiCacheProperties.NumCacheNodes = 3
+			elastiCacheProperties.SnapshotWindow = "05:00-09:00"
+
+			err := elastiCacheProperties.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("only supported by the redis engine"))
+		})
+
+		It("returns error if a memcached plan has too many nodes", func() {
+			elastiCacheProperties.Engine = "memcached"
+			elastiCacheProperties.NumCacheNodes = 21
+
+			err := elastiCacheProperties.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("memcached clusters support at most 20 nodes"))
+		})
+
+		It("returns error if PreferredMaintenanceWindow is not valid", func() {
+			elastiCacheProperties.PreferredMaintenanceWindow = "sunday 05:00"
+
+			err := elastiCacheProperties.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid PreferredMaintenanceWindow 'sunday 05:00'"))
+		})
+
+		It("returns error if SnapshotWindow is not valid", func() {
+			elastiCacheProperties.SnapshotWindow = "25:00-26:00"
+
+			err := elastiCacheProperties.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid SnapshotWindow '25:00-26:00'"))
+		})
+
+		It("returns error if both CacheSubnetGroupName and SubnetIDs are set", func() {
+			elastiCacheProperties.CacheSubnetGroupName = "subnet-group"
+			elastiCacheProperties.SubnetIDs = []string{"subnet-1"}
+
+			err := elastiCacheProperties.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Must provide either a CacheSubnetGroupName or SubnetIDs"))
+		})
+
+		It("returns error if a CacheSecurityGroup is not a security group ID", func() {
+			elastiCacheProperties.CacheSecurityGroups = []string{"default"}
+
+			err := elastiCacheProperties.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid CacheSecurityGroup 'default', must be a VPC security group ID"))
+		})
+
+		It("returns error if a SubnetID is not valid", func() {
+			elastiCacheProperties.SubnetIDs = []string{"sg-1"}
+
+			err := elastiCacheProperties.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid SubnetID 'sg-1'"))
+		})
+
+		It("returns error if InstanceSecurityGroup is set without a subnet group", func() {
+			elastiCacheProperties.InstanceSecurityGroup = true
+			elastiCacheProperties.InstanceSecurityGroupCIDRs = []string{"10.0.16.0/20"}
+
+			err := elastiCacheProperties.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Must provide a CacheSubnetGroupName or SubnetIDs when using an InstanceSecurityGroup"))
+		})
+
+		It("returns error if InstanceSecurityGroup is set without CIDRs", func() {
+			elastiCacheProperties.InstanceSecurityGroup = true
+			elastiCacheProperties.CacheSubnetGroupName = "subnet-group"
+
+			err := elastiCacheProperties.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Must provide at least one InstanceSecurityGroupCIDR"))
+		})
+
+		It("returns error if an InstanceSecurityGroupCIDR is not valid", func() {
+			elastiCacheProperties.InstanceSecurityGroup = true
+			elastiCacheProperties.CacheSubnetGroupName = "subnet-group"
+			elastiCacheProperties.InstanceSecurityGroupCIDRs = []string{"10.0.16.0"}
+
+			err := elastiCacheProperties.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid InstanceSecurityGroupCIDR '10.0.16.0'"))
+		})
 	})
 })
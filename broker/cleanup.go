@@ -0,0 +1,185 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+
+	"github.com/cloudfoundry-community/elasticache-broker/awsec2"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+// failedProvisionStatuses are the cache cluster statuses a provision does not recover from.
+var failedProvisionStatuses = map[string]bool{
+	"create-failed":        true,
+	"incompatible-network": true,
+	"restore-failed":       true,
+}
+
+// RunFailedProvisionCleanup detects failed provisions and cleans up after them periodically,
+// until stop is closed.
+func (b *ElastiCacheBroker) RunFailedProvisionCleanup(stop <-chan struct{}) {
+	b.runPeriodically(b.pendingDeleteInterval, stop, "cleanup-failed-provisions", b.CleanupFailedProvisions)
+}
+
+// CleanupFailedProvisions records the failure of the provisions that failed without the
+// platform asking for their last operation, and deletes the cache clusters and instance
+// security groups left behind by failed provisions.
+func (b *ElastiCacheBroker) CleanupFailedProvisions() error {
+	instances, err := b.store.ListInstances()
+	if err != nil {
+		return err
+	}
+
+	for _, instance := range instances {
+		if err = b.cleanupFailedProvision(instance); err != nil {
+			b.logger.Error("cleanup-failed-provision", err, lager.Data{instanceIDLogKey: instance.ID, "cache-cluster-id": instance.CacheClusterID})
+		}
+	}
+
+	return nil
+}
+
+func (b *ElastiCacheBroker) cleanupFailedProvision(instance store.Instance) error {
+	if instance.Failure != nil {
+		if instance.Failure.CleanedUp {
+			return nil
+		}
+		return b.deleteFailedProvisionResources(instance.ID)
+	}
+
+	if instance.Operation == nil || instance.Operation.Type != store.OperationProvision {
+		return nil
+	}
+
+	if workflow := instance.Operation.Workflow; workflow != nil {
+		switch workflow.State {
+		case store.WorkflowFailed:
+			return b.failProvision(instance.ID, workflow.Error)
+		case store.WorkflowPending, store.WorkflowRunning:
+			return nil
+		}
+	}
+
+	cacheClusterDetails, err := b.cacheCluster.Describe(instance.CacheClusterID)
+	if err != nil {
+		if err == awselasticache.ErrCacheClusterDoesNotExist {
+			return nil
+		}
+		return err
+	}
+
+	if reason, failed := failedProvisionReason(instance.CacheClusterID, cacheClusterDetails); failed {
+		return b.failProvision(instance.ID, reason)
+	}
+
+	return nil
+}
+
+// isProvisioning reports whether the service instance has a provision in progress.
+func (b *ElastiCacheBroker) isProvisioning(instanceID string) bool {
+	instance, err := b.store.GetInstance(instanceID)
+	if err != nil {
+		return false
+	}
+
+	return instance.Operation != nil && instance.Operation.Type == store.OperationProvision
+}
+
+func failedProvisionReason(cacheClusterID string, cacheClusterDetails awselasticache.CacheClusterDetails) (string, bool) {
+	if !failedProvisionStatuses[cacheClusterDetails.Status] {
+		return "", false
+	}
+
+	return fmt.Sprintf("Cache Cluster '%s' could not be created: status is '%s'", cacheClusterID, cacheClusterDetails.Status), true
+}
+
+// failProvision records the failure of the provision of a service instance, releasing the
+// service instance, and starts deleting the resources left behind.
+func (b *ElastiCacheBroker) failProvision(instanceID string, reason string) error {
+	err := b.storeInstance(instanceID, func(instance *store.Instance) {
+		instance.Operation = nil
+		instance.Failure = &store.Failure{Reason: reason, FailedAt: time.Now()}
+	})
+	if err != nil {
+		return err
+	}
+
+	b.logger.Error("provision-failed", errors.New(reason), lager.Data{instanceIDLogKey: instanceID})
+
+	return b.deleteFailedProvisionResources(instanceID)
+}
+
+// deleteFailedProvisionResources deletes the cache cluster left behind by a failed provision
+// and, once it is gone, its instance security group. It is run until both are deleted. A cache
+// cluster still being created, e.g. by a timed out provision, cannot be deleted yet.
+func (b *ElastiCacheBroker) deleteFailedProvisionResources(instanceID string) error {
+	instance, err := b.store.GetInstance(instanceID)
+	if err != nil {
+		return err
+	}
+
+	cacheClusterDetails, err := b.cacheCluster.Describe(instance.CacheClusterID)
+	if err == nil {
+		if cacheClusterDetails.Status == cacheClusterStatusCreating || cacheClusterDetails.Status == cacheClusterStatusDeleting {
+			return nil
+		}
+
+		tags, err := b.cacheCluster.ListTags(instance.CacheClusterID)
+		if err != nil {
+			return err
+		}
+		if err = b.checkOwnership(instanceID, instance.CacheClusterID, tags); err != nil {
+			return err
+		}
+		if err = b.cacheCluster.Delete(instance.CacheClusterID); err != nil {
+			return err
+		}
+
+		b.logger.Info("deleting-failed-provision-cache-cluster", lager.Data{instanceIDLogKey: instanceID, "cache-cluster-id": instance.CacheClusterID})
+		return nil
+	}
+	if err != awselasticache.ErrCacheClusterDoesNotExist {
+		return err
+	}
+
+	if err = b.deleteInstanceSecurityGroup(instanceID); err != nil {
+		if err == awsec2.ErrSecurityGroupInUse {
+			return nil
+		}
+		return err
+	}
+
+	err = b.storeInstance(instanceID, func(instance *store.Instance) {
+		if instance.Failure != nil {
+			instance.Failure.CleanedUp = true
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	b.logger.Info("cleaned-up-failed-provision", lager.Data{instanceIDLogKey: instanceID})
+
+	return nil
+}
+
+// runPeriodically runs a background task every interval, until stop is closed.
+func (b *ElastiCacheBroker) runPeriodically(interval time.Duration, stop <-chan struct{}, action string, task func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := task(); err != nil {
+				b.logger.Error(action, err)
+			}
+		}
+	}
+}
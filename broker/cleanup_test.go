@@ -0,0 +1,175 @@
+package broker_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/frodenas/brokerapi"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	cwfakes "github.com/cloudfoundry-community/elasticache-broker/awscloudwatch/fakes"
+	"github.com/cloudfoundry-community/elasticache-broker/awsec2"
+	ec2fakes "github.com/cloudfoundry-community/elasticache-broker/awsec2/fakes"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache/fakes"
+	. "github.com/cloudfoundry-community/elasticache-broker/broker"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+var _ = Describe("Failed Provision Cleanup", func() {
+	const instanceID = "ce71b484-d542-40f7-9dd4-5526e38c81ba"
+
+	var (
+		cacheCluster  *fakes.FakeCacheCluster
+		securityGroup *ec2fakes.FakeSecurityGroup
+		brokerStore   store.Store
+
+		elastiCacheBroker *ElastiCacheBroker
+	)
+
+	setCacheClusterStatus := func(status string) {
+		cacheCluster.DescribeCacheClusters["cf-aso4rtfujlvj"] = awselasticache.CacheClusterDetails{
+			CacheClusterId: "cf-aso4rtfujlvj",
+			Status:         status,
+			Engine:         "redis",
+			Tags:           map[string]string{"Instance ID": instanceID, "Broker ID": "cf"},
+		}
+	}
+
+	recordedInstance := func() store.Instance {
+		instance, err := brokerStore.GetInstance(instanceID)
+		Expect(err).ToNot(HaveOccurred())
+		return instance
+	}
+
+	BeforeEach(func() {
+		cacheCluster = &fakes.FakeCacheCluster{
+			DescribeCacheClusters: map[string]awselasticache.CacheClusterDetails{},
+		}
+		setCacheClusterStatus("create-failed")
+		securityGroup = &ec2fakes.FakeSecurityGroup{
+			FindByNameSecurityGroupDetails: awsec2.SecurityGroupDetails{ID: "sg-1"},
+		}
+		brokerStore = store.NewMemoryStore()
+		Expect(brokerStore.PutInstance(store.Instance{
+			ID:             instanceID,
+			ServiceID:      "Service-1",
+			PlanID:         "Plan-1",
+			OrganizationID: "organization-id",
+			SpaceID:        "space-id",
+			CacheClusterID: "cf-aso4rtfujlvj",
+			Operation:      &store.Operation{Type: store.OperationProvision, StartedAt: time.Now()},
+		})).To(Succeed())
+	})
+
+	JustBeforeEach(func() {
+		config := Config{
+			Region:      "elasticache-region",
+			CachePrefix: "cf",
+			Catalog: Catalog{
+				Services: []Service{
+					Service{
+						ID: "Service-1",
+						Plans: []ServicePlan{
+							ServicePlan{
+								ID: "Plan-1",
+								ElastiCacheProperties: ElastiCacheProperties{
+									CacheInstanceClass:    "cache.t2.micro",
+									Engine:                "redis",
+									InstanceSecurityGroup: true,
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		elastiCacheBroker = New(config, cacheCluster, &fakes.FakeCacheSubnetGroup{}, securityGroup, &cwfakes.FakeCacheClusterMetrics{}, nil, brokerStore, lagertest.NewTestLogger("broker_test"))
+	})
+
+	It("reports a provision whose cache cluster could not be created as failed", func() {
+		lastOperationResponse, err := elastiCacheBroker.LastOperation(instanceID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lastOperationResponse.State).To(Equal(brokerapi.LastOperationFailed))
+		Expect(lastOperationResponse.Description).To(Equal("Cache Cluster 'cf-aso4rtfujlvj' could not be created: status is 'create-failed'"))
+
+		instance := recordedInstance()
+		Expect(instance.Operation).To(BeNil())
+		Expect(instance.Failure.Reason).To(Equal(lastOperationResponse.Description))
+		Expect(instance.Failure.CleanedUp).To(BeFalse())
+	})
+
+	It("deletes the cache cluster left behind", func() {
+		_, err := elastiCacheBroker.LastOperation(instanceID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cacheCluster.DeleteCalled).To(BeTrue())
+		Expect(cacheCluster.DeleteID).To(Equal("cf-aso4rtfujlvj"))
+		Expect(securityGroup.DeleteCalled).To(BeFalse())
+	})
+
+	It("detects failed provisions in the background", func() {
+		Expect(elastiCacheBroker.CleanupFailedProvisions()).To(Succeed())
+		Expect(cacheCluster.DeleteCalled).To(BeTrue())
+		Expect(recordedInstance().Failure).ToNot(BeNil())
+	})
+
+	It("does not touch provisions in progress", func() {
+		setCacheClusterStatus("creating")
+
+		Expect(elastiCacheBroker.CleanupFailedProvisions()).To(Succeed())
+		Expect(cacheCluster.DeleteCalled).To(BeFalse())
+		Expect(recordedInstance().Failure).To(BeNil())
+	})
+
+	Context("when the cache cluster left behind is deleted", func() {
+		JustBeforeEach(func() {
+			_, err := elastiCacheBroker.LastOperation(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			delete(cacheCluster.DescribeCacheClusters, "cf-aso4rtfujlvj")
+		})
+
+		It("deletes the instance security group and keeps reporting the failure", func() {
+			Expect(elastiCacheBroker.CleanupFailedProvisions()).To(Succeed())
+			Expect(securityGroup.DeleteCalled).To(BeTrue())
+			Expect(securityGroup.DeleteID).To(Equal("sg-1"))
+			Expect(recordedInstance().Failure.CleanedUp).To(BeTrue())
+
+			lastOperationResponse, err := elastiCacheBroker.LastOperation(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lastOperationResponse.State).To(Equal(brokerapi.LastOperationFailed))
+		})
+
+		It("waits while the instance security group is still in use", func() {
+			securityGroup.DeleteError = awsec2.ErrSecurityGroupInUse
+
+			Expect(elastiCacheBroker.CleanupFailedProvisions()).To(Succeed())
+			Expect(recordedInstance().Failure.CleanedUp).To(BeFalse())
+		})
+
+		It("rejects provisions until the cleanup is done", func() {
+			provisionDetails := brokerapi.ProvisionDetails{
+				ServiceID:        "Service-1",
+				PlanID:           "Plan-1",
+				OrganizationGUID: "organization-id",
+				SpaceGUID:        "space-id",
+			}
+
+			securityGroup.DeleteError = awsec2.ErrSecurityGroupInUse
+			Expect(elastiCacheBroker.CleanupFailedProvisions()).To(Succeed())
+			_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("failed provision are being deleted"))
+
+			securityGroup.DeleteError = nil
+			Expect(elastiCacheBroker.CleanupFailedProvisions()).To(Succeed())
+			_, asynch, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(asynch).To(BeTrue())
+			Expect(elastiCacheBroker.ResumeWorkflows()).To(Succeed())
+			Expect(cacheCluster.CreateCalled).To(BeTrue())
+			Expect(recordedInstance().Failure).To(BeNil())
+		})
+	})
+})
@@ -3,14 +3,21 @@ package broker
 import (
 	"errors"
 	"fmt"
+	"unicode/utf8"
 )
 
 type Config struct {
-	Region                       string  `json:"region"`
-	CachePrefix                  string  `json:"cache_prefix"`
-	AllowUserProvisionParameters bool    `json:"allow_user_provision_parameters"`
-	AllowUserUpdateParameters    bool    `json:"allow_user_update_parameters"`
-	Catalog                      Catalog `json:"catalog"`
+	Region                          string            `json:"region"`
+	CachePrefix                     string            `json:"cache_prefix"`
+	BrokerID                        string            `json:"broker_id,omitempty"`
+	AllowUserProvisionParameters    bool              `json:"allow_user_provision_parameters"`
+	AllowUserUpdateParameters       bool              `json:"allow_user_update_parameters"`
+	ManageApplicationSecurityGroups bool              `json:"manage_application_security_groups"`
+	CostAllocationTags              map[string]string `json:"cost_allocation_tags,omitempty"`
+	AllowedUserTagKeys              []string          `json:"allowed_user_tag_keys,omitempty"`
+	AllowedUnownedCacheClusterIDs   []string          `json:"allowed_unowned_cache_cluster_ids,omitempty"`
+	PendingDeleteInterval           int               `json:"pending_delete_interval,omitempty"`
+	Catalog                         Catalog           `json:"catalog"`
 }
 
 func (c Config) Validate() error {
@@ -22,6 +29,34 @@ func (c Config) Validate() error {
 		return errors.New("Must provide a non-empty CachePrefix")
 	}
 
+	if !cachePrefixPattern.MatchString(c.CachePrefix) {
+		return fmt.Errorf("Invalid CachePrefix '%s': must start with a letter and contain only letters, digits and hyphens", c.CachePrefix)
+	}
+
+	if utf8.RuneCountInString(c.BrokerID) > maxTagValueLength || !validTagCharacters.MatchString(c.BrokerID) {
+		return fmt.Errorf("Invalid BrokerID '%s': must be a valid tag value", c.BrokerID)
+	}
+
+	if len(c.CostAllocationTags)+len(brokerTagKeys) > maxTagsPerResource {
+		return fmt.Errorf("Invalid CostAllocationTags: at most %d tags are allowed", maxTagsPerResource-len(brokerTagKeys))
+	}
+
+	for key, value := range c.CostAllocationTags {
+		if err := checkTag(key, value); err != nil {
+			return fmt.Errorf("Invalid CostAllocationTags: %s", err)
+		}
+	}
+
+	for _, key := range c.AllowedUserTagKeys {
+		if key == "" {
+			return errors.New("Must provide non-empty AllowedUserTagKeys entries")
+		}
+	}
+
+	if c.PendingDeleteInterval < 0 {
+		return fmt.Errorf("Invalid PendingDeleteInterval '%d': must not be negative", c.PendingDeleteInterval)
+	}
+
 	if err := c.Catalog.Validate(); err != nil {
 		return fmt.Errorf("Validating Catalog configuration: %s", err)
 	}
@@ -12,7 +12,7 @@ var _ = Describe("Config", func() {
 		config Config
 
 		validConfig = Config{
-			Region:    "elasticache-region",
+			Region:      "elasticache-region",
 			CachePrefix: "cf",
 			Catalog: Catalog{
 				[]Service{
@@ -52,6 +52,54 @@ var _ = Describe("Config", func() {
 			Expect(err.Error()).To(ContainSubstring("Must provide a non-empty CachePrefix"))
 		})
 
+		It("returns error if CachePrefix contains invalid characters", func() {
+			config.CachePrefix = "1cf_broker"
+
+			err := config.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid CachePrefix '1cf_broker'"))
+		})
+
+		It("returns error if BrokerID is not a valid tag value", func() {
+			config.BrokerID = "broker#1"
+
+			err := config.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid BrokerID 'broker#1'"))
+		})
+
+		It("returns error if a CostAllocationTags value contains invalid characters", func() {
+			config.CostAllocationTags = map[string]string{"Cost Center": "R&D"}
+
+			err := config.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid CostAllocationTags: Tag 'Cost Center' contains invalid characters"))
+		})
+
+		It("returns error if a CostAllocationTags key uses the reserved AWS prefix", func() {
+			config.CostAllocationTags = map[string]string{"aws:cost-center": "1234"}
+
+			err := config.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid CostAllocationTags: Tag key 'aws:cost-center' uses the reserved 'aws:' prefix"))
+		})
+
+		It("returns error if AllowedUserTagKeys contains an empty key", func() {
+			config.AllowedUserTagKeys = []string{"Cost Center", ""}
+
+			err := config.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Must provide non-empty AllowedUserTagKeys entries"))
+		})
+
+		It("returns error if PendingDeleteInterval is negative", func() {
+			config.PendingDeleteInterval = -1
+
+			err := config.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid PendingDeleteInterval '-1'"))
+		})
+
 		It("returns error if Catalog is not valid", func() {
 			config.Catalog = Catalog{
 				[]Service{
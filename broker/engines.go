@@ -0,0 +1,79 @@
+package broker
+
+import (
+	"regexp"
+)
+
+const (
+	engineMemcached = "memcached"
+	engineRedis     = "redis"
+)
+
+var supportedEngines = []string{engineMemcached, engineRedis}
+
+// maxMemcachedCacheNodes is the default ElastiCache limit of nodes per memcached cluster.
+const maxMemcachedCacheNodes = 20
+
+var (
+	engineVersionPattern     = regexp.MustCompile(`^[0-9]+\.[0-9]+(\.[0-9]+)?$`)
+	cacheNodeTypePattern     = regexp.MustCompile(`^cache\.[a-z]+[0-9]+[a-z]*\.[0-9a-z]+$`)
+	maintenanceWindowPattern = regexp.MustCompile(`^(sun|mon|tue|wed|thu|fri|sat):([01][0-9]|2[0-3]):[0-5][0-9]-(sun|mon|tue|wed|thu|fri|sat):([01][0-9]|2[0-3]):[0-5][0-9]$`)
+	snapshotWindowPattern    = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]-([01][0-9]|2[0-3]):[0-5][0-9]$`)
+)
+
+var defaultEnginePorts = map[string]int64{
+	engineMemcached: 11211,
+	engineRedis:     6379,
+}
+
+// cacheNodeTypeMemory is the memory, in GiB, available for data on each known cache node type.
+// Plans using other node types set it with the cache_node_memory property.
+var cacheNodeTypeMemory = map[string]float64{
+	"cache.t1.micro":    0.213,
+	"cache.t2.micro":    0.555,
+	"cache.t2.small":    1.55,
+	"cache.t2.medium":   3.22,
+	"cache.t3.micro":    0.5,
+	"cache.t3.small":    1.37,
+	"cache.t3.medium":   3.09,
+	"cache.m1.small":    1.3,
+	"cache.m1.medium":   3.35,
+	"cache.m1.large":    7.1,
+	"cache.m1.xlarge":   14.6,
+	"cache.m2.xlarge":   16.7,
+	"cache.m2.2xlarge":  33.8,
+	"cache.m2.4xlarge":  68,
+	"cache.m3.medium":   2.78,
+	"cache.m3.large":    6.05,
+	"cache.m3.xlarge":   13.3,
+	"cache.m3.2xlarge":  27.9,
+	"cache.m4.large":    6.42,
+	"cache.m4.xlarge":   14.28,
+	"cache.m4.2xlarge":  29.7,
+	"cache.m4.4xlarge":  60.78,
+	"cache.m4.10xlarge": 154.64,
+	"cache.m5.large":    6.38,
+	"cache.m5.xlarge":   12.93,
+	"cache.m5.2xlarge":  26.04,
+	"cache.m5.4xlarge":  52.26,
+	"cache.m5.12xlarge": 157.12,
+	"cache.m5.24xlarge": 314.32,
+	"cache.c1.xlarge":   6.6,
+	"cache.r3.large":    13.5,
+	"cache.r3.xlarge":   28.4,
+	"cache.r3.2xlarge":  58.2,
+	"cache.r3.4xlarge":  118,
+	"cache.r3.8xlarge":  237,
+	"cache.r4.large":    12.3,
+	"cache.r4.xlarge":   25.05,
+	"cache.r4.2xlarge":  50.47,
+	"cache.r4.4xlarge":  101.38,
+	"cache.r4.8xlarge":  203.26,
+	"cache.r4.16xlarge": 407,
+	"cache.r5.large":    13.07,
+	"cache.r5.xlarge":   26.32,
+	"cache.r5.2xlarge":  52.82,
+	"cache.r5.4xlarge":  105.81,
+	"cache.r5.12xlarge": 317.77,
+	"cache.r5.24xlarge": 635.61,
+}
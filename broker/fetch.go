@@ -0,0 +1,108 @@
+package broker
+
+import (
+	"fmt"
+
+	"github.com/frodenas/brokerapi"
+
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+// FetchInstance returns the plan and parameters of a service instance. Service instances still
+// being provisioned, or whose provision failed, do not exist yet, and those being updated cannot
+// be fetched until the update completes. Service instances missing from the store are looked up
+// by their cache cluster tags.
+func (b *ElastiCacheBroker) FetchInstance(instanceID string) (api.FetchInstanceResponse, error) {
+	fetchInstanceResponse := api.FetchInstanceResponse{}
+
+	instance, err := b.store.GetInstance(instanceID)
+	if err != nil {
+		if err == store.ErrInstanceNotFound {
+			return b.fetchUnrecordedInstance(instanceID)
+		}
+		return fetchInstanceResponse, err
+	}
+
+	if instance.Failure != nil {
+		return fetchInstanceResponse, brokerapi.ErrInstanceDoesNotExist
+	}
+
+	if instance.Operation != nil {
+		switch instance.Operation.Type {
+		case store.OperationProvision:
+			return fetchInstanceResponse, brokerapi.ErrInstanceDoesNotExist
+		case store.OperationUpdate:
+			err = fmt.Errorf("Cannot fetch service instance '%s': an update is in progress", instanceID)
+			return fetchInstanceResponse, api.NewConcurrencyErrorResponse(err, "concurrent-operation")
+		}
+	}
+
+	fetchInstanceResponse.ServiceID = instance.ServiceID
+	fetchInstanceResponse.PlanID = instance.PlanID
+	fetchInstanceResponse.Parameters = instance.Parameters
+
+	return fetchInstanceResponse, nil
+}
+
+func (b *ElastiCacheBroker) fetchUnrecordedInstance(instanceID string) (api.FetchInstanceResponse, error) {
+	fetchInstanceResponse := api.FetchInstanceResponse{}
+
+	cacheClusterID, _, err := b.describeCacheCluster(instanceID)
+	if err != nil {
+		if err == awselasticache.ErrCacheClusterDoesNotExist {
+			return fetchInstanceResponse, brokerapi.ErrInstanceDoesNotExist
+		}
+		return fetchInstanceResponse, err
+	}
+
+	tags, err := b.cacheCluster.ListTags(cacheClusterID)
+	if err != nil {
+		return fetchInstanceResponse, err
+	}
+
+	if err = b.checkOwnership(instanceID, cacheClusterID, tags); err != nil {
+		return fetchInstanceResponse, err
+	}
+
+	fetchInstanceResponse.ServiceID = tags["Service ID"]
+	fetchInstanceResponse.PlanID = tags["Plan ID"]
+
+	return fetchInstanceResponse, nil
+}
+
+// FetchBinding returns the credentials and parameters of a service binding. Bindings recorded
+// without their credentials get them from the cache cluster.
+func (b *ElastiCacheBroker) FetchBinding(instanceID, bindingID string) (api.FetchBindingResponse, error) {
+	fetchBindingResponse := api.FetchBindingResponse{}
+
+	binding, err := b.store.GetBinding(instanceID, bindingID)
+	if err != nil {
+		if err == store.ErrBindingNotFound {
+			return fetchBindingResponse, brokerapi.ErrBindingDoesNotExist
+		}
+		return fetchBindingResponse, err
+	}
+
+	credentials := binding.Credentials
+	if credentials == nil {
+		cacheClusterID, cacheClusterDetails, err := b.describeCacheCluster(instanceID)
+		if err != nil {
+			if err == awselasticache.ErrCacheClusterDoesNotExist {
+				return fetchBindingResponse, brokerapi.ErrInstanceDoesNotExist
+			}
+			return fetchBindingResponse, err
+		}
+		credentials = &store.Credentials{Host: cacheClusterDetails.Endpoint, Port: cacheClusterDetails.Port, Name: cacheClusterID}
+	}
+
+	fetchBindingResponse.Credentials = &brokerapi.CredentialsHash{
+		Host: credentials.Host,
+		Port: credentials.Port,
+		Name: credentials.Name,
+	}
+	fetchBindingResponse.Parameters = binding.Parameters
+
+	return fetchBindingResponse, nil
+}
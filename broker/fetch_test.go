@@ -0,0 +1,177 @@
+package broker_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/frodenas/brokerapi"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+	cwfakes "github.com/cloudfoundry-community/elasticache-broker/awscloudwatch/fakes"
+	ec2fakes "github.com/cloudfoundry-community/elasticache-broker/awsec2/fakes"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache/fakes"
+	. "github.com/cloudfoundry-community/elasticache-broker/broker"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+var _ = Describe("Fetch", func() {
+	const instanceID = "ce71b484-d542-40f7-9dd4-5526e38c81ba"
+
+	var (
+		cacheCluster *fakes.FakeCacheCluster
+		brokerStore  store.Store
+		instance     store.Instance
+
+		elastiCacheBroker *ElastiCacheBroker
+	)
+
+	BeforeEach(func() {
+		cacheCluster = &fakes.FakeCacheCluster{
+			DescribeCacheClusters: map[string]awselasticache.CacheClusterDetails{
+				"cf-aso4rtfujlvj": awselasticache.CacheClusterDetails{
+					CacheClusterId: "cf-aso4rtfujlvj",
+					Status:         "available",
+					Endpoint:       "cache-host",
+					Port:           6379,
+					Engine:         "redis",
+					Tags: map[string]string{
+						"Instance ID": instanceID,
+						"Broker ID":   "cf",
+						"Service ID":  "Service-1",
+						"Plan ID":     "Plan-1",
+					},
+				},
+			},
+		}
+		brokerStore = store.NewMemoryStore()
+		instance = store.Instance{
+			ID:             instanceID,
+			ServiceID:      "Service-1",
+			PlanID:         "Plan-1",
+			CacheClusterID: "cf-aso4rtfujlvj",
+			Parameters:     map[string]interface{}{"engine_version": "2.8.24"},
+		}
+	})
+
+	JustBeforeEach(func() {
+		config := Config{
+			Region:      "elasticache-region",
+			CachePrefix: "cf",
+			Catalog: Catalog{
+				Services: []Service{
+					Service{
+						ID:       "Service-1",
+						Bindable: true,
+						Plans: []ServicePlan{
+							ServicePlan{
+								ID: "Plan-1",
+								ElastiCacheProperties: ElastiCacheProperties{
+									CacheInstanceClass: "cache.t2.micro",
+									Engine:             "redis",
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		elastiCacheBroker = New(config, cacheCluster, &fakes.FakeCacheSubnetGroup{}, &ec2fakes.FakeSecurityGroup{}, &cwfakes.FakeCacheClusterMetrics{}, nil, brokerStore, lagertest.NewTestLogger("broker_test"))
+	})
+
+	Describe("FetchInstance", func() {
+		JustBeforeEach(func() {
+			Expect(brokerStore.PutInstance(instance)).To(Succeed())
+		})
+
+		It("returns the plan and parameters of the service instance", func() {
+			fetchInstanceResponse, err := elastiCacheBroker.FetchInstance(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fetchInstanceResponse).To(Equal(api.FetchInstanceResponse{
+				ServiceID:  "Service-1",
+				PlanID:     "Plan-1",
+				Parameters: map[string]interface{}{"engine_version": "2.8.24"},
+			}))
+			Expect(cacheCluster.DescribeCalled).To(BeFalse())
+		})
+
+		Context("when the service instance is being provisioned", func() {
+			BeforeEach(func() {
+				instance.Operation = &store.Operation{Type: store.OperationProvision, StartedAt: time.Now()}
+			})
+
+			It("returns the proper error", func() {
+				_, err := elastiCacheBroker.FetchInstance(instanceID)
+				Expect(err).To(Equal(brokerapi.ErrInstanceDoesNotExist))
+			})
+		})
+
+		Context("when the service instance is being updated", func() {
+			BeforeEach(func() {
+				instance.Operation = &store.Operation{Type: store.OperationUpdate, StartedAt: time.Now()}
+			})
+
+			It("returns a ConcurrencyError", func() {
+				_, err := elastiCacheBroker.FetchInstance(instanceID)
+				Expect(err).To(HaveOccurred())
+				Expect(err.(*api.FailureResponse).ErrorResponse().Error).To(Equal("ConcurrencyError"))
+			})
+		})
+
+		Context("when the provision failed", func() {
+			BeforeEach(func() {
+				instance.Failure = &store.Failure{Reason: "create-failed", FailedAt: time.Now()}
+			})
+
+			It("returns the proper error", func() {
+				_, err := elastiCacheBroker.FetchInstance(instanceID)
+				Expect(err).To(Equal(brokerapi.ErrInstanceDoesNotExist))
+			})
+		})
+	})
+
+	Describe("FetchInstance of a service instance missing from the store", func() {
+		It("returns the plan from the cache cluster tags", func() {
+			fetchInstanceResponse, err := elastiCacheBroker.FetchInstance(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fetchInstanceResponse.ServiceID).To(Equal("Service-1"))
+			Expect(fetchInstanceResponse.PlanID).To(Equal("Plan-1"))
+		})
+
+		It("returns the proper error when the cache cluster does not exist", func() {
+			delete(cacheCluster.DescribeCacheClusters, "cf-aso4rtfujlvj")
+
+			_, err := elastiCacheBroker.FetchInstance(instanceID)
+			Expect(err).To(Equal(brokerapi.ErrInstanceDoesNotExist))
+		})
+	})
+
+	Describe("FetchBinding", func() {
+		It("returns the credentials stored at bind time", func() {
+			_, err := elastiCacheBroker.Bind(instanceID, "binding-id", brokerapi.BindDetails{ServiceID: "Service-1", PlanID: "Plan-1", AppGUID: "app-guid"})
+			Expect(err).ToNot(HaveOccurred())
+			cacheCluster.DescribeCalled = false
+
+			fetchBindingResponse, err := elastiCacheBroker.FetchBinding(instanceID, "binding-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fetchBindingResponse.Credentials).To(Equal(&brokerapi.CredentialsHash{Host: "cache-host", Port: 6379, Name: "cf-aso4rtfujlvj"}))
+			Expect(cacheCluster.DescribeCalled).To(BeFalse())
+		})
+
+		It("returns the cache cluster credentials of bindings recorded without them", func() {
+			Expect(brokerStore.PutBinding(store.Binding{ID: "binding-id", InstanceID: instanceID, CreatedAt: time.Now()})).To(Succeed())
+
+			fetchBindingResponse, err := elastiCacheBroker.FetchBinding(instanceID, "binding-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fetchBindingResponse.Credentials).To(Equal(&brokerapi.CredentialsHash{Host: "cache-host", Port: 6379, Name: "cf-aso4rtfujlvj"}))
+		})
+
+		It("returns the proper error when the binding does not exist", func() {
+			_, err := elastiCacheBroker.FetchBinding(instanceID, "binding-id")
+			Expect(err).To(Equal(brokerapi.ErrBindingDoesNotExist))
+		})
+	})
+})
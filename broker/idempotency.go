@@ -0,0 +1,131 @@
+package broker
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/frodenas/brokerapi"
+	"github.com/pivotal-golang/lager"
+
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+const (
+	cacheClusterStatusCreating = "creating"
+	cacheClusterStatusDeleting = "deleting"
+)
+
+// existingInstance checks a provision request against an already provisioned service instance.
+// It reports whether the service instance exists and whether its creation is still in progress,
+// or returns brokerapi.ErrInstanceAlreadyExists when the existing service instance has different
+// attributes. Service instances missing from the store are checked against their cache cluster
+// tags, and recorded.
+func (b *ElastiCacheBroker) existingInstance(instanceID string, details brokerapi.ProvisionDetails) (bool, bool, error) {
+	instance, err := b.store.GetInstance(instanceID)
+	if err == nil {
+		if instance.Failure != nil {
+			if !instance.Failure.CleanedUp {
+				err = fmt.Errorf("Cannot provision service instance '%s': the resources of its failed provision are being deleted", instanceID)
+				return true, false, api.NewConcurrencyErrorResponse(err, "concurrent-operation")
+			}
+			if err = b.forgetInstance(instanceID); err != nil {
+				return false, false, err
+			}
+			return false, false, nil
+		}
+		if instance.Operation != nil && instance.Operation.Type == store.OperationDeprovision {
+			return true, false, brokerapi.ErrInstanceAlreadyExists
+		}
+		if instance.ServiceID != details.ServiceID ||
+			instance.PlanID != details.PlanID ||
+			instance.OrganizationID != details.OrganizationGUID ||
+			instance.SpaceID != details.SpaceGUID ||
+			!equalParameters(instance.Parameters, details.Parameters) {
+			return true, false, brokerapi.ErrInstanceAlreadyExists
+		}
+		inProgress := instance.Operation != nil && instance.Operation.Type == store.OperationProvision
+		return true, inProgress, nil
+	}
+	if err != store.ErrInstanceNotFound {
+		return false, false, err
+	}
+
+	cacheClusterID, cacheClusterDetails, err := b.describeCacheCluster(instanceID)
+	if err != nil {
+		if err == awselasticache.ErrCacheClusterDoesNotExist {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+
+	if cacheClusterDetails.Status == cacheClusterStatusDeleting {
+		return true, false, brokerapi.ErrInstanceAlreadyExists
+	}
+
+	tags, err := b.cacheCluster.ListTags(cacheClusterID)
+	if err != nil {
+		return true, false, err
+	}
+
+	requestedTags := map[string]string{
+		"Service ID":      details.ServiceID,
+		"Plan ID":         details.PlanID,
+		"Organization ID": details.OrganizationGUID,
+		"Space ID":        details.SpaceGUID,
+	}
+	for key, value := range requestedTags {
+		if currentValue, ok := tags[key]; ok && currentValue != value {
+			return true, false, brokerapi.ErrInstanceAlreadyExists
+		}
+	}
+
+	inProgress := cacheClusterDetails.Status == cacheClusterStatusCreating
+	err = b.storeInstance(instanceID, func(instance *store.Instance) {
+		instance.ServiceID = details.ServiceID
+		instance.PlanID = details.PlanID
+		instance.OrganizationID = details.OrganizationGUID
+		instance.SpaceID = details.SpaceGUID
+		instance.CacheClusterID = cacheClusterID
+		instance.Parameters = details.Parameters
+		if inProgress {
+			startOperation(instance, store.OperationProvision)
+		}
+	})
+	if err != nil {
+		return true, false, err
+	}
+
+	b.logger.Info("recorded-existing-instance", lager.Data{instanceIDLogKey: instanceID, "cache-cluster-id": cacheClusterID})
+
+	return true, inProgress, nil
+}
+
+// existingBinding checks a bind request against an already recorded service binding. It reports
+// whether the service binding exists, or returns brokerapi.ErrBindingAlreadyExists when the
+// existing service binding has different attributes.
+func (b *ElastiCacheBroker) existingBinding(instanceID, bindingID string, details brokerapi.BindDetails) (bool, error) {
+	binding, err := b.store.GetBinding(instanceID, bindingID)
+	if err != nil {
+		if err == store.ErrBindingNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if binding.AppGUID != details.AppGUID || !equalParameters(binding.Parameters, details.Parameters) {
+		return true, brokerapi.ErrBindingAlreadyExists
+	}
+
+	return true, nil
+}
+
+// equalParameters compares request parameters, treating missing and empty parameters as equal.
+func equalParameters(parameters map[string]interface{}, otherParameters map[string]interface{}) bool {
+	if len(parameters) == 0 && len(otherParameters) == 0 {
+		return true
+	}
+
+	return reflect.DeepEqual(parameters, otherParameters)
+}
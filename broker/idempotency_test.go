@@ -0,0 +1,216 @@
+package broker_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/frodenas/brokerapi"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+	cwfakes "github.com/cloudfoundry-community/elasticache-broker/awscloudwatch/fakes"
+	ec2fakes "github.com/cloudfoundry-community/elasticache-broker/awsec2/fakes"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache/fakes"
+	. "github.com/cloudfoundry-community/elasticache-broker/broker"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+var _ = Describe("Idempotency", func() {
+	const instanceID = "ce71b484-d542-40f7-9dd4-5526e38c81ba"
+
+	var (
+		cacheCluster *fakes.FakeCacheCluster
+		brokerStore  *store.MemoryStore
+
+		provisionDetails brokerapi.ProvisionDetails
+
+		elastiCacheBroker *ElastiCacheBroker
+	)
+
+	BeforeEach(func() {
+		cacheCluster = &fakes.FakeCacheCluster{
+			DescribeCacheClusters: map[string]awselasticache.CacheClusterDetails{},
+		}
+		brokerStore = store.NewMemoryStore()
+		provisionDetails = brokerapi.ProvisionDetails{
+			ServiceID:        "Service-1",
+			PlanID:           "Plan-1",
+			OrganizationGUID: "organization-id",
+			SpaceGUID:        "space-id",
+			Parameters:       map[string]interface{}{"engine_version": "2.8.24"},
+		}
+	})
+
+	JustBeforeEach(func() {
+		config := Config{
+			Region:                       "elasticache-region",
+			CachePrefix:                  "cf",
+			AllowUserProvisionParameters: true,
+			Catalog: Catalog{
+				Services: []Service{
+					Service{
+						ID:       "Service-1",
+						Bindable: true,
+						Plans: []ServicePlan{
+							ServicePlan{
+								ID: "Plan-1",
+								ElastiCacheProperties: ElastiCacheProperties{
+									CacheInstanceClass: "cache.t2.micro",
+									Engine:             "redis",
+								},
+							},
+							ServicePlan{
+								ID: "Plan-2",
+								ElastiCacheProperties: ElastiCacheProperties{
+									CacheInstanceClass: "cache.m3.medium",
+									Engine:             "redis",
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		elastiCacheBroker = New(config, cacheCluster, &fakes.FakeCacheSubnetGroup{}, &ec2fakes.FakeSecurityGroup{}, &cwfakes.FakeCacheClusterMetrics{}, nil, brokerStore, lagertest.NewTestLogger("broker_test"))
+	})
+
+	addCacheCluster := func(status string, tags map[string]string) {
+		cacheCluster.DescribeCacheClusters["cf-aso4rtfujlvj"] = awselasticache.CacheClusterDetails{
+			CacheClusterId: "cf-aso4rtfujlvj",
+			Status:         status,
+			Engine:         "redis",
+			Endpoint:       "cache-endpoint",
+			Port:           6379,
+			Tags:           tags,
+		}
+	}
+
+	Describe("Provision", func() {
+		Context("when the service instance is being created", func() {
+			JustBeforeEach(func() {
+				_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(elastiCacheBroker.ResumeWorkflows()).To(Succeed())
+				addCacheCluster("creating", cacheCluster.CreateCacheClusterDetails.Tags)
+				cacheCluster.CreateCalled = false
+			})
+
+			It("accepts identical requests without creating another cache cluster", func() {
+				_, asynch, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(asynch).To(BeTrue())
+				Expect(cacheCluster.CreateCalled).To(BeFalse())
+			})
+
+			It("reports identical requests once the service instance is created", func() {
+				_, err := elastiCacheBroker.LastOperation(instanceID)
+				Expect(err).ToNot(HaveOccurred())
+				addCacheCluster("available", nil)
+				_, err = elastiCacheBroker.LastOperation(instanceID)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, asynch, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).To(Equal(api.ErrInstanceAlreadyProvisioned))
+				Expect(asynch).To(BeFalse())
+				Expect(cacheCluster.CreateCalled).To(BeFalse())
+			})
+
+			It("rejects requests with a different plan", func() {
+				provisionDetails.PlanID = "Plan-2"
+
+				_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).To(Equal(brokerapi.ErrInstanceAlreadyExists))
+				Expect(cacheCluster.CreateCalled).To(BeFalse())
+			})
+
+			It("rejects requests with different parameters", func() {
+				provisionDetails.Parameters = map[string]interface{}{"engine_version": "3.2.4"}
+
+				_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).To(Equal(brokerapi.ErrInstanceAlreadyExists))
+			})
+		})
+
+		Context("when the cache cluster exists but the service instance is not recorded", func() {
+			BeforeEach(func() {
+				addCacheCluster("available", map[string]string{
+					"Instance ID":     instanceID,
+					"Broker ID":       "cf",
+					"Service ID":      "Service-1",
+					"Plan ID":         "Plan-1",
+					"Organization ID": "organization-id",
+					"Space ID":        "space-id",
+				})
+			})
+
+			It("accepts identical requests and records the service instance", func() {
+				_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).To(Equal(api.ErrInstanceAlreadyProvisioned))
+				Expect(cacheCluster.CreateCalled).To(BeFalse())
+
+				instance, err := brokerStore.GetInstance(instanceID)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(instance.CacheClusterID).To(Equal("cf-aso4rtfujlvj"))
+			})
+
+			It("rejects requests whose attributes differ from the cache cluster tags", func() {
+				provisionDetails.SpaceGUID = "other-space-id"
+
+				_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+				Expect(err).To(Equal(brokerapi.ErrInstanceAlreadyExists))
+				Expect(brokerStore.ListInstances()).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Bind", func() {
+		var bindDetails brokerapi.BindDetails
+
+		BeforeEach(func() {
+			addCacheCluster("available", map[string]string{"Instance ID": instanceID, "Broker ID": "cf"})
+			bindDetails = brokerapi.BindDetails{ServiceID: "Service-1", PlanID: "Plan-1", AppGUID: "app-guid"}
+		})
+
+		JustBeforeEach(func() {
+			_, err := elastiCacheBroker.Bind(instanceID, "binding-id", bindDetails)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns the existing credentials for identical requests", func() {
+			bindingResponse, err := elastiCacheBroker.Bind(instanceID, "binding-id", bindDetails)
+			Expect(err).To(Equal(api.ErrBindingAlreadyBound))
+			Expect(bindingResponse.Credentials).To(Equal(&brokerapi.CredentialsHash{
+				Host: "cache-endpoint",
+				Port: 6379,
+				Name: "cf-aso4rtfujlvj",
+			}))
+		})
+
+		It("rejects requests for a different application", func() {
+			bindDetails.AppGUID = "other-app-guid"
+
+			_, err := elastiCacheBroker.Bind(instanceID, "binding-id", bindDetails)
+			Expect(err).To(Equal(brokerapi.ErrBindingAlreadyExists))
+
+			binding, err := brokerStore.GetBinding(instanceID, "binding-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(binding.AppGUID).To(Equal("app-guid"))
+		})
+	})
+
+	Describe("Deprovision", func() {
+		It("accepts deprovisions of cache clusters already being deleted", func() {
+			addCacheCluster("deleting", map[string]string{"Instance ID": instanceID, "Broker ID": "cf"})
+
+			asynch, err := elastiCacheBroker.Deprovision(instanceID, brokerapi.DeprovisionDetails{ServiceID: "Service-1", PlanID: "Plan-1"}, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(asynch).To(BeTrue())
+			Expect(cacheCluster.DeleteCalled).To(BeFalse())
+
+			instance, err := brokerStore.GetInstance(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance.Operation.Type).To(Equal(store.OperationDeprovision))
+		})
+	})
+})
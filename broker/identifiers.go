@@ -0,0 +1,129 @@
+package broker
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pivotal-golang/lager"
+
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+// ElastiCache cache cluster identifiers must have at most 20 characters, start with a
+// letter, contain only lowercase letters, digits and hyphens, and must not end with a
+// hyphen or contain two consecutive hyphens.
+const maxCacheClusterIDLength = 20
+
+// cacheClusterIDHashLength is the number of base32 characters (5 bits each) of the instance
+// ID hash kept in the cache cluster identifier.
+const cacheClusterIDHashLength = 12
+
+// maxCachePrefixLength leaves room in the cache cluster identifier for a hyphen and the hash.
+const maxCachePrefixLength = maxCacheClusterIDLength - cacheClusterIDHashLength - 1
+
+var (
+	cachePrefixPattern     = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-]*$`)
+	cacheClusterIDPattern  = regexp.MustCompile(`^[a-z][a-z0-9]*(-[a-z0-9]+)*$`)
+	consecutiveHyphens     = regexp.MustCompile(`-+`)
+	cacheClusterIDEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567")
+)
+
+// cacheClusterIdentifier derives the cache cluster identifier of a service instance from the
+// cache prefix and a hash of the instance ID, so different instances never share a cache
+// cluster and the identifier is always valid regardless of the prefix length.
+func (b *ElastiCacheBroker) cacheClusterIdentifier(instanceID string) string {
+	hash := sha256.Sum256([]byte(instanceID))
+	suffix := cacheClusterIDEncoding.EncodeToString(hash[:])[:cacheClusterIDHashLength]
+
+	return fmt.Sprintf("%s-%s", cacheClusterIDPrefix(b.cachePrefix), suffix)
+}
+
+// legacyCacheClusterIdentifier returns the identifier used by previous broker versions,
+// the truncated concatenation of the cache prefix and the instance ID, or an empty string
+// if such an identifier is not a valid cache cluster identifier.
+func (b *ElastiCacheBroker) legacyCacheClusterIdentifier(instanceID string) string {
+	id := fmt.Sprintf("%s-%s", b.cachePrefix, strings.Replace(instanceID, "-", "", -1))
+	if len(id) < maxCacheClusterIDLength {
+		return ""
+	}
+
+	id = strings.ToLower(id[:maxCacheClusterIDLength])
+	if !cacheClusterIDPattern.MatchString(id) {
+		return ""
+	}
+
+	return id
+}
+
+// ownershipTags are the tags identifying the service instance and the broker owning a cache cluster.
+func (b *ElastiCacheBroker) ownershipTags(instanceID string) map[string]string {
+	return map[string]string{
+		"Instance ID": instanceID,
+		"Broker ID":   b.brokerID,
+	}
+}
+
+// describeCacheCluster returns the identifier and details of the cache cluster of a service
+// instance. The cache cluster recorded in the store is authoritative; otherwise the cache
+// cluster is looked up by its derived identifier, then by the legacy identifier of cache
+// clusters created by previous broker versions.
+func (b *ElastiCacheBroker) describeCacheCluster(instanceID string) (string, awselasticache.CacheClusterDetails, error) {
+	if instance, err := b.store.GetInstance(instanceID); err == nil && instance.CacheClusterID != "" {
+		cacheClusterDetails, err := b.cacheCluster.Describe(instance.CacheClusterID)
+		return instance.CacheClusterID, cacheClusterDetails, err
+	}
+
+	cacheClusterID := b.cacheClusterIdentifier(instanceID)
+	cacheClusterDetails, err := b.cacheCluster.Describe(cacheClusterID)
+	if err != awselasticache.ErrCacheClusterDoesNotExist {
+		return cacheClusterID, cacheClusterDetails, err
+	}
+
+	if legacyCacheClusterID := b.legacyCacheClusterIdentifier(instanceID); legacyCacheClusterID != "" {
+		cacheClusterDetails, err = b.cacheCluster.Describe(legacyCacheClusterID)
+		if err != awselasticache.ErrCacheClusterDoesNotExist {
+			return legacyCacheClusterID, cacheClusterDetails, err
+		}
+	}
+
+	return cacheClusterID, cacheClusterDetails, err
+}
+
+// recoverCacheCluster is describeCacheCluster for the update and deprovision requests of
+// service instances the store knows nothing about: cache clusters whose identifier no longer
+// matches the derived one (e.g. after a cache prefix change) are then found by their ownership
+// tags. Scanning the cache clusters is expensive, so it is never done for polled requests, nor
+// for service instances whose cache cluster is recorded in the store.
+func (b *ElastiCacheBroker) recoverCacheCluster(instanceID string) (string, awselasticache.CacheClusterDetails, error) {
+	cacheClusterID, cacheClusterDetails, err := b.describeCacheCluster(instanceID)
+	if err != awselasticache.ErrCacheClusterDoesNotExist {
+		return cacheClusterID, cacheClusterDetails, err
+	}
+
+	if _, err = b.store.GetInstance(instanceID); err != store.ErrInstanceNotFound {
+		return cacheClusterID, cacheClusterDetails, awselasticache.ErrCacheClusterDoesNotExist
+	}
+
+	cacheClusterDetails, err = b.cacheCluster.DescribeByTags(b.ownershipTags(instanceID))
+	if err != nil {
+		return cacheClusterID, cacheClusterDetails, err
+	}
+
+	b.logger.Info("cache-cluster-found-by-tags", lager.Data{instanceIDLogKey: instanceID, "cache-cluster-id": cacheClusterDetails.CacheClusterId})
+
+	return cacheClusterDetails.CacheClusterId, cacheClusterDetails, nil
+}
+
+// cacheClusterIDPrefix turns the cache prefix into a valid cache cluster identifier prefix.
+func cacheClusterIDPrefix(cachePrefix string) string {
+	prefix := consecutiveHyphens.ReplaceAllString(strings.ToLower(cachePrefix), "-")
+	if len(prefix) > maxCachePrefixLength {
+		prefix = prefix[:maxCachePrefixLength]
+	}
+
+	return strings.TrimRight(prefix, "-")
+}
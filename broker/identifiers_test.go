@@ -0,0 +1,195 @@
+package broker_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/frodenas/brokerapi"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	cwfakes "github.com/cloudfoundry-community/elasticache-broker/awscloudwatch/fakes"
+	ec2fakes "github.com/cloudfoundry-community/elasticache-broker/awsec2/fakes"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache/fakes"
+	. "github.com/cloudfoundry-community/elasticache-broker/broker"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+var _ = Describe("Cache Cluster Identifiers", func() {
+	const (
+		instanceID          = "ce71b484-d542-40f7-9dd4-5526e38c81ba"
+		validCacheClusterID = `^[a-z][a-z0-9]*(-[a-z0-9]+)*$`
+	)
+
+	var (
+		cachePrefix  string
+		cacheCluster *fakes.FakeCacheCluster
+
+		elastiCacheBroker *ElastiCacheBroker
+	)
+
+	BeforeEach(func() {
+		cachePrefix = "cf"
+		cacheCluster = &fakes.FakeCacheCluster{}
+	})
+
+	JustBeforeEach(func() {
+		config := Config{
+			Region:      "elasticache-region",
+			CachePrefix: cachePrefix,
+			Catalog: Catalog{
+				Services: []Service{
+					Service{
+						ID:             "Service-1",
+						Bindable:       true,
+						PlanUpdateable: true,
+						Plans: []ServicePlan{
+							ServicePlan{
+								ID: "Plan-1",
+								ElastiCacheProperties: ElastiCacheProperties{
+									CacheInstanceClass: "cache.t2.micro",
+									Engine:             "redis",
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		elastiCacheBroker = New(config, cacheCluster, &fakes.FakeCacheSubnetGroup{}, &ec2fakes.FakeSecurityGroup{}, &cwfakes.FakeCacheClusterMetrics{}, nil, store.NewMemoryStore(), lagertest.NewTestLogger("broker_test"))
+	})
+
+	provision := func(instanceID string) string {
+		cacheCluster.DescribeError = awselasticache.ErrCacheClusterDoesNotExist
+		_, _, err := elastiCacheBroker.Provision(instanceID, brokerapi.ProvisionDetails{ServiceID: "Service-1", PlanID: "Plan-1"}, true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(elastiCacheBroker.ResumeWorkflows()).To(Succeed())
+		Expect(cacheCluster.CreateCalled).To(BeTrue())
+		return cacheCluster.CreateID
+	}
+
+	It("derives a valid identifier from the instance ID", func() {
+		cacheClusterID := provision(instanceID)
+		Expect(cacheClusterID).To(Equal("cf-aso4rtfujlvj"))
+		Expect(cacheClusterID).To(MatchRegexp(validCacheClusterID))
+	})
+
+	It("always derives the same identifier for an instance", func() {
+		Expect(provision(instanceID)).To(Equal(provision(instanceID)))
+	})
+
+	It("derives different identifiers for instances sharing a prefix", func() {
+		Expect(provision("ce71b484-d542-40f7-9dd4-000000000001")).ToNot(Equal(provision("ce71b484-d542-40f7-9dd4-000000000002")))
+	})
+
+	It("tags the cache cluster with the instance ID and the broker ID", func() {
+		provision(instanceID)
+		Expect(cacheCluster.CreateCacheClusterDetails.Tags).To(HaveKeyWithValue("Instance ID", instanceID))
+		Expect(cacheCluster.CreateCacheClusterDetails.Tags).To(HaveKeyWithValue("Broker ID", "cf"))
+	})
+
+	Context("when the cache prefix is long", func() {
+		BeforeEach(func() {
+			cachePrefix = "Cloud-Foundry--Broker"
+		})
+
+		It("keeps the identifier within the ElastiCache limits", func() {
+			cacheClusterID := provision(instanceID)
+			Expect(len(cacheClusterID)).To(BeNumerically("<=", 20))
+			Expect(cacheClusterID).To(HavePrefix("cloud-f-"))
+			Expect(cacheClusterID).To(MatchRegexp(validCacheClusterID))
+		})
+	})
+
+	Context("when the cache cluster was created with a legacy identifier", func() {
+		BeforeEach(func() {
+			cacheCluster.DescribeCacheClusters = map[string]awselasticache.CacheClusterDetails{
+				"cf-ce71b484d54240f79": awselasticache.CacheClusterDetails{
+					Status: "available",
+					Engine: "redis",
+					Tags:   map[string]string{"Created by": "AWS ElastiCache Service Broker"},
+				},
+			}
+		})
+
+		It("finds the cache cluster by its legacy identifier", func() {
+			bindingResponse, err := elastiCacheBroker.Bind(instanceID, "binding-id", brokerapi.BindDetails{ServiceID: "Service-1", PlanID: "Plan-1"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cacheCluster.DescribeIDs).To(Equal([]string{"cf-aso4rtfujlvj", "cf-ce71b484d54240f79"}))
+			Expect(bindingResponse.Credentials.(*brokerapi.CredentialsHash).Name).To(Equal("cf-ce71b484d54240f79"))
+		})
+
+		It("deletes the cache cluster by its legacy identifier", func() {
+			_, err := elastiCacheBroker.Deprovision(instanceID, brokerapi.DeprovisionDetails{ServiceID: "Service-1", PlanID: "Plan-1"}, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cacheCluster.DeleteID).To(Equal("cf-ce71b484d54240f79"))
+		})
+	})
+
+	Context("when the cache cluster identifier no longer matches the derived one", func() {
+		BeforeEach(func() {
+			cacheCluster.DescribeCacheClusters = map[string]awselasticache.CacheClusterDetails{
+				"old-aso4rtfujlvj": awselasticache.CacheClusterDetails{
+					CacheClusterId: "old-aso4rtfujlvj",
+					Status:         "available",
+					Tags:           map[string]string{"Instance ID": instanceID, "Broker ID": "cf"},
+				},
+			}
+		})
+
+		It("updates the cache cluster found by its ownership tags", func() {
+			_, err := elastiCacheBroker.Update(instanceID, brokerapi.UpdateDetails{ServiceID: "Service-1", PlanID: "Plan-1"}, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cacheCluster.DescribeByTagsTags).To(Equal(map[string]string{"Instance ID": instanceID, "Broker ID": "cf"}))
+			Expect(cacheCluster.ModifyID).To(Equal("old-aso4rtfujlvj"))
+		})
+
+		It("does not look for the cache cluster by its tags when polling the last operation", func() {
+			_, err := elastiCacheBroker.LastOperation(instanceID)
+			Expect(err).To(Equal(brokerapi.ErrInstanceDoesNotExist))
+			Expect(cacheCluster.DescribeByTagsCalled).To(BeFalse())
+		})
+
+		It("reports the last operation of the cache cluster recorded once found by its tags", func() {
+			_, err := elastiCacheBroker.Update(instanceID, brokerapi.UpdateDetails{ServiceID: "Service-1", PlanID: "Plan-1"}, true)
+			Expect(err).ToNot(HaveOccurred())
+			cacheCluster.DescribeByTagsCalled = false
+
+			lastOperationResponse, err := elastiCacheBroker.LastOperation(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lastOperationResponse.Description).To(ContainSubstring("'old-aso4rtfujlvj'"))
+			Expect(cacheCluster.DescribeByTagsCalled).To(BeFalse())
+		})
+
+		It("does not look for the cache cluster by its tags once the recorded one is gone", func() {
+			_, err := elastiCacheBroker.Update(instanceID, brokerapi.UpdateDetails{ServiceID: "Service-1", PlanID: "Plan-1"}, true)
+			Expect(err).ToNot(HaveOccurred())
+			cacheCluster.DescribeByTagsCalled = false
+			delete(cacheCluster.DescribeCacheClusters, "old-aso4rtfujlvj")
+
+			_, err = elastiCacheBroker.Update(instanceID, brokerapi.UpdateDetails{ServiceID: "Service-1", PlanID: "Plan-1"}, true)
+			Expect(err).To(Equal(brokerapi.ErrInstanceDoesNotExist))
+			Expect(cacheCluster.DescribeByTagsCalled).To(BeFalse())
+		})
+
+		It("does not find cache clusters owned by another broker", func() {
+			cacheCluster.DescribeCacheClusters["old-aso4rtfujlvj"].Tags["Broker ID"] = "other"
+
+			_, err := elastiCacheBroker.Deprovision(instanceID, brokerapi.DeprovisionDetails{ServiceID: "Service-1", PlanID: "Plan-1"}, true)
+			Expect(err).To(Equal(brokerapi.ErrInstanceDoesNotExist))
+			Expect(cacheCluster.DeleteCalled).To(BeFalse())
+		})
+	})
+
+	Context("when the cache cluster does not exist", func() {
+		BeforeEach(func() {
+			cacheCluster.DescribeCacheClusters = map[string]awselasticache.CacheClusterDetails{}
+		})
+
+		It("returns the proper error", func() {
+			_, err := elastiCacheBroker.Deprovision(instanceID, brokerapi.DeprovisionDetails{ServiceID: "Service-1", PlanID: "Plan-1"}, true)
+			Expect(err).To(Equal(brokerapi.ErrInstanceDoesNotExist))
+			Expect(cacheCluster.DeleteCalled).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,77 @@
+package broker
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/frodenas/brokerapi"
+	"github.com/pivotal-golang/lager"
+
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+// staleOperationPeriod is how long a recorded operation keeps the service instance locked once
+// its cache cluster is no longer busy, in case LastOperation is never polled to clear it. It
+// covers the lag between an AWS call and the cache cluster status reflecting it.
+const staleOperationPeriod = time.Minute
+
+// lockInstance claims the service instance for an operation by recording the operation in the
+// store, which is shared by all the broker replicas when a shared store is configured. It fails
+// with a ConcurrencyError when the cache cluster is busy, when another operation is recorded,
+// or when another request claims the service instance at the same time. update, if not nil,
+// is applied to the stored service instance along with the operation.
+func (b *ElastiCacheBroker) lockInstance(instanceID string, cacheClusterStatus string, operationType string, update func(instance *store.Instance)) error {
+	if cacheClusterStatus != "" && elastiCacheStatus2State[cacheClusterStatus] == brokerapi.LastOperationInProgress {
+		err := fmt.Errorf("Cannot %s service instance '%s': Cache Cluster status is '%s'", operationType, instanceID, cacheClusterStatus)
+		return api.NewConcurrencyErrorResponse(err, "concurrent-operation")
+	}
+
+	now := time.Now()
+
+	instance, err := b.store.GetInstance(instanceID)
+	if err != nil {
+		if err != store.ErrInstanceNotFound {
+			return err
+		}
+		instance = store.Instance{ID: instanceID, CreatedAt: now}
+	}
+
+	if instance.Operation != nil && !isStaleOperation(*instance.Operation, cacheClusterStatus) {
+		err = fmt.Errorf("Cannot %s service instance '%s': %s operation in progress", operationType, instanceID, instance.Operation.Type)
+		return api.NewConcurrencyErrorResponse(err, "concurrent-operation")
+	}
+
+	if update != nil {
+		update(&instance)
+	}
+	startOperation(&instance, operationType)
+	instance.UpdatedAt = now
+
+	if err = b.store.PutInstance(instance); err != nil {
+		if err == store.ErrConcurrentUpdate {
+			err = fmt.Errorf("Cannot %s service instance '%s': another operation is starting", operationType, instanceID)
+			return api.NewConcurrencyErrorResponse(err, "concurrent-operation")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// unlockInstance releases the service instance after an operation failed to start.
+func (b *ElastiCacheBroker) unlockInstance(instanceID string) {
+	if err := b.finishOperation(instanceID); err != nil {
+		b.logger.Error("unlock-instance", err, lager.Data{instanceIDLogKey: instanceID})
+	}
+}
+
+// isStaleOperation reports whether a recorded operation is over although LastOperation has
+// not cleared it, because its cache cluster is no longer busy.
+func isStaleOperation(operation store.Operation, cacheClusterStatus string) bool {
+	if cacheClusterStatus == "" || elastiCacheStatus2State[cacheClusterStatus] == brokerapi.LastOperationInProgress {
+		return false
+	}
+
+	return time.Since(operation.StartedAt) > staleOperationPeriod
+}
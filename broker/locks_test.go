@@ -0,0 +1,196 @@
+package broker_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/frodenas/brokerapi"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+	cwfakes "github.com/cloudfoundry-community/elasticache-broker/awscloudwatch/fakes"
+	ec2fakes "github.com/cloudfoundry-community/elasticache-broker/awsec2/fakes"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache/fakes"
+	. "github.com/cloudfoundry-community/elasticache-broker/broker"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+// racingStore simulates another broker replica claiming the service instance between the
+// broker reading and writing it.
+type racingStore struct {
+	*store.MemoryStore
+}
+
+func (s *racingStore) PutInstance(instance store.Instance) error {
+	return store.ErrConcurrentUpdate
+}
+
+var _ = Describe("Instance Locks", func() {
+	const instanceID = "ce71b484-d542-40f7-9dd4-5526e38c81ba"
+
+	var (
+		cacheCluster *fakes.FakeCacheCluster
+		brokerStore  store.Store
+
+		updateDetails      brokerapi.UpdateDetails
+		deprovisionDetails brokerapi.DeprovisionDetails
+
+		elastiCacheBroker *ElastiCacheBroker
+	)
+
+	setCacheClusterStatus := func(status string) {
+		cacheCluster.DescribeCacheClusters["cf-aso4rtfujlvj"] = awselasticache.CacheClusterDetails{
+			CacheClusterId: "cf-aso4rtfujlvj",
+			Status:         status,
+			Engine:         "redis",
+			Tags:           map[string]string{"Instance ID": instanceID, "Broker ID": "cf"},
+		}
+	}
+
+	recordOperation := func(operationType string, startedAt time.Time) {
+		Expect(brokerStore.PutInstance(store.Instance{
+			ID:             instanceID,
+			ServiceID:      "Service-1",
+			PlanID:         "Plan-1",
+			CacheClusterID: "cf-aso4rtfujlvj",
+			Operation:      &store.Operation{Type: operationType, StartedAt: startedAt},
+		})).To(Succeed())
+	}
+
+	expectConcurrencyError := func(err error) {
+		Expect(err).To(HaveOccurred())
+		failureResponse, ok := err.(*api.FailureResponse)
+		Expect(ok).To(BeTrue())
+		Expect(failureResponse.StatusCode()).To(Equal(422))
+		Expect(failureResponse.ErrorResponse().Error).To(Equal("ConcurrencyError"))
+	}
+
+	BeforeEach(func() {
+		cacheCluster = &fakes.FakeCacheCluster{
+			DescribeCacheClusters: map[string]awselasticache.CacheClusterDetails{},
+		}
+		setCacheClusterStatus("available")
+		brokerStore = store.NewMemoryStore()
+		updateDetails = brokerapi.UpdateDetails{ServiceID: "Service-1", PlanID: "Plan-1"}
+		deprovisionDetails = brokerapi.DeprovisionDetails{ServiceID: "Service-1", PlanID: "Plan-1"}
+	})
+
+	JustBeforeEach(func() {
+		config := Config{
+			Region:      "elasticache-region",
+			CachePrefix: "cf",
+			Catalog: Catalog{
+				Services: []Service{
+					Service{
+						ID:             "Service-1",
+						PlanUpdateable: true,
+						Plans: []ServicePlan{
+							ServicePlan{
+								ID: "Plan-1",
+								ElastiCacheProperties: ElastiCacheProperties{
+									CacheInstanceClass: "cache.t2.micro",
+									Engine:             "redis",
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		elastiCacheBroker = New(config, cacheCluster, &fakes.FakeCacheSubnetGroup{}, &ec2fakes.FakeSecurityGroup{}, &cwfakes.FakeCacheClusterMetrics{}, nil, brokerStore, lagertest.NewTestLogger("broker_test"))
+	})
+
+	Context("when the service instance is being created", func() {
+		BeforeEach(func() {
+			setCacheClusterStatus("creating")
+			recordOperation(store.OperationProvision, time.Now())
+		})
+
+		It("rejects updates", func() {
+			_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+			expectConcurrencyError(err)
+			Expect(cacheCluster.ModifyCalled).To(BeFalse())
+		})
+	})
+
+	Context("when the cache cluster is busy but no operation is recorded", func() {
+		BeforeEach(func() {
+			setCacheClusterStatus("modifying")
+		})
+
+		It("rejects updates", func() {
+			_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+			expectConcurrencyError(err)
+			Expect(cacheCluster.ModifyCalled).To(BeFalse())
+		})
+
+		It("rejects deprovisions", func() {
+			_, err := elastiCacheBroker.Deprovision(instanceID, deprovisionDetails, true)
+			expectConcurrencyError(err)
+			Expect(cacheCluster.DeleteCalled).To(BeFalse())
+		})
+	})
+
+	Context("when an operation was just started", func() {
+		BeforeEach(func() {
+			recordOperation(store.OperationUpdate, time.Now())
+		})
+
+		It("rejects other operations even if the cache cluster status is not updated yet", func() {
+			_, err := elastiCacheBroker.Deprovision(instanceID, deprovisionDetails, true)
+			expectConcurrencyError(err)
+			Expect(cacheCluster.DeleteCalled).To(BeFalse())
+		})
+	})
+
+	Context("when a finished operation was never cleared", func() {
+		BeforeEach(func() {
+			recordOperation(store.OperationUpdate, time.Now().Add(-time.Hour))
+		})
+
+		It("accepts new operations", func() {
+			_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cacheCluster.ModifyCalled).To(BeTrue())
+		})
+	})
+
+	It("records the operation before modifying the cache cluster", func() {
+		_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+		Expect(err).ToNot(HaveOccurred())
+
+		instance, err := brokerStore.GetInstance(instanceID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(instance.Operation.Type).To(Equal(store.OperationUpdate))
+
+		_, err = elastiCacheBroker.Update(instanceID, updateDetails, true)
+		expectConcurrencyError(err)
+	})
+
+	It("releases the service instance when the operation fails to start", func() {
+		cacheCluster.ModifyError = errors.New("operation failed")
+
+		_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+		Expect(err).To(HaveOccurred())
+
+		instance, err := brokerStore.GetInstance(instanceID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(instance.Operation).To(BeNil())
+	})
+
+	Context("when another broker replica claims the service instance first", func() {
+		BeforeEach(func() {
+			brokerStore = &racingStore{MemoryStore: store.NewMemoryStore()}
+		})
+
+		It("rejects the operation", func() {
+			_, err := elastiCacheBroker.Update(instanceID, updateDetails, true)
+			expectConcurrencyError(err)
+			Expect(cacheCluster.ModifyCalled).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,33 @@
+package broker
+
+import (
+	"fmt"
+
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+)
+
+const createdByTagValue = "AWS ElastiCache Service Broker"
+
+// checkOwnership refuses to modify or delete a cache cluster that does not carry this broker
+// ownership tags for the service instance, so a hand built cache cluster that happens to
+// match a derived identifier is never touched. Cache clusters created by previous broker
+// versions, which were not tagged with their instance ID, are recognized by their legacy
+// identifier and creation tag. Operators can exempt cache clusters from the check.
+func (b *ElastiCacheBroker) checkOwnership(instanceID string, cacheClusterID string, tags map[string]string) error {
+	if containsString(b.allowedUnownedCacheClusterIDs, cacheClusterID) {
+		return nil
+	}
+
+	if _, ok := tags["Instance ID"]; !ok && tags["Created by"] == createdByTagValue && cacheClusterID == b.legacyCacheClusterIdentifier(instanceID) {
+		return nil
+	}
+
+	for key, value := range b.ownershipTags(instanceID) {
+		if tags[key] != value {
+			err := fmt.Errorf("Cache Cluster '%s' is not owned by this broker for service instance '%s': tag '%s' must be '%s'", cacheClusterID, instanceID, key, value)
+			return api.NewUnprocessableEntityResponse(err, "cache-cluster-not-owned")
+		}
+	}
+
+	return nil
+}
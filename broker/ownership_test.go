@@ -0,0 +1,122 @@
+package broker_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/frodenas/brokerapi"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+	cwfakes "github.com/cloudfoundry-community/elasticache-broker/awscloudwatch/fakes"
+	ec2fakes "github.com/cloudfoundry-community/elasticache-broker/awsec2/fakes"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache/fakes"
+	. "github.com/cloudfoundry-community/elasticache-broker/broker"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+var _ = Describe("Cache Cluster Ownership", func() {
+	const instanceID = "ce71b484-d542-40f7-9dd4-5526e38c81ba"
+
+	var (
+		config       Config
+		cacheCluster *fakes.FakeCacheCluster
+
+		elastiCacheBroker *ElastiCacheBroker
+	)
+
+	BeforeEach(func() {
+		config = Config{
+			Region:      "elasticache-region",
+			CachePrefix: "cf",
+			Catalog: Catalog{
+				Services: []Service{
+					Service{
+						ID:             "Service-1",
+						PlanUpdateable: true,
+						Plans: []ServicePlan{
+							ServicePlan{
+								ID: "Plan-1",
+								ElastiCacheProperties: ElastiCacheProperties{
+									CacheInstanceClass: "cache.t2.micro",
+									Engine:             "redis",
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		cacheCluster = &fakes.FakeCacheCluster{
+			ListTagsTags: map[string]string{"Name": "hand-built"},
+		}
+	})
+
+	JustBeforeEach(func() {
+		elastiCacheBroker = New(config, cacheCluster, &fakes.FakeCacheSubnetGroup{}, &ec2fakes.FakeSecurityGroup{}, &cwfakes.FakeCacheClusterMetrics{}, nil, store.NewMemoryStore(), lagertest.NewTestLogger("broker_test"))
+	})
+
+	deprovision := func() error {
+		_, err := elastiCacheBroker.Deprovision(instanceID, brokerapi.DeprovisionDetails{ServiceID: "Service-1", PlanID: "Plan-1"}, true)
+		return err
+	}
+
+	update := func() error {
+		_, err := elastiCacheBroker.Update(instanceID, brokerapi.UpdateDetails{ServiceID: "Service-1", PlanID: "Plan-1"}, true)
+		return err
+	}
+
+	Context("when the cache cluster does not carry the ownership tags", func() {
+		It("refuses to delete it", func() {
+			err := deprovision()
+			Expect(err).To(MatchError(ContainSubstring("Cache Cluster 'cf-aso4rtfujlvj' is not owned by this broker")))
+			Expect(err.(*api.FailureResponse).StatusCode()).To(Equal(422))
+			Expect(cacheCluster.DeleteCalled).To(BeFalse())
+		})
+
+		It("refuses to modify it", func() {
+			err := update()
+			Expect(err).To(MatchError(ContainSubstring("is not owned by this broker")))
+			Expect(cacheCluster.ModifyCalled).To(BeFalse())
+		})
+
+		Context("when the operator allows the cache cluster", func() {
+			BeforeEach(func() {
+				config.AllowedUnownedCacheClusterIDs = []string{"cf-aso4rtfujlvj"}
+			})
+
+			It("deletes it", func() {
+				Expect(deprovision()).To(Succeed())
+				Expect(cacheCluster.DeleteID).To(Equal("cf-aso4rtfujlvj"))
+			})
+		})
+	})
+
+	Context("when the cache cluster is owned by another broker", func() {
+		BeforeEach(func() {
+			cacheCluster.ListTagsTags = map[string]string{"Instance ID": instanceID, "Broker ID": "other"}
+		})
+
+		It("refuses to delete it", func() {
+			Expect(deprovision()).To(MatchError(ContainSubstring("tag 'Broker ID' must be 'cf'")))
+			Expect(cacheCluster.DeleteCalled).To(BeFalse())
+		})
+	})
+
+	Context("when the cache cluster carries the ownership tags", func() {
+		BeforeEach(func() {
+			config.BrokerID = "broker-1"
+			cacheCluster.ListTagsTags = map[string]string{"Instance ID": instanceID, "Broker ID": "broker-1"}
+		})
+
+		It("deletes it", func() {
+			Expect(deprovision()).To(Succeed())
+			Expect(cacheCluster.DeleteCalled).To(BeTrue())
+		})
+
+		It("modifies it", func() {
+			Expect(update()).To(Succeed())
+			Expect(cacheCluster.ModifyCalled).To(BeTrue())
+		})
+	})
+})
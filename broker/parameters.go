@@ -1,9 +1,215 @@
 package broker
 
-type ProvisionParameters struct {
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+)
 
+type ProvisionParameters struct {
+	CacheNodeType              string            `mapstructure:"cache_node_type"`
+	EngineVersion              string            `mapstructure:"engine_version"`
+	NumCacheNodes              int64             `mapstructure:"num_cache_nodes"`
+	Port                       int64             `mapstructure:"port"`
+	PreferredAvailabilityZone  string            `mapstructure:"preferred_availability_zone"`
+	PreferredMaintenanceWindow string            `mapstructure:"preferred_maintenance_window"`
+	SnapshotName               string            `mapstructure:"snapshot_name"`
+	SnapshotRetentionLimit     *int64            `mapstructure:"snapshot_retention_limit"`
+	SnapshotWindow             string            `mapstructure:"snapshot_window"`
+	AutoMinorVersionUpgrade    *bool             `mapstructure:"auto_minor_version_upgrade"`
+	Tags                       map[string]string `mapstructure:"tags"`
+	AdoptClusterID             string            `mapstructure:"adopt_cluster_id"`
+	KeepCluster                *bool             `mapstructure:"keep_cluster"`
 }
 
 type UpdateParameters struct {
-	ApplyImmediately           bool   `mapstructure:"apply_immediately"`
+	ApplyImmediately           bool              `mapstructure:"apply_immediately"`
+	EngineVersion              string            `mapstructure:"engine_version"`
+	PreferredMaintenanceWindow string            `mapstructure:"preferred_maintenance_window"`
+	SnapshotRetentionLimit     *int64            `mapstructure:"snapshot_retention_limit"`
+	SnapshotWindow             string            `mapstructure:"snapshot_window"`
+	AutoMinorVersionUpgrade    *bool             `mapstructure:"auto_minor_version_upgrade"`
+	Tags                       map[string]string `mapstructure:"tags"`
+}
+
+// Parameter types, named after their JSON schema counterparts.
+const (
+	parameterTypeString  = "string"
+	parameterTypeInteger = "integer"
+	parameterTypeBoolean = "boolean"
+	parameterTypeObject  = "object"
+)
+
+type parameterDefinition struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+var provisionParameterDefinitions = []parameterDefinition{
+	{"cache_node_type", parameterTypeString, "The compute and memory capacity of the nodes"},
+	{"engine_version", parameterTypeString, "The version number of the cache engine"},
+	{"num_cache_nodes", parameterTypeInteger, "The initial number of cache nodes"},
+	{"port", parameterTypeInteger, "The port number on which each of the cache nodes accepts connections"},
+	{"preferred_availability_zone", parameterTypeString, "The EC2 Availability Zone in which the cache cluster is created"},
+	{"preferred_maintenance_window", parameterTypeString, "The weekly time range during which system maintenance can occur"},
+	{"snapshot_name", parameterTypeString, "The name of a Redis snapshot from which to restore data"},
+	{"snapshot_retention_limit", parameterTypeInteger, "The number of days for which automatic Redis snapshots are retained"},
+	{"snapshot_window", parameterTypeString, "The daily time range during which automatic Redis snapshots are taken"},
+	{"auto_minor_version_upgrade", parameterTypeBoolean, "Whether minor engine upgrades are applied automatically"},
+	{"tags", parameterTypeObject, "A map of tag keys and values to add to the cache cluster"},
+	{"adopt_cluster_id", parameterTypeString, "The identifier of an existing cache cluster to adopt instead of creating a new one"},
+	{"keep_cluster", parameterTypeBoolean, "Whether the adopted cache cluster is kept when the service instance is deleted (defaults to true)"},
+}
+
+var updateParameterDefinitions = []parameterDefinition{
+	{"apply_immediately", parameterTypeBoolean, "Whether modifications are applied as soon as possible instead of during the maintenance window"},
+	{"engine_version", parameterTypeString, "The upgraded version of the cache engine"},
+	{"preferred_maintenance_window", parameterTypeString, "The weekly time range during which system maintenance can occur"},
+	{"snapshot_retention_limit", parameterTypeInteger, "The number of days for which automatic Redis snapshots are retained"},
+	{"snapshot_window", parameterTypeString, "The daily time range during which automatic Redis snapshots are taken"},
+	{"auto_minor_version_upgrade", parameterTypeBoolean, "Whether minor engine upgrades are applied automatically"},
+	{"tags", parameterTypeObject, "A map of tag keys and values that replaces the user tags on the cache cluster"},
+}
+
+func findParameterDefinition(definitions []parameterDefinition, name string) (parameterDefinition, bool) {
+	for _, definition := range definitions {
+		if definition.Name == name {
+			return definition, true
+		}
+	}
+
+	return parameterDefinition{}, false
+}
+
+// decodeParameters checks the user parameters against the supported parameter definitions
+// and the plan allowed parameters, and decodes them into result. Any violation is reported
+// to the platform as a 400 Bad Request.
+func decodeParameters(parameters map[string]interface{}, definitions []parameterDefinition, servicePlan ServicePlan, result interface{}) error {
+	if err := checkParameters(parameters, definitions, servicePlan); err != nil {
+		return api.NewBadRequestResponse(err, "invalid-parameters")
+	}
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		ErrorUnused: true,
+		Result:      result,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = decoder.Decode(parameters); err != nil {
+		return api.NewBadRequestResponse(fmt.Errorf("Invalid parameters: %s", err), "invalid-parameters")
+	}
+
+	return nil
+}
+
+func checkParameters(parameters map[string]interface{}, definitions []parameterDefinition, servicePlan ServicePlan) error {
+	var names []string
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		definition, ok := findParameterDefinition(definitions, name)
+		if !ok {
+			return fmt.Errorf("Unknown parameter '%s'", name)
+		}
+
+		if definition.Type == parameterTypeInteger {
+			if number, ok := toFloat64(parameters[name]); ok && number != float64(int64(number)) {
+				return fmt.Errorf("Parameter '%s' must be an integer", name)
+			}
+		}
+
+		if servicePlan.AllowedParameters == nil {
+			continue
+		}
+
+		constraints, ok := servicePlan.AllowedParameters[name]
+		if !ok {
+			return fmt.Errorf("Parameter '%s' is not allowed by Service Plan '%s'", name, servicePlan.Name)
+		}
+
+		if err := constraints.Check(name, parameters[name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ParameterConstraints restricts the values users may set for a parameter.
+type ParameterConstraints struct {
+	Minimum *int64   `json:"minimum,omitempty"`
+	Maximum *int64   `json:"maximum,omitempty"`
+	Values  []string `json:"values,omitempty"`
+}
+
+func (pc ParameterConstraints) Validate(name string) error {
+	definition, ok := findParameterDefinition(provisionParameterDefinitions, name)
+	if !ok {
+		if definition, ok = findParameterDefinition(updateParameterDefinitions, name); !ok {
+			return fmt.Errorf("Unknown parameter '%s'", name)
+		}
+	}
+
+	if (pc.Minimum != nil || pc.Maximum != nil) && definition.Type != parameterTypeInteger {
+		return fmt.Errorf("Parameter '%s' is not an integer and cannot have a Minimum or Maximum", name)
+	}
+
+	if pc.Minimum != nil && pc.Maximum != nil && *pc.Minimum > *pc.Maximum {
+		return fmt.Errorf("Parameter '%s' Minimum must not be greater than Maximum", name)
+	}
+
+	if len(pc.Values) > 0 && definition.Type != parameterTypeString {
+		return fmt.Errorf("Parameter '%s' is not a string and cannot have Values", name)
+	}
+
+	return nil
+}
+
+func (pc ParameterConstraints) Check(name string, value interface{}) error {
+	if pc.Minimum != nil || pc.Maximum != nil {
+		number, ok := toFloat64(value)
+		if !ok {
+			return fmt.Errorf("Parameter '%s' must be an integer", name)
+		}
+
+		if pc.Minimum != nil && number < float64(*pc.Minimum) {
+			return fmt.Errorf("Parameter '%s' must be greater than or equal to %d", name, *pc.Minimum)
+		}
+
+		if pc.Maximum != nil && number > float64(*pc.Maximum) {
+			return fmt.Errorf("Parameter '%s' must be less than or equal to %d", name, *pc.Maximum)
+		}
+	}
+
+	if len(pc.Values) > 0 {
+		str, ok := value.(string)
+		if !ok || !containsString(pc.Values, str) {
+			return fmt.Errorf("Parameter '%s' must be one of: %s", name, strings.Join(pc.Values, ", "))
+		}
+	}
+
+	return nil
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+
+	return 0, false
 }
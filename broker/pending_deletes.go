@@ -0,0 +1,135 @@
+package broker
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+const defaultPendingDeleteInterval = 30 * time.Second
+
+// scheduleDelete records the intent to delete a cache cluster that cannot be deleted yet
+// because it is still being created. The delete is issued by ProcessPendingDeletes.
+// The intent is only kept by a durable store: with a memory store, a broker restart would lose
+// it and leave the cache cluster behind, so the deprovision is rejected until it is created.
+func (b *ElastiCacheBroker) scheduleDelete(instanceID string, cacheClusterID string) error {
+	if !b.store.Durable() {
+		err := fmt.Errorf("Cannot deprovision service instance '%s' while cache cluster '%s' is being created: configure a file or sql store to defer the delete", instanceID, cacheClusterID)
+		return api.NewConcurrencyErrorResponse(err, "non-durable-store")
+	}
+
+	tags, err := b.cacheCluster.ListTags(cacheClusterID)
+	if err != nil {
+		return err
+	}
+
+	if err = b.checkOwnership(instanceID, cacheClusterID, tags); err != nil {
+		return err
+	}
+
+	err = b.storeInstance(instanceID, func(instance *store.Instance) {
+		instance.CacheClusterID = cacheClusterID
+		if !isDeletePending(*instance) {
+			startOperation(instance, store.OperationDeprovision)
+			instance.Operation.Pending = true
+		}
+	})
+	if err != nil {
+		if err == store.ErrConcurrentUpdate {
+			err = fmt.Errorf("Cannot deprovision service instance '%s': another operation is starting", instanceID)
+			return api.NewConcurrencyErrorResponse(err, "concurrent-operation")
+		}
+		return err
+	}
+
+	b.logger.Info("scheduled-delete", lager.Data{instanceIDLogKey: instanceID, "cache-cluster-id": cacheClusterID})
+
+	return nil
+}
+
+// isDeletePending reports whether the service instance has a delete waiting to be issued.
+func (b *ElastiCacheBroker) isDeletePending(instanceID string) bool {
+	instance, err := b.store.GetInstance(instanceID)
+	if err != nil {
+		return false
+	}
+
+	return isDeletePending(instance)
+}
+
+func isDeletePending(instance store.Instance) bool {
+	return instance.Operation != nil && instance.Operation.Type == store.OperationDeprovision && instance.Operation.Pending
+}
+
+// RunPendingDeletes processes the pending deletes periodically, until stop is closed.
+func (b *ElastiCacheBroker) RunPendingDeletes(stop <-chan struct{}) {
+	b.runPeriodically(b.pendingDeleteInterval, stop, "process-pending-deletes", b.ProcessPendingDeletes)
+}
+
+// ProcessPendingDeletes issues the pending deletes of the cache clusters that are no longer
+// being created. Failed deletes are kept pending and retried on the next run.
+func (b *ElastiCacheBroker) ProcessPendingDeletes() error {
+	instances, err := b.store.ListInstances()
+	if err != nil {
+		return err
+	}
+
+	for _, instance := range instances {
+		if !isDeletePending(instance) {
+			continue
+		}
+		if err = b.issuePendingDelete(instance); err != nil {
+			b.logger.Error("issue-pending-delete", err, lager.Data{instanceIDLogKey: instance.ID, "cache-cluster-id": instance.CacheClusterID})
+		}
+	}
+
+	return nil
+}
+
+// issuePendingDelete deletes the cache cluster of a service instance once it is deletable. The
+// delete is claimed in the store first, so only one broker replica issues it.
+func (b *ElastiCacheBroker) issuePendingDelete(instance store.Instance) error {
+	cacheClusterDetails, err := b.cacheCluster.Describe(instance.CacheClusterID)
+	if err != nil {
+		if err == awselasticache.ErrCacheClusterDoesNotExist {
+			return nil
+		}
+		return err
+	}
+
+	if cacheClusterDetails.Status == cacheClusterStatusCreating {
+		return nil
+	}
+
+	instance.Operation.Pending = false
+	instance.UpdatedAt = time.Now()
+	if err = b.store.PutInstance(instance); err != nil {
+		if err == store.ErrConcurrentUpdate {
+			return nil
+		}
+		return err
+	}
+
+	if cacheClusterDetails.Status != cacheClusterStatusDeleting {
+		if err = b.cacheCluster.Delete(instance.CacheClusterID); err != nil {
+			restoreErr := b.storeInstance(instance.ID, func(instance *store.Instance) {
+				if instance.Operation != nil && instance.Operation.Type == store.OperationDeprovision {
+					instance.Operation.Pending = true
+				}
+			})
+			if restoreErr != nil {
+				b.logger.Error("restore-pending-delete", restoreErr, lager.Data{instanceIDLogKey: instance.ID})
+			}
+			return err
+		}
+	}
+
+	b.logger.Info("issued-pending-delete", lager.Data{instanceIDLogKey: instance.ID, "cache-cluster-id": instance.CacheClusterID})
+
+	return nil
+}
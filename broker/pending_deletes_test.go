@@ -0,0 +1,202 @@
+package broker_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/frodenas/brokerapi"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+	cwfakes "github.com/cloudfoundry-community/elasticache-broker/awscloudwatch/fakes"
+	ec2fakes "github.com/cloudfoundry-community/elasticache-broker/awsec2/fakes"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache/fakes"
+	. "github.com/cloudfoundry-community/elasticache-broker/broker"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+var _ = Describe("Pending Deletes", func() {
+	const instanceID = "ce71b484-d542-40f7-9dd4-5526e38c81ba"
+
+	var (
+		cacheCluster *fakes.FakeCacheCluster
+		storeDir     string
+		brokerStore  store.Store
+
+		deprovisionDetails brokerapi.DeprovisionDetails
+
+		logger            *lagertest.TestLogger
+		elastiCacheBroker *ElastiCacheBroker
+	)
+
+	setCacheClusterStatus := func(status string) {
+		cacheCluster.DescribeCacheClusters["cf-aso4rtfujlvj"] = awselasticache.CacheClusterDetails{
+			CacheClusterId: "cf-aso4rtfujlvj",
+			Status:         status,
+			Engine:         "redis",
+			Tags:           map[string]string{"Instance ID": instanceID, "Broker ID": "cf"},
+		}
+	}
+
+	BeforeEach(func() {
+		cacheCluster = &fakes.FakeCacheCluster{
+			DescribeCacheClusters: map[string]awselasticache.CacheClusterDetails{},
+		}
+		setCacheClusterStatus("creating")
+
+		var err error
+		storeDir, err = ioutil.TempDir("", "broker")
+		Expect(err).ToNot(HaveOccurred())
+		brokerStore, err = store.NewFileStore(filepath.Join(storeDir, "state.json"), lagertest.NewTestLogger("broker_test"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(brokerStore.PutInstance(store.Instance{
+			ID:             instanceID,
+			ServiceID:      "Service-1",
+			PlanID:         "Plan-1",
+			CacheClusterID: "cf-aso4rtfujlvj",
+			Operation:      &store.Operation{Type: store.OperationProvision, StartedAt: time.Now()},
+		})).To(Succeed())
+		deprovisionDetails = brokerapi.DeprovisionDetails{ServiceID: "Service-1", PlanID: "Plan-1"}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(storeDir)
+	})
+
+	JustBeforeEach(func() {
+		config := Config{
+			Region:      "elasticache-region",
+			CachePrefix: "cf",
+			Catalog: Catalog{
+				Services: []Service{
+					Service{
+						ID: "Service-1",
+						Plans: []ServicePlan{
+							ServicePlan{
+								ID: "Plan-1",
+								ElastiCacheProperties: ElastiCacheProperties{
+									CacheInstanceClass: "cache.t2.micro",
+									Engine:             "redis",
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		logger = lagertest.NewTestLogger("broker_test")
+		elastiCacheBroker = New(config, cacheCluster, &fakes.FakeCacheSubnetGroup{}, &ec2fakes.FakeSecurityGroup{}, &cwfakes.FakeCacheClusterMetrics{}, nil, brokerStore, logger)
+	})
+
+	Context("when deprovisioned while the cache cluster is being created", func() {
+		JustBeforeEach(func() {
+			asynch, err := elastiCacheBroker.Deprovision(instanceID, deprovisionDetails, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(asynch).To(BeTrue())
+		})
+
+		It("records the delete while the cache cluster is being created", func() {
+			Expect(cacheCluster.DeleteCalled).To(BeFalse())
+
+			instance, err := brokerStore.GetInstance(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance.Operation.Type).To(Equal(store.OperationDeprovision))
+			Expect(instance.Operation.Pending).To(BeTrue())
+		})
+
+		It("accepts repeated deprovisions", func() {
+			setCacheClusterStatus("available")
+
+			asynch, err := elastiCacheBroker.Deprovision(instanceID, deprovisionDetails, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(asynch).To(BeTrue())
+			Expect(cacheCluster.DeleteCalled).To(BeFalse())
+		})
+
+		It("reports the delete in progress until it is issued", func() {
+			setCacheClusterStatus("available")
+
+			lastOperationResponse, err := elastiCacheBroker.LastOperation(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lastOperationResponse.State).To(Equal(brokerapi.LastOperationInProgress))
+			Expect(lastOperationResponse.Description).To(ContainSubstring("waiting to delete it"))
+		})
+
+		It("does not issue the delete while the cache cluster is being created", func() {
+			Expect(elastiCacheBroker.ProcessPendingDeletes()).To(Succeed())
+			Expect(cacheCluster.DeleteCalled).To(BeFalse())
+		})
+
+		It("issues the delete once the cache cluster is created", func() {
+			setCacheClusterStatus("available")
+
+			Expect(elastiCacheBroker.ProcessPendingDeletes()).To(Succeed())
+			Expect(cacheCluster.DeleteCalled).To(BeTrue())
+			Expect(cacheCluster.DeleteID).To(Equal("cf-aso4rtfujlvj"))
+
+			instance, err := brokerStore.GetInstance(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance.Operation.Type).To(Equal(store.OperationDeprovision))
+			Expect(instance.Operation.Pending).To(BeFalse())
+		})
+
+		It("keeps the delete pending when it fails", func() {
+			setCacheClusterStatus("available")
+			cacheCluster.DeleteError = errors.New("operation failed")
+
+			Expect(elastiCacheBroker.ProcessPendingDeletes()).To(Succeed())
+			Expect(cacheCluster.DeleteCalled).To(BeTrue())
+
+			instance, err := brokerStore.GetInstance(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance.Operation.Pending).To(BeTrue())
+		})
+
+		It("stops when asked to", func() {
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				elastiCacheBroker.RunPendingDeletes(stop)
+				close(done)
+			}()
+
+			close(stop)
+			Eventually(done).Should(BeClosed())
+		})
+	})
+
+	Context("with a memory store", func() {
+		BeforeEach(func() {
+			brokerStore = store.NewMemoryStore()
+			Expect(brokerStore.PutInstance(store.Instance{
+				ID:             instanceID,
+				ServiceID:      "Service-1",
+				PlanID:         "Plan-1",
+				CacheClusterID: "cf-aso4rtfujlvj",
+				Operation:      &store.Operation{Type: store.OperationProvision, StartedAt: time.Now()},
+			})).To(Succeed())
+		})
+
+		It("rejects deprovisions while the cache cluster is being created, as the delete would be lost on restart", func() {
+			_, err := elastiCacheBroker.Deprovision(instanceID, deprovisionDetails, true)
+			Expect(err).To(MatchError("Cannot deprovision service instance 'ce71b484-d542-40f7-9dd4-5526e38c81ba' while cache cluster 'cf-aso4rtfujlvj' is being created: configure a file or sql store to defer the delete"))
+			Expect(err.(*api.FailureResponse).StatusCode()).To(Equal(422))
+			Expect(cacheCluster.DeleteCalled).To(BeFalse())
+
+			instance, err := brokerStore.GetInstance(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance.Operation.Type).To(Equal(store.OperationProvision))
+		})
+
+		It("warns at startup that such deprovisions are rejected", func() {
+			Expect(logger.LogMessages()).To(ContainElement("broker_test.broker.non-durable-store"))
+		})
+	})
+})
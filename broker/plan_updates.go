@@ -0,0 +1,71 @@
+package broker
+
+import (
+	"fmt"
+
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+)
+
+const bytesPerMiB = 1024 * 1024
+
+// checkPlanUpdate enforces the allowed plan transitions: the operator defined
+// AllowedPlanUpdates, no engine changes, and no downgrades to a plan with less memory than
+// the cache cluster is currently using. Rejected transitions are reported as 422 errors.
+func (b *ElastiCacheBroker) checkPlanUpdate(cacheClusterID string, cacheClusterDetails awselasticache.CacheClusterDetails, previousPlan ServicePlan, servicePlan ServicePlan) error {
+	if previousPlan.AllowedPlanUpdates != nil && !containsString(previousPlan.AllowedPlanUpdates, servicePlan.ID) {
+		err := fmt.Errorf("Service Plan '%s' cannot be updated to Service Plan '%s'", previousPlan.Name, servicePlan.Name)
+		return api.NewUnprocessableEntityResponse(err, "plan-update-not-allowed")
+	}
+
+	if previousPlan.ElastiCacheProperties.Engine != servicePlan.ElastiCacheProperties.Engine {
+		err := fmt.Errorf("Service Plan '%s' uses the '%s' engine and cannot be updated to Service Plan '%s' using the '%s' engine", previousPlan.Name, previousPlan.ElastiCacheProperties.Engine, servicePlan.Name, servicePlan.ElastiCacheProperties.Engine)
+		return api.NewUnprocessableEntityResponse(err, "plan-update-not-allowed")
+	}
+
+	previousMemory, ok := planMemory(previousPlan)
+	if !ok {
+		return nil
+	}
+
+	memory, ok := planMemory(servicePlan)
+	if !ok || memory >= previousMemory {
+		return nil
+	}
+
+	var cacheNodeIDs []string
+	for _, cacheNode := range cacheClusterDetails.CacheNodes {
+		cacheNodeIDs = append(cacheNodeIDs, cacheNode.CacheNodeId)
+	}
+
+	memoryUsage, err := b.cacheClusterMetrics.MemoryUsage(cacheClusterID, cacheClusterDetails.Engine, cacheNodeIDs)
+	if err != nil {
+		return err
+	}
+
+	if memoryUsage > memory {
+		err := fmt.Errorf("Service Plan '%s' provides %d MiB of memory, but the cache cluster is using %d MiB", servicePlan.Name, memory/bytesPerMiB, memoryUsage/bytesPerMiB)
+		return api.NewUnprocessableEntityResponse(err, "plan-update-not-allowed")
+	}
+
+	return nil
+}
+
+// planMemory returns the memory in bytes of the plan cache cluster, if its cache node memory is
+// configured or its node type is known.
+func planMemory(servicePlan ServicePlan) (int64, bool) {
+	nodeMemory := servicePlan.ElastiCacheProperties.CacheNodeMemory
+	if nodeMemory == 0 {
+		var ok bool
+		if nodeMemory, ok = cacheNodeTypeMemory[servicePlan.ElastiCacheProperties.CacheInstanceClass]; !ok {
+			return 0, false
+		}
+	}
+
+	numCacheNodes := servicePlan.ElastiCacheProperties.NumCacheNodes
+	if numCacheNodes == 0 {
+		numCacheNodes = 1
+	}
+
+	return int64(nodeMemory*1024*bytesPerMiB) * numCacheNodes, true
+}
@@ -0,0 +1,131 @@
+package broker
+
+import (
+	"encoding/json"
+
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+	"github.com/cloudfoundry-community/elasticache-broker/jsonschema"
+)
+
+const jsonSchemaDraft4 = "http://json-schema.org/draft-04/schema#"
+
+// Catalog returns the OSBAPI catalog including the plan schemas and the retrievable flags, which
+// brokerapi.CatalogResponse cannot carry.
+func (b *ElastiCacheBroker) Catalog() api.CatalogResponse {
+	catalogResponse := api.CatalogResponse{}
+
+	brokerCatalog, err := json.Marshal(b.catalog)
+	if err != nil {
+		b.logger.Error("marshal-error", err)
+		return catalogResponse
+	}
+
+	if err = json.Unmarshal(brokerCatalog, &catalogResponse); err != nil {
+		b.logger.Error("unmarshal-error", err)
+		return catalogResponse
+	}
+
+	for i, service := range catalogResponse.Services {
+		catalogResponse.Services[i].InstancesRetrievable = b.store.Durable()
+		catalogResponse.Services[i].BindingsRetrievable = b.store.Durable()
+		for j, plan := range service.Plans {
+			if servicePlan, ok := b.catalog.FindServicePlan(plan.ID); ok {
+				schemas := b.planSchemas(servicePlan)
+				catalogResponse.Services[i].Plans[j].Schemas = &schemas
+			}
+		}
+	}
+
+	return catalogResponse
+}
+
+// planSchemas returns the schemas configured at the service plan, generating the missing
+// ones from the supported parameter definitions and the plan allowed parameters.
+func (b *ElastiCacheBroker) planSchemas(servicePlan ServicePlan) api.ServiceSchemas {
+	schemas := api.ServiceSchemas{}
+	if servicePlan.Schemas != nil {
+		schemas = *servicePlan.Schemas
+	}
+
+	if schemas.ServiceInstance.Create == nil {
+		schemas.ServiceInstance.Create = &api.InputParametersSchema{
+			Parameters: parametersSchema(provisionParameterDefinitions, servicePlan.AllowedParameters, b.allowUserProvisionParameters),
+		}
+	}
+
+	if schemas.ServiceInstance.Update == nil {
+		schemas.ServiceInstance.Update = &api.InputParametersSchema{
+			Parameters: parametersSchema(updateParameterDefinitions, servicePlan.AllowedParameters, b.allowUserUpdateParameters),
+		}
+	}
+
+	if schemas.ServiceBinding.Create == nil {
+		schemas.ServiceBinding.Create = &api.InputParametersSchema{
+			Parameters: parametersSchema(nil, nil, false),
+		}
+	}
+
+	return schemas
+}
+
+func parametersSchema(definitions []parameterDefinition, allowedParameters map[string]ParameterConstraints, enabled bool) map[string]interface{} {
+	properties := make(map[string]interface{})
+
+	if enabled {
+		for _, definition := range definitions {
+			constraints, ok := allowedParameters[definition.Name]
+			if allowedParameters != nil && !ok {
+				continue
+			}
+
+			property := map[string]interface{}{
+				"type":        definition.Type,
+				"description": definition.Description,
+			}
+			if definition.Type == parameterTypeObject {
+				property["additionalProperties"] = map[string]interface{}{"type": parameterTypeString}
+			}
+			if constraints.Minimum != nil {
+				property["minimum"] = *constraints.Minimum
+			}
+			if constraints.Maximum != nil {
+				property["maximum"] = *constraints.Maximum
+			}
+			if len(constraints.Values) > 0 {
+				var values []interface{}
+				for _, value := range constraints.Values {
+					values = append(values, value)
+				}
+				property["enum"] = values
+			}
+
+			properties[definition.Name] = property
+		}
+	}
+
+	return map[string]interface{}{
+		"$schema":              jsonSchemaDraft4,
+		"type":                 parameterTypeObject,
+		"additionalProperties": false,
+		"properties":           properties,
+	}
+}
+
+// validateParameters validates the user parameters against a plan schema, also when none are
+// sent, so that required properties are enforced. Violations are reported to the platform as a
+// 400 Bad Request.
+func validateParameters(schema *api.InputParametersSchema, parameters map[string]interface{}) error {
+	if schema == nil || schema.Parameters == nil {
+		return nil
+	}
+
+	if parameters == nil {
+		parameters = make(map[string]interface{})
+	}
+
+	if err := jsonschema.Validate(schema.Parameters, parameters); err != nil {
+		return api.NewBadRequestResponse(err, "invalid-parameters")
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package broker
+
+import (
+	"fmt"
+
+	"github.com/pivotal-golang/lager"
+
+	"github.com/cloudfoundry-community/elasticache-broker/awsec2"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+)
+
+// createInstanceSecurityGroup creates a security group dedicated to a service instance that only
+// allows traffic to the engine port from the CIDRs configured at the service plan. If the security
+// group already exists (i.e. a previous provision attempt failed) it is reused.
+func (b *ElastiCacheBroker) createInstanceSecurityGroup(instanceID string, servicePlan ServicePlan, instance *awselasticache.CacheClusterDetails) (string, error) {
+	securityGroupName := b.instanceSecurityGroupName(instanceID)
+
+	securityGroupDetails, err := b.securityGroup.FindByName(securityGroupName)
+	if err == nil {
+		return securityGroupDetails.ID, nil
+	}
+	if err != awsec2.ErrSecurityGroupDoesNotExist {
+		return "", err
+	}
+
+	cacheSubnetGroupDetails, err := b.cacheSubnetGroup.Describe(instance.CacheSubnetGroupName)
+	if err != nil {
+		return "", fmt.Errorf("Cache Subnet Group '%s': %s", instance.CacheSubnetGroupName, err)
+	}
+
+	port := enginePort(instance.Engine, instance.Port)
+	securityGroupDetails = awsec2.SecurityGroupDetails{
+		Description: ManagedResourceDescription,
+		VpcID:       cacheSubnetGroupDetails.VpcID,
+		IngressRules: []awsec2.IngressRule{
+			awsec2.IngressRule{
+				Protocol: "tcp",
+				FromPort: port,
+				ToPort:   port,
+				CIDRs:    servicePlan.ElastiCacheProperties.InstanceSecurityGroupCIDRs,
+			},
+		},
+	}
+
+	securityGroupID, err := b.securityGroup.Create(securityGroupName, securityGroupDetails)
+	if err != nil {
+		return "", err
+	}
+	b.logger.Info("created-instance-security-group", lager.Data{instanceIDLogKey: instanceID, "security-group-id": securityGroupID})
+
+	return securityGroupID, nil
+}
+
+// deleteInstanceSecurityGroup deletes the security group dedicated to a service instance, if any.
+// It returns awsec2.ErrSecurityGroupInUse while the cache cluster network interfaces are still attached.
+func (b *ElastiCacheBroker) deleteInstanceSecurityGroup(instanceID string) error {
+	if !b.catalog.usesInstanceSecurityGroups() {
+		return nil
+	}
+
+	securityGroupDetails, err := b.securityGroup.FindByName(b.instanceSecurityGroupName(instanceID))
+	if err != nil {
+		if err == awsec2.ErrSecurityGroupDoesNotExist {
+			return nil
+		}
+		return err
+	}
+
+	if err = b.securityGroup.Delete(securityGroupDetails.ID); err != nil {
+		if err == awsec2.ErrSecurityGroupDoesNotExist {
+			return nil
+		}
+		return err
+	}
+	b.logger.Info("deleted-instance-security-group", lager.Data{instanceIDLogKey: instanceID, "security-group-id": securityGroupDetails.ID})
+
+	return nil
+}
+
+func (b *ElastiCacheBroker) instanceSecurityGroupName(instanceID string) string {
+	return fmt.Sprintf("%s-%s", b.cachePrefix, instanceID)
+}
+
+func enginePort(engine string, port int64) int64 {
+	if port > 0 {
+		return port
+	}
+
+	return defaultEnginePorts[engine]
+}
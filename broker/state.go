@@ -0,0 +1,101 @@
+package broker
+
+import (
+	"time"
+
+	"github.com/frodenas/brokerapi"
+
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+// storeInstance applies update to the stored service instance and records it. Service
+// instances created before the broker had a store are recorded on the fly.
+func (b *ElastiCacheBroker) storeInstance(instanceID string, update func(instance *store.Instance)) error {
+	now := time.Now()
+
+	instance, err := b.store.GetInstance(instanceID)
+	if err != nil {
+		if err != store.ErrInstanceNotFound {
+			return err
+		}
+		instance = store.Instance{ID: instanceID, CreatedAt: now}
+	}
+
+	update(&instance)
+	instance.UpdatedAt = now
+
+	return b.store.PutInstance(instance)
+}
+
+// startOperation records the asynchronous operation in progress on the stored service instance.
+func startOperation(instance *store.Instance, operationType string) {
+	instance.Operation = &store.Operation{Type: operationType, StartedAt: time.Now()}
+}
+
+// finishOperation clears the operation in progress on the stored service instance, if any.
+func (b *ElastiCacheBroker) finishOperation(instanceID string) error {
+	instance, err := b.store.GetInstance(instanceID)
+	if err != nil {
+		if err == store.ErrInstanceNotFound {
+			return nil
+		}
+		return err
+	}
+
+	if instance.Operation == nil {
+		return nil
+	}
+
+	instance.Operation = nil
+	instance.UpdatedAt = time.Now()
+
+	return b.store.PutInstance(instance)
+}
+
+// forgetInstance deletes the stored service instance and its bindings, if any.
+func (b *ElastiCacheBroker) forgetInstance(instanceID string) error {
+	if err := b.store.DeleteInstance(instanceID); err != nil && err != store.ErrInstanceNotFound {
+		return err
+	}
+
+	return nil
+}
+
+// previousPlanID returns the plan a service instance is updated from: the previous plan sent
+// by the platform or, when the platform sends no previous values, the plan recorded in the store.
+func (b *ElastiCacheBroker) previousPlanID(instanceID string, details brokerapi.UpdateDetails) (string, error) {
+	if details.PreviousValues.PlanID != "" {
+		return details.PreviousValues.PlanID, nil
+	}
+
+	instance, err := b.store.GetInstance(instanceID)
+	if err != nil {
+		if err == store.ErrInstanceNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return instance.PlanID, nil
+}
+
+// mergeParameters merges the parameters of an update into the recorded provision parameters.
+// Parameters that only apply to updates (e.g. apply_immediately) are not recorded, as they are
+// not part of the service instance configuration.
+func mergeParameters(parameters map[string]interface{}, newParameters map[string]interface{}) map[string]interface{} {
+	if len(newParameters) == 0 {
+		return parameters
+	}
+
+	merged := make(map[string]interface{})
+	for key, value := range parameters {
+		merged[key] = value
+	}
+	for key, value := range newParameters {
+		if _, ok := findParameterDefinition(provisionParameterDefinitions, key); ok {
+			merged[key] = value
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,200 @@
+package broker_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/frodenas/brokerapi"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	cwfakes "github.com/cloudfoundry-community/elasticache-broker/awscloudwatch/fakes"
+	ec2fakes "github.com/cloudfoundry-community/elasticache-broker/awsec2/fakes"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache/fakes"
+	. "github.com/cloudfoundry-community/elasticache-broker/broker"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+var _ = Describe("Broker State", func() {
+	const instanceID = "ce71b484-d542-40f7-9dd4-5526e38c81ba"
+
+	var (
+		cacheCluster *fakes.FakeCacheCluster
+		brokerStore  *store.MemoryStore
+
+		elastiCacheBroker *ElastiCacheBroker
+	)
+
+	BeforeEach(func() {
+		cacheCluster = &fakes.FakeCacheCluster{
+			DescribeCacheClusters: map[string]awselasticache.CacheClusterDetails{
+				"cf-aso4rtfujlvj": awselasticache.CacheClusterDetails{
+					CacheClusterId: "cf-aso4rtfujlvj",
+					Status:         "available",
+					Engine:         "redis",
+					Tags:           map[string]string{"Instance ID": instanceID, "Broker ID": "cf"},
+				},
+			},
+		}
+		brokerStore = store.NewMemoryStore()
+	})
+
+	JustBeforeEach(func() {
+		config := Config{
+			Region:                       "elasticache-region",
+			CachePrefix:                  "cf",
+			AllowUserProvisionParameters: true,
+			AllowUserUpdateParameters:    true,
+			Catalog: Catalog{
+				Services: []Service{
+					Service{
+						ID:             "Service-1",
+						Bindable:       true,
+						PlanUpdateable: true,
+						Plans: []ServicePlan{
+							ServicePlan{
+								ID: "Plan-1",
+								ElastiCacheProperties: ElastiCacheProperties{
+									CacheInstanceClass: "cache.t2.micro",
+									Engine:             "redis",
+								},
+							},
+							ServicePlan{
+								ID: "Plan-2",
+								ElastiCacheProperties: ElastiCacheProperties{
+									CacheInstanceClass: "cache.m3.medium",
+									Engine:             "redis",
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		elastiCacheBroker = New(config, cacheCluster, &fakes.FakeCacheSubnetGroup{}, &ec2fakes.FakeSecurityGroup{}, &cwfakes.FakeCacheClusterMetrics{}, nil, brokerStore, lagertest.NewTestLogger("broker_test"))
+	})
+
+	It("records provisioned service instances with the operation in progress", func() {
+		delete(cacheCluster.DescribeCacheClusters, "cf-aso4rtfujlvj")
+
+		_, _, err := elastiCacheBroker.Provision(instanceID, brokerapi.ProvisionDetails{
+			ServiceID:        "Service-1",
+			PlanID:           "Plan-1",
+			OrganizationGUID: "organization-id",
+			SpaceGUID:        "space-id",
+			Parameters:       map[string]interface{}{"engine_version": "2.8.24"},
+		}, true)
+		Expect(err).ToNot(HaveOccurred())
+
+		instance, err := brokerStore.GetInstance(instanceID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(instance.PlanID).To(Equal("Plan-1"))
+		Expect(instance.OrganizationID).To(Equal("organization-id"))
+		Expect(instance.SpaceID).To(Equal("space-id"))
+		Expect(instance.CacheClusterID).To(Equal("cf-aso4rtfujlvj"))
+		Expect(instance.Parameters).To(Equal(map[string]interface{}{"engine_version": "2.8.24"}))
+		Expect(instance.Operation.Type).To(Equal(store.OperationProvision))
+	})
+
+	It("records the failure of service instances that could not be created", func() {
+		delete(cacheCluster.DescribeCacheClusters, "cf-aso4rtfujlvj")
+		cacheCluster.CreateError = awselasticache.ErrCacheClusterDoesNotExist
+
+		_, _, err := elastiCacheBroker.Provision(instanceID, brokerapi.ProvisionDetails{ServiceID: "Service-1", PlanID: "Plan-1"}, true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(elastiCacheBroker.ResumeWorkflows()).To(Succeed())
+
+		lastOperationResponse, err := elastiCacheBroker.LastOperation(instanceID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lastOperationResponse.State).To(Equal(brokerapi.LastOperationFailed))
+
+		instance, err := brokerStore.GetInstance(instanceID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(instance.Operation).To(BeNil())
+		Expect(instance.Failure.Reason).To(Equal("Step 'create-cache-cluster' failed: " + awselasticache.ErrCacheClusterDoesNotExist.Error()))
+	})
+
+	Context("when the service instance is recorded", func() {
+		BeforeEach(func() {
+			Expect(brokerStore.PutInstance(store.Instance{
+				ID:             instanceID,
+				ServiceID:      "Service-1",
+				PlanID:         "Plan-1",
+				CacheClusterID: "renamed",
+				Operation:      &store.Operation{Type: store.OperationProvision},
+			})).To(Succeed())
+			cacheCluster.DescribeCacheClusters["renamed"] = cacheCluster.DescribeCacheClusters["cf-aso4rtfujlvj"]
+			delete(cacheCluster.DescribeCacheClusters, "cf-aso4rtfujlvj")
+		})
+
+		It("looks up the recorded cache cluster", func() {
+			lastOperationResponse, err := elastiCacheBroker.LastOperation(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lastOperationResponse.Description).To(ContainSubstring("'renamed'"))
+			Expect(cacheCluster.DescribeIDs).To(Equal([]string{"renamed"}))
+		})
+
+		It("clears the operation once it is finished", func() {
+			_, err := elastiCacheBroker.LastOperation(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+
+			instance, err := brokerStore.GetInstance(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance.Operation).To(BeNil())
+		})
+
+		It("records plan updates", func() {
+			_, err := elastiCacheBroker.Update(instanceID, brokerapi.UpdateDetails{ServiceID: "Service-1", PlanID: "Plan-2"}, true)
+			Expect(err).ToNot(HaveOccurred())
+
+			instance, err := brokerStore.GetInstance(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance.PlanID).To(Equal("Plan-2"))
+			Expect(instance.Operation.Type).To(Equal(store.OperationUpdate))
+		})
+
+		It("records the provision parameters changed by updates", func() {
+			updateParameters := map[string]interface{}{
+				"apply_immediately":            true,
+				"preferred_maintenance_window": "sun:05:00-sun:09:00",
+			}
+
+			_, err := elastiCacheBroker.Update(instanceID, brokerapi.UpdateDetails{ServiceID: "Service-1", PlanID: "Plan-1", Parameters: updateParameters}, true)
+			Expect(err).ToNot(HaveOccurred())
+
+			instance, err := brokerStore.GetInstance(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance.Parameters).To(Equal(map[string]interface{}{"preferred_maintenance_window": "sun:05:00-sun:09:00"}))
+		})
+
+		It("records deprovisions in progress", func() {
+			_, err := elastiCacheBroker.Deprovision(instanceID, brokerapi.DeprovisionDetails{ServiceID: "Service-1", PlanID: "Plan-1"}, true)
+			Expect(err).ToNot(HaveOccurred())
+
+			instance, err := brokerStore.GetInstance(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance.Operation.Type).To(Equal(store.OperationDeprovision))
+		})
+
+		It("forgets the service instance once its cache cluster is gone", func() {
+			delete(cacheCluster.DescribeCacheClusters, "renamed")
+
+			_, err := elastiCacheBroker.LastOperation(instanceID)
+			Expect(err).To(Equal(brokerapi.ErrInstanceDoesNotExist))
+			Expect(brokerStore.ListInstances()).To(BeEmpty())
+		})
+
+		It("records bindings", func() {
+			_, err := elastiCacheBroker.Bind(instanceID, "binding-id", brokerapi.BindDetails{ServiceID: "Service-1", PlanID: "Plan-1", AppGUID: "app-guid"})
+			Expect(err).ToNot(HaveOccurred())
+
+			binding, err := brokerStore.GetBinding(instanceID, "binding-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(binding.AppGUID).To(Equal("app-guid"))
+
+			err = elastiCacheBroker.Unbind(instanceID, "binding-id", brokerapi.UnbindDetails{ServiceID: "Service-1", PlanID: "Plan-1"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(brokerStore.ListBindings(instanceID)).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,110 @@
+package broker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pivotal-golang/lager"
+
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+)
+
+const cacheSubnetGroupInfix = "subnets"
+
+// ManagedResourceDescription describes the cache subnet groups and security groups created and
+// managed by the broker, which it recognizes by this description.
+const ManagedResourceDescription = "Managed by AWS ElastiCache Service Broker"
+
+// SyncCacheSubnetGroups makes sure that every plan configured with `subnet_ids` has its
+// cache subnet group created (which validates the subnets against AWS) and removes the
+// subnet groups previously created by the broker that are no longer referenced by any plan.
+func (b *ElastiCacheBroker) SyncCacheSubnetGroups() error {
+	desiredCacheSubnetGroups := make(map[string]bool)
+	for _, service := range b.catalog.Services {
+		for _, servicePlan := range service.Plans {
+			if len(servicePlan.ElastiCacheProperties.SubnetIDs) == 0 {
+				continue
+			}
+
+			cacheSubnetGroupName, err := b.ensureCacheSubnetGroup(servicePlan.ElastiCacheProperties.SubnetIDs)
+			if err != nil {
+				return fmt.Errorf("Service Plan '%s' subnets: %s", servicePlan.ID, err)
+			}
+			desiredCacheSubnetGroups[cacheSubnetGroupName] = true
+		}
+	}
+
+	cacheSubnetGroups, err := b.cacheSubnetGroup.List(b.cacheSubnetGroupNamePrefix())
+	if err != nil {
+		return err
+	}
+
+	for _, cacheSubnetGroup := range cacheSubnetGroups {
+		if desiredCacheSubnetGroups[cacheSubnetGroup.Name] || cacheSubnetGroup.Description != ManagedResourceDescription {
+			continue
+		}
+
+		if err := b.cacheSubnetGroup.Delete(cacheSubnetGroup.Name); err != nil {
+			if err == awselasticache.ErrCacheSubnetGroupInUse || err == awselasticache.ErrCacheSubnetGroupDoesNotExist {
+				b.logger.Info("skip-cache-subnet-group-cleanup", lager.Data{"cache-subnet-group": cacheSubnetGroup.Name, "reason": err.Error()})
+				continue
+			}
+			return err
+		}
+		b.logger.Info("deleted-unused-cache-subnet-group", lager.Data{"cache-subnet-group": cacheSubnetGroup.Name})
+	}
+
+	return nil
+}
+
+func (b *ElastiCacheBroker) ensureCacheSubnetGroup(subnetIDs []string) (string, error) {
+	cacheSubnetGroupName := b.cacheSubnetGroupName(subnetIDs)
+
+	cacheSubnetGroupDetails, err := b.cacheSubnetGroup.Describe(cacheSubnetGroupName)
+	if err == nil {
+		if b.cacheSubnetGroupName(cacheSubnetGroupDetails.SubnetIDs) != cacheSubnetGroupName {
+			return "", fmt.Errorf("Cache Subnet Group '%s' exists with different subnets %v", cacheSubnetGroupName, cacheSubnetGroupDetails.SubnetIDs)
+		}
+		return cacheSubnetGroupName, nil
+	}
+	if err != awselasticache.ErrCacheSubnetGroupDoesNotExist {
+		return "", err
+	}
+
+	cacheSubnetGroupDetails = awselasticache.CacheSubnetGroupDetails{
+		Description: ManagedResourceDescription,
+		SubnetIDs:   subnetIDs,
+	}
+	if err = b.cacheSubnetGroup.Create(cacheSubnetGroupName, cacheSubnetGroupDetails); err != nil {
+		return "", err
+	}
+	b.logger.Info("created-cache-subnet-group", lager.Data{"cache-subnet-group": cacheSubnetGroupName, "subnet-ids": subnetIDs})
+
+	return cacheSubnetGroupName, nil
+}
+
+func (b *ElastiCacheBroker) cacheSubnetGroupNamePrefix() string {
+	return strings.ToLower(fmt.Sprintf("%s-%s-", b.cachePrefix, cacheSubnetGroupInfix))
+}
+
+// cacheSubnetGroupName derives the subnet group name from the content of the subnet list,
+// so plans sharing the same subnets (in any order) share the same subnet group.
+func (b *ElastiCacheBroker) cacheSubnetGroupName(subnetIDs []string) string {
+	uniqueSubnetIDs := make(map[string]bool)
+	for _, subnetID := range subnetIDs {
+		uniqueSubnetIDs[strings.ToLower(subnetID)] = true
+	}
+
+	sortedSubnetIDs := make([]string, 0, len(uniqueSubnetIDs))
+	for subnetID := range uniqueSubnetIDs {
+		sortedSubnetIDs = append(sortedSubnetIDs, subnetID)
+	}
+	sort.Strings(sortedSubnetIDs)
+
+	hash := sha256.Sum256([]byte(strings.Join(sortedSubnetIDs, ",")))
+
+	return b.cacheSubnetGroupNamePrefix() + hex.EncodeToString(hash[:])[:16]
+}
@@ -0,0 +1,122 @@
+package broker_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/pivotal-golang/lager/lagertest"
+
+	cwfakes "github.com/cloudfoundry-community/elasticache-broker/awscloudwatch/fakes"
+	ec2fakes "github.com/cloudfoundry-community/elasticache-broker/awsec2/fakes"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache/fakes"
+	. "github.com/cloudfoundry-community/elasticache-broker/broker"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+var _ = Describe("SyncCacheSubnetGroups", func() {
+	var (
+		config               Config
+		cacheCluster         *fakes.FakeCacheCluster
+		cacheSubnetGroup     *fakes.FakeCacheSubnetGroup
+		securityGroup        *ec2fakes.FakeSecurityGroup
+		elastiCacheBroker    *ElastiCacheBroker
+		servicePlanSubnetIDs []string
+	)
+
+	BeforeEach(func() {
+		servicePlanSubnetIDs = []string{"subnet-2", "subnet-1"}
+		cacheCluster = &fakes.FakeCacheCluster{}
+		securityGroup = &ec2fakes.FakeSecurityGroup{}
+		cacheSubnetGroup = &fakes.FakeCacheSubnetGroup{
+			DescribeError: awselasticache.ErrCacheSubnetGroupDoesNotExist,
+		}
+	})
+
+	JustBeforeEach(func() {
+		config = Config{
+			Region:      "elasticache-region",
+			CachePrefix: "cf",
+			Catalog: Catalog{
+				Services: []Service{
+					Service{
+						ID: "Service-1",
+						Plans: []ServicePlan{
+							ServicePlan{
+								ID: "Plan-1",
+								ElastiCacheProperties: ElastiCacheProperties{
+									SubnetIDs: servicePlanSubnetIDs,
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		elastiCacheBroker = New(config, cacheCluster, cacheSubnetGroup, securityGroup, &cwfakes.FakeCacheClusterMetrics{}, nil, store.NewMemoryStore(), lagertest.NewTestLogger("broker_test"))
+	})
+
+	It("creates the cache subnet group for plans with subnets", func() {
+		err := elastiCacheBroker.SyncCacheSubnetGroups()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cacheSubnetGroup.CreateCalled).To(BeTrue())
+		Expect(cacheSubnetGroup.CreateName).To(HavePrefix("cf-subnets-"))
+		Expect(cacheSubnetGroup.CreateCacheSubnetGroupDetails.SubnetIDs).To(Equal(servicePlanSubnetIDs))
+	})
+
+	It("names the cache subnet group after the subnets content", func() {
+		err := elastiCacheBroker.SyncCacheSubnetGroups()
+		Expect(err).ToNot(HaveOccurred())
+		createName := cacheSubnetGroup.CreateName
+
+		config.Catalog.Services[0].Plans[0].ElastiCacheProperties.SubnetIDs = []string{"subnet-1", "subnet-2", "subnet-1"}
+		elastiCacheBroker = New(config, cacheCluster, cacheSubnetGroup, securityGroup, &cwfakes.FakeCacheClusterMetrics{}, nil, store.NewMemoryStore(), lagertest.NewTestLogger("broker_test"))
+
+		err = elastiCacheBroker.SyncCacheSubnetGroups()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cacheSubnetGroup.CreateName).To(Equal(createName))
+	})
+
+	It("reuses an existing cache subnet group with the same subnets", func() {
+		cacheSubnetGroup.DescribeError = nil
+		cacheSubnetGroup.DescribeCacheSubnetGroupDetails = awselasticache.CacheSubnetGroupDetails{
+			SubnetIDs: []string{"subnet-1", "subnet-2"},
+		}
+
+		err := elastiCacheBroker.SyncCacheSubnetGroups()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cacheSubnetGroup.CreateCalled).To(BeFalse())
+	})
+
+	It("returns error if the subnets are not valid", func() {
+		cacheSubnetGroup.CreateError = errors.New("InvalidSubnet: The subnet ID subnet-1 does not exist")
+
+		err := elastiCacheBroker.SyncCacheSubnetGroups()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Service Plan 'Plan-1' subnets"))
+	})
+
+	It("deletes unused cache subnet groups created by the broker", func() {
+		cacheSubnetGroup.ListCacheSubnetGroupsDetails = []awselasticache.CacheSubnetGroupDetails{
+			awselasticache.CacheSubnetGroupDetails{Name: "cf-subnets-unused", Description: ManagedResourceDescription},
+			awselasticache.CacheSubnetGroupDetails{Name: "cf-subnets-manual", Description: "Created by hand"},
+		}
+
+		err := elastiCacheBroker.SyncCacheSubnetGroups()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cacheSubnetGroup.ListNamePrefix).To(Equal("cf-subnets-"))
+		Expect(cacheSubnetGroup.DeleteNames).To(Equal([]string{"cf-subnets-unused"}))
+	})
+
+	It("skips cache subnet groups still in use", func() {
+		cacheSubnetGroup.ListCacheSubnetGroupsDetails = []awselasticache.CacheSubnetGroupDetails{
+			awselasticache.CacheSubnetGroupDetails{Name: "cf-subnets-unused", Description: ManagedResourceDescription},
+		}
+		cacheSubnetGroup.DeleteError = awselasticache.ErrCacheSubnetGroupInUse
+
+		err := elastiCacheBroker.SyncCacheSubnetGroups()
+		Expect(err).ToNot(HaveOccurred())
+	})
+})
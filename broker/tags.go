@@ -0,0 +1,214 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+)
+
+// AWS limits for ElastiCache cost allocation tags.
+const (
+	maxTagsPerResource = 50
+	maxTagKeyLength    = 128
+	maxTagValueLength  = 256
+)
+
+var validTagCharacters = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
+// brokerTagKeys are the tag keys written by the broker itself. Any other tag found on
+// a cache cluster is considered user or operator owned and is left untouched.
+var brokerTagKeys = []string{
+	"Owner",
+	"Instance ID",
+	"Broker ID",
+	"Created by",
+	"Created at",
+	"Updated by",
+	"Updated at",
+	"Service ID",
+	"Plan ID",
+	"Organization ID",
+	"Organization",
+	"Space ID",
+	"Space",
+	"Adopted by",
+	"Adopted at",
+	keepClusterTagKey,
+}
+
+// preservedTagKeys are broker tags that are set once and must survive later updates.
+var preservedTagKeys = []string{
+	"Created by",
+	"Created at",
+	"Organization ID",
+	"Organization",
+	"Space ID",
+	"Space",
+	"Adopted by",
+	"Adopted at",
+	keepClusterTagKey,
+}
+
+// timestampTagKeys change on every update, so they are only rewritten when another tag changes.
+var timestampTagKeys = []string{
+	"Updated by",
+	"Updated at",
+}
+
+func (b *ElastiCacheBroker) isBrokerTagKey(key string) bool {
+	if _, ok := b.costAllocationTags[key]; ok {
+		return true
+	}
+
+	return containsString(brokerTagKeys, key)
+}
+
+// validateUserTags checks user supplied tags against the AWS limits and the operator
+// allowed keys. Violations are reported to the platform as a 400 Bad Request.
+func (b *ElastiCacheBroker) validateUserTags(tags map[string]string) error {
+	if err := b.checkUserTags(tags); err != nil {
+		return api.NewBadRequestResponse(err, "invalid-tags")
+	}
+
+	return nil
+}
+
+func (b *ElastiCacheBroker) checkUserTags(tags map[string]string) error {
+	if len(tags)+len(brokerTagKeys)+len(b.costAllocationTags) > maxTagsPerResource {
+		return fmt.Errorf("Too many tags: at most %d user tags are allowed", maxTagsPerResource-len(brokerTagKeys)-len(b.costAllocationTags))
+	}
+
+	for key, value := range tags {
+		if err := checkTag(key, value); err != nil {
+			return err
+		}
+
+		if b.isBrokerTagKey(key) {
+			return fmt.Errorf("Tag key '%s' is managed by the broker", key)
+		}
+
+		if len(b.allowedUserTagKeys) > 0 && !containsString(b.allowedUserTagKeys, key) {
+			return fmt.Errorf("Tag key '%s' is not allowed, allowed keys are: %s", key, strings.Join(b.allowedUserTagKeys, ", "))
+		}
+	}
+
+	return nil
+}
+
+// checkTag checks a tag against the AWS limits.
+func checkTag(key string, value string) error {
+	if key == "" {
+		return errors.New("Tag keys must not be empty")
+	}
+
+	if utf8.RuneCountInString(key) > maxTagKeyLength {
+		return fmt.Errorf("Tag key '%s' exceeds %d characters", key, maxTagKeyLength)
+	}
+
+	if utf8.RuneCountInString(value) > maxTagValueLength {
+		return fmt.Errorf("Tag '%s' value exceeds %d characters", key, maxTagValueLength)
+	}
+
+	if strings.HasPrefix(strings.ToLower(key), "aws:") {
+		return fmt.Errorf("Tag key '%s' uses the reserved 'aws:' prefix", key)
+	}
+
+	if !validTagCharacters.MatchString(key) || !validTagCharacters.MatchString(value) {
+		return fmt.Errorf("Tag '%s' contains invalid characters", key)
+	}
+
+	return nil
+}
+
+// sanitizeTagValue turns a value the broker does not control, such as an organization name,
+// into a valid tag value: characters AWS does not accept are replaced with '-', and the value
+// is truncated to the maximum length.
+func sanitizeTagValue(value string) string {
+	sanitized := []rune{}
+	for _, r := range value {
+		if !validTagCharacters.MatchString(string(r)) {
+			r = '-'
+		}
+		sanitized = append(sanitized, r)
+	}
+
+	if len(sanitized) > maxTagValueLength {
+		sanitized = sanitized[:maxTagValueLength]
+	}
+
+	return string(sanitized)
+}
+
+// desiredTags computes the full tag set a cache cluster should carry after an update,
+// merging the user tags with the freshly computed broker tags. When userTags is nil the
+// user tags currently on the cache cluster are kept.
+func (b *ElastiCacheBroker) desiredTags(currentTags, brokerTags, userTags map[string]string) map[string]string {
+	tags := make(map[string]string)
+
+	if userTags == nil {
+		for key, value := range currentTags {
+			if !b.isBrokerTagKey(key) {
+				tags[key] = value
+			}
+		}
+	} else {
+		for key, value := range userTags {
+			tags[key] = value
+		}
+	}
+
+	for key, value := range brokerTags {
+		tags[key] = value
+	}
+
+	for _, key := range preservedTagKeys {
+		if _, ok := tags[key]; ok {
+			continue
+		}
+		if value, ok := currentTags[key]; ok {
+			tags[key] = value
+		}
+	}
+
+	if tagsEqualExcept(currentTags, tags, timestampTagKeys) {
+		for _, key := range timestampTagKeys {
+			if value, ok := currentTags[key]; ok {
+				tags[key] = value
+			} else {
+				delete(tags, key)
+			}
+		}
+	}
+
+	return tags
+}
+
+func tagsEqualExcept(currentTags, desiredTags map[string]string, ignoredKeys []string) bool {
+	tagsToAdd, tagKeysToRemove := awselasticache.DiffTags(currentTags, desiredTags)
+
+	for _, key := range ignoredKeys {
+		delete(tagsToAdd, key)
+	}
+	for _, key := range tagKeysToRemove {
+		if !containsString(ignoredKeys, key) {
+			return false
+		}
+	}
+
+	return len(tagsToAdd) == 0
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,87 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+// maxTimeoutEvents is the number of recent cache cluster events reported for a timed out operation.
+const maxTimeoutEvents = 5
+
+// timeout returns the deadline of an operation type, or zero when it has none.
+func (ot *OperationTimeouts) timeout(operationType string) time.Duration {
+	if ot == nil {
+		return 0
+	}
+
+	switch operationType {
+	case store.OperationProvision:
+		return time.Duration(ot.Provision) * time.Second
+	case store.OperationUpdate:
+		return time.Duration(ot.Update) * time.Second
+	case store.OperationDeprovision:
+		return time.Duration(ot.Deprovision) * time.Second
+	}
+
+	return 0
+}
+
+// timedOutOperation checks the operation in progress on a service instance against the deadline
+// configured for its type in the service plan. An operation past its deadline is released, and
+// described with the last cache cluster status and its recent events. A provision past its
+// deadline is recorded as failed, so that its resources are cleaned up. Pending deletes are
+// never timed out, as releasing them would leave the cache cluster behind.
+func (b *ElastiCacheBroker) timedOutOperation(instanceID string, cacheClusterID string, cacheClusterDetails awselasticache.CacheClusterDetails) (string, bool, error) {
+	instance, err := b.store.GetInstance(instanceID)
+	if err != nil || instance.Operation == nil || isDeletePending(instance) {
+		return "", false, nil
+	}
+
+	servicePlan, ok := b.catalog.FindServicePlan(instance.PlanID)
+	if !ok {
+		return "", false, nil
+	}
+
+	timeout := servicePlan.OperationTimeouts.timeout(instance.Operation.Type)
+	if timeout == 0 || time.Since(instance.Operation.StartedAt) <= timeout {
+		return "", false, nil
+	}
+
+	description := fmt.Sprintf("Cache Cluster Instance '%s' %s did not complete within %s: status is '%s'", cacheClusterID, instance.Operation.Type, timeout, cacheClusterDetails.Status)
+
+	events, err := b.cacheCluster.DescribeEvents(cacheClusterID, instance.Operation.StartedAt)
+	if err != nil {
+		b.logger.Error("describe-events", err, lager.Data{instanceIDLogKey: instanceID, "cache-cluster-id": cacheClusterID})
+		events = nil
+	}
+	if len(events) > maxTimeoutEvents {
+		events = events[len(events)-maxTimeoutEvents:]
+	}
+	if len(events) > 0 {
+		messages := []string{}
+		for _, event := range events {
+			messages = append(messages, fmt.Sprintf("%s %s", event.Date.UTC().Format(time.RFC3339), event.Message))
+		}
+		description = fmt.Sprintf("%s; recent events: %s", description, strings.Join(messages, "; "))
+	}
+
+	if instance.Operation.Type == store.OperationProvision {
+		err = b.failProvision(instanceID, description)
+	} else {
+		err = b.finishOperation(instanceID)
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	b.logger.Error("operation-timed-out", errors.New(description), lager.Data{instanceIDLogKey: instanceID, "operation": instance.Operation.Type})
+
+	return description, true, nil
+}
@@ -0,0 +1,244 @@
+package broker_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/frodenas/brokerapi"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	cwfakes "github.com/cloudfoundry-community/elasticache-broker/awscloudwatch/fakes"
+	ec2fakes "github.com/cloudfoundry-community/elasticache-broker/awsec2/fakes"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache/fakes"
+	. "github.com/cloudfoundry-community/elasticache-broker/broker"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+var _ = Describe("Operation Timeouts", func() {
+	const instanceID = "ce71b484-d542-40f7-9dd4-5526e38c81ba"
+
+	var (
+		cacheCluster *fakes.FakeCacheCluster
+		brokerStore  store.Store
+
+		operationTimeouts *OperationTimeouts
+		startedAt         time.Time
+		operation         *store.Operation
+
+		elastiCacheBroker *ElastiCacheBroker
+	)
+
+	eventAt := func(minutes int, message string) awselasticache.CacheClusterEvent {
+		return awselasticache.CacheClusterEvent{
+			Date:    time.Date(2016, time.March, 1, 10, minutes, 0, 0, time.UTC),
+			Message: message,
+		}
+	}
+
+	BeforeEach(func() {
+		cacheCluster = &fakes.FakeCacheCluster{
+			DescribeCacheClusters: map[string]awselasticache.CacheClusterDetails{
+				"cf-aso4rtfujlvj": awselasticache.CacheClusterDetails{
+					CacheClusterId: "cf-aso4rtfujlvj",
+					Status:         "modifying",
+					Engine:         "redis",
+					Tags:           map[string]string{"Instance ID": instanceID, "Broker ID": "cf"},
+				},
+			},
+			DescribeEventsEvents: []awselasticache.CacheClusterEvent{
+				eventAt(1, "Cache cluster modification started"),
+				eventAt(2, "Cache node 0001 shutdown"),
+			},
+		}
+		operationTimeouts = &OperationTimeouts{Update: 600}
+		startedAt = time.Now().Add(-time.Hour)
+		operation = &store.Operation{Type: store.OperationUpdate}
+		brokerStore = store.NewMemoryStore()
+	})
+
+	JustBeforeEach(func() {
+		operation.StartedAt = startedAt
+		Expect(brokerStore.PutInstance(store.Instance{
+			ID:             instanceID,
+			ServiceID:      "Service-1",
+			PlanID:         "Plan-1",
+			CacheClusterID: "cf-aso4rtfujlvj",
+			Operation:      operation,
+		})).To(Succeed())
+
+		config := Config{
+			Region:      "elasticache-region",
+			CachePrefix: "cf",
+			Catalog: Catalog{
+				Services: []Service{
+					Service{
+						ID: "Service-1",
+						Plans: []ServicePlan{
+							ServicePlan{
+								ID: "Plan-1",
+								ElastiCacheProperties: ElastiCacheProperties{
+									CacheInstanceClass: "cache.t2.micro",
+									Engine:             "redis",
+								},
+								OperationTimeouts: operationTimeouts,
+							},
+						},
+					},
+				},
+			},
+		}
+		elastiCacheBroker = New(config, cacheCluster, &fakes.FakeCacheSubnetGroup{}, &ec2fakes.FakeSecurityGroup{}, &cwfakes.FakeCacheClusterMetrics{}, nil, brokerStore, lagertest.NewTestLogger("broker_test"))
+	})
+
+	It("reports an operation past its deadline as failed with the cache cluster status and events", func() {
+		lastOperationResponse, err := elastiCacheBroker.LastOperation(instanceID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lastOperationResponse.State).To(Equal(brokerapi.LastOperationFailed))
+		Expect(lastOperationResponse.Description).To(Equal("Cache Cluster Instance 'cf-aso4rtfujlvj' update did not complete within 10m0s: status is 'modifying'; recent events: 2016-03-01T10:01:00Z Cache cluster modification started; 2016-03-01T10:02:00Z Cache node 0001 shutdown"))
+		Expect(cacheCluster.DescribeEventsID).To(Equal("cf-aso4rtfujlvj"))
+		Expect(cacheCluster.DescribeEventsStartTime).To(Equal(startedAt))
+	})
+
+	It("releases the service instance", func() {
+		_, err := elastiCacheBroker.LastOperation(instanceID)
+		Expect(err).ToNot(HaveOccurred())
+
+		instance, err := brokerStore.GetInstance(instanceID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(instance.Operation).To(BeNil())
+	})
+
+	It("only reports the most recent events", func() {
+		cacheCluster.DescribeEventsEvents = []awselasticache.CacheClusterEvent{}
+		for minutes := 1; minutes <= 7; minutes++ {
+			cacheCluster.DescribeEventsEvents = append(cacheCluster.DescribeEventsEvents, eventAt(minutes, "Event"))
+		}
+
+		lastOperationResponse, err := elastiCacheBroker.LastOperation(instanceID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lastOperationResponse.Description).ToNot(ContainSubstring("10:02:00Z"))
+		Expect(lastOperationResponse.Description).To(ContainSubstring("10:03:00Z Event"))
+		Expect(lastOperationResponse.Description).To(ContainSubstring("10:07:00Z Event"))
+	})
+
+	It("reports the timeout even when the events cannot be described", func() {
+		cacheCluster.DescribeEventsError = errors.New("operation failed")
+
+		lastOperationResponse, err := elastiCacheBroker.LastOperation(instanceID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lastOperationResponse.State).To(Equal(brokerapi.LastOperationFailed))
+		Expect(lastOperationResponse.Description).To(Equal("Cache Cluster Instance 'cf-aso4rtfujlvj' update did not complete within 10m0s: status is 'modifying'"))
+	})
+
+	Context("when the operation is within its deadline", func() {
+		BeforeEach(func() {
+			startedAt = time.Now()
+		})
+
+		It("reports it in progress", func() {
+			lastOperationResponse, err := elastiCacheBroker.LastOperation(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lastOperationResponse.State).To(Equal(brokerapi.LastOperationInProgress))
+			Expect(cacheCluster.DescribeEventsCalled).To(BeFalse())
+		})
+	})
+
+	Context("when the operation type has no deadline", func() {
+		BeforeEach(func() {
+			operationTimeouts = &OperationTimeouts{Provision: 600}
+		})
+
+		It("reports it in progress", func() {
+			lastOperationResponse, err := elastiCacheBroker.LastOperation(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lastOperationResponse.State).To(Equal(brokerapi.LastOperationInProgress))
+		})
+	})
+
+	Context("when a provision is past its deadline", func() {
+		BeforeEach(func() {
+			operationTimeouts = &OperationTimeouts{Provision: 600}
+			operation = &store.Operation{Type: store.OperationProvision}
+			cacheCluster.DescribeCacheClusters["cf-aso4rtfujlvj"] = awselasticache.CacheClusterDetails{
+				CacheClusterId: "cf-aso4rtfujlvj",
+				Status:         "creating",
+				Engine:         "redis",
+				Tags:           map[string]string{"Instance ID": instanceID, "Broker ID": "cf"},
+			}
+			cacheCluster.DescribeEventsEvents = nil
+		})
+
+		It("records the provision failure", func() {
+			lastOperationResponse, err := elastiCacheBroker.LastOperation(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lastOperationResponse.State).To(Equal(brokerapi.LastOperationFailed))
+			Expect(lastOperationResponse.Description).To(Equal("Cache Cluster Instance 'cf-aso4rtfujlvj' provision did not complete within 10m0s: status is 'creating'"))
+
+			instance, err := brokerStore.GetInstance(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance.Operation).To(BeNil())
+			Expect(instance.Failure.Reason).To(Equal(lastOperationResponse.Description))
+			Expect(instance.Failure.CleanedUp).To(BeFalse())
+		})
+
+		It("deletes the cache cluster once it is created", func() {
+			_, err := elastiCacheBroker.LastOperation(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cacheCluster.DeleteCalled).To(BeFalse())
+
+			cacheCluster.DescribeCacheClusters["cf-aso4rtfujlvj"] = awselasticache.CacheClusterDetails{
+				CacheClusterId: "cf-aso4rtfujlvj",
+				Status:         "available",
+				Engine:         "redis",
+				Tags:           map[string]string{"Instance ID": instanceID, "Broker ID": "cf"},
+			}
+			Expect(elastiCacheBroker.CleanupFailedProvisions()).To(Succeed())
+			Expect(cacheCluster.DeleteCalled).To(BeTrue())
+			Expect(cacheCluster.DeleteID).To(Equal("cf-aso4rtfujlvj"))
+		})
+	})
+
+	Context("when a delete is pending past its deadline", func() {
+		BeforeEach(func() {
+			operationTimeouts = &OperationTimeouts{Deprovision: 600}
+			operation = &store.Operation{Type: store.OperationDeprovision, Pending: true}
+			cacheCluster.DescribeCacheClusters["cf-aso4rtfujlvj"] = awselasticache.CacheClusterDetails{
+				CacheClusterId: "cf-aso4rtfujlvj",
+				Status:         "creating",
+				Engine:         "redis",
+				Tags:           map[string]string{"Instance ID": instanceID, "Broker ID": "cf"},
+			}
+		})
+
+		It("keeps waiting to delete the cache cluster", func() {
+			lastOperationResponse, err := elastiCacheBroker.LastOperation(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lastOperationResponse.State).To(Equal(brokerapi.LastOperationInProgress))
+			Expect(lastOperationResponse.Description).To(ContainSubstring("waiting to delete it"))
+
+			instance, err := brokerStore.GetInstance(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance.Operation.Pending).To(BeTrue())
+		})
+	})
+
+	Context("when the operation completed", func() {
+		BeforeEach(func() {
+			cacheCluster.DescribeCacheClusters["cf-aso4rtfujlvj"] = awselasticache.CacheClusterDetails{
+				CacheClusterId: "cf-aso4rtfujlvj",
+				Status:         "available",
+				Engine:         "redis",
+			}
+		})
+
+		It("reports it succeeded past its deadline", func() {
+			lastOperationResponse, err := elastiCacheBroker.LastOperation(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lastOperationResponse.State).To(Equal(brokerapi.LastOperationSucceeded))
+		})
+	})
+})
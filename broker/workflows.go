@@ -0,0 +1,191 @@
+package broker
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/frodenas/brokerapi"
+	"github.com/pivotal-golang/lager"
+
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+	"github.com/cloudfoundry-community/elasticache-broker/workflow"
+)
+
+const provisionWorkflow = "provision"
+
+const (
+	cacheSubnetGroupDataKey      = "cache-subnet-group"
+	instanceSecurityGroupDataKey = "instance-security-group"
+)
+
+const (
+	workflowResumeInterval = 30 * time.Second
+	staleWorkflowPeriod    = 2 * time.Minute
+)
+
+// RunWorkflows runs the workflows as soon as they are started, and resumes the workflows
+// interrupted by a broker restart periodically, until stop is closed. Workflows only survive
+// a restart with a durable store, a warning is logged otherwise.
+func (b *ElastiCacheBroker) RunWorkflows(stop <-chan struct{}) {
+	if !b.store.Durable() {
+		b.logger.Info("run-workflows", lager.Data{"warning": "workflows interrupted by a broker restart are lost: configure a file or sql store to resume them"})
+	}
+
+	ticker := time.NewTicker(workflowResumeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-b.workflows.Started():
+		}
+
+		if err := b.ResumeWorkflows(); err != nil {
+			b.logger.Error("resume-workflows", err)
+		}
+	}
+}
+
+// ResumeWorkflows runs the steps of the workflows started by the provision requests, and the
+// remaining steps of the workflows interrupted by a broker restart. The latter are only found
+// in a durable store: with a memory store, the restart loses them along with the service
+// instances.
+func (b *ElastiCacheBroker) ResumeWorkflows() error {
+	return b.workflows.Resume(staleWorkflowPeriod)
+}
+
+// runningWorkflow returns the name of the workflow owning the service instance, if any. Until
+// it is over, the resources it creates are unknown to the other operations.
+func (b *ElastiCacheBroker) runningWorkflow(instanceID string) string {
+	instance, err := b.store.GetInstance(instanceID)
+	if err != nil || instance.Operation == nil || instance.Operation.Workflow == nil {
+		return ""
+	}
+
+	switch instance.Operation.Workflow.State {
+	case store.WorkflowPending, store.WorkflowRunning:
+		return instance.Operation.Workflow.Name
+	}
+
+	return ""
+}
+
+// provisionSteps builds the provision workflow of a service instance from its stored
+// attributes, so that it can be resumed without the original request.
+func (b *ElastiCacheBroker) provisionSteps(instance store.Instance) ([]workflow.Step, error) {
+	servicePlan, ok := b.catalog.FindServicePlan(instance.PlanID)
+	if !ok {
+		return nil, fmt.Errorf("Service Plan '%s' not found", instance.PlanID)
+	}
+
+	provisionParameters := ProvisionParameters{}
+	if len(instance.Parameters) > 0 {
+		if err := decodeParameters(instance.Parameters, provisionParameterDefinitions, servicePlan, &provisionParameters); err != nil {
+			return nil, err
+		}
+	}
+
+	details := brokerapi.ProvisionDetails{
+		ServiceID:        instance.ServiceID,
+		PlanID:           instance.PlanID,
+		OrganizationGUID: instance.OrganizationID,
+		SpaceGUID:        instance.SpaceID,
+		Parameters:       instance.Parameters,
+	}
+
+	cacheClusterDetails := func(data map[string]string) *awselasticache.CacheClusterDetails {
+		cacheClusterDetails := b.createCacheCluster(instance.ID, servicePlan, provisionParameters, details)
+		cacheClusterDetails.CacheSubnetGroupName = data[cacheSubnetGroupDataKey]
+		if securityGroupID := data[instanceSecurityGroupDataKey]; securityGroupID != "" {
+			cacheClusterDetails.CacheSecurityGroups = append(cacheClusterDetails.CacheSecurityGroups, securityGroupID)
+		}
+		return cacheClusterDetails
+	}
+
+	steps := []workflow.Step{}
+
+	if len(servicePlan.ElastiCacheProperties.SubnetIDs) > 0 {
+		steps = append(steps, workflow.Step{
+			Name: "create-cache-subnet-group",
+			Run: func(data map[string]string) error {
+				cacheSubnetGroupName, err := b.ensureCacheSubnetGroup(servicePlan.ElastiCacheProperties.SubnetIDs)
+				if err != nil {
+					return err
+				}
+				data[cacheSubnetGroupDataKey] = cacheSubnetGroupName
+				return nil
+			},
+		})
+	}
+
+	if servicePlan.ElastiCacheProperties.InstanceSecurityGroup {
+		steps = append(steps, workflow.Step{
+			Name: "create-instance-security-group",
+			Run: func(data map[string]string) error {
+				securityGroupID, err := b.createInstanceSecurityGroup(instance.ID, servicePlan, cacheClusterDetails(data))
+				if err != nil {
+					return err
+				}
+				data[instanceSecurityGroupDataKey] = securityGroupID
+				return nil
+			},
+			Compensate: func(data map[string]string) error {
+				return b.deleteInstanceSecurityGroup(instance.ID)
+			},
+		})
+	}
+
+	steps = append(steps, workflow.Step{
+		Name: "create-cache-cluster",
+		Run: func(data map[string]string) error {
+			cacheClusterID := b.cacheClusterIdentifier(instance.ID)
+			if _, err := b.cacheCluster.Describe(cacheClusterID); err == nil {
+				return nil
+			}
+			return b.cacheCluster.Create(cacheClusterID, *cacheClusterDetails(data))
+		},
+	})
+
+	return steps, nil
+}
+
+// workflowProgress describes the workflow of the operation in progress on the service
+// instance, if any and not yet succeeded, as a last operation.
+func (b *ElastiCacheBroker) workflowProgress(instanceID string) (brokerapi.LastOperationResponse, bool) {
+	instance, err := b.store.GetInstance(instanceID)
+	if err != nil || instance.Operation == nil || instance.Operation.Workflow == nil {
+		return brokerapi.LastOperationResponse{}, false
+	}
+
+	progress := instance.Operation.Workflow
+	switch progress.State {
+	case store.WorkflowPending:
+		return brokerapi.LastOperationResponse{
+			State:       brokerapi.LastOperationInProgress,
+			Description: fmt.Sprintf("Starting %s", progress.Name),
+		}, true
+	case store.WorkflowRunning:
+		for i, step := range progress.Steps {
+			if step.State != store.StepDone {
+				return brokerapi.LastOperationResponse{
+					State:       brokerapi.LastOperationInProgress,
+					Description: fmt.Sprintf("Running %s step %d of %d: %s", progress.Name, i+1, len(progress.Steps), step.Name),
+				}, true
+			}
+		}
+		return brokerapi.LastOperationResponse{
+			State:       brokerapi.LastOperationInProgress,
+			Description: fmt.Sprintf("Finishing %s", progress.Name),
+		}, true
+	case store.WorkflowFailed:
+		return brokerapi.LastOperationResponse{
+			State:       brokerapi.LastOperationFailed,
+			Description: progress.Error,
+		}, true
+	}
+
+	return brokerapi.LastOperationResponse{}, false
+}
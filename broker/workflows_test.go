@@ -0,0 +1,308 @@
+package broker_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/frodenas/brokerapi"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+	cwfakes "github.com/cloudfoundry-community/elasticache-broker/awscloudwatch/fakes"
+	"github.com/cloudfoundry-community/elasticache-broker/awsec2"
+	ec2fakes "github.com/cloudfoundry-community/elasticache-broker/awsec2/fakes"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache/fakes"
+	. "github.com/cloudfoundry-community/elasticache-broker/broker"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+// unsavedWorkflowStore simulates a store failing to record the workflows being started.
+type unsavedWorkflowStore struct {
+	*store.MemoryStore
+}
+
+func (s *unsavedWorkflowStore) PutInstance(instance store.Instance) error {
+	if instance.Operation != nil && instance.Operation.Workflow != nil {
+		return errors.New("store unavailable")
+	}
+	return s.MemoryStore.PutInstance(instance)
+}
+
+var _ = Describe("Workflows", func() {
+	const instanceID = "ce71b484-d542-40f7-9dd4-5526e38c81ba"
+
+	var (
+		cacheCluster     *fakes.FakeCacheCluster
+		cacheSubnetGroup *fakes.FakeCacheSubnetGroup
+		securityGroup    *ec2fakes.FakeSecurityGroup
+		brokerStore      store.Store
+
+		config           Config
+		provisionDetails brokerapi.ProvisionDetails
+
+		elastiCacheBroker *ElastiCacheBroker
+	)
+
+	provisionWorkflow := func() *store.Workflow {
+		instance, err := brokerStore.GetInstance(instanceID)
+		Expect(err).ToNot(HaveOccurred())
+		return instance.Operation.Workflow
+	}
+
+	recordWorkflow := func(state string, steps []store.WorkflowStep) {
+		Expect(brokerStore.PutInstance(store.Instance{
+			ID:             instanceID,
+			ServiceID:      "Service-1",
+			PlanID:         "Plan-1",
+			OrganizationID: "organization-id",
+			SpaceID:        "space-id",
+			CacheClusterID: "cf-aso4rtfujlvj",
+			Operation: &store.Operation{
+				Type: store.OperationProvision,
+				Workflow: &store.Workflow{
+					Name:      "provision",
+					State:     state,
+					Steps:     steps,
+					Data:      map[string]string{"cache-subnet-group": "cf-subnet-group", "instance-security-group": "sg-1"},
+					Error:     "Step 'create-cache-cluster' failed: operation failed",
+					UpdatedAt: time.Now().Add(-time.Hour),
+				},
+			},
+		})).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		cacheCluster = &fakes.FakeCacheCluster{
+			DescribeError: awselasticache.ErrCacheClusterDoesNotExist,
+		}
+		cacheSubnetGroup = &fakes.FakeCacheSubnetGroup{
+			DescribeCacheSubnetGroupDetails: awselasticache.CacheSubnetGroupDetails{
+				SubnetIDs: []string{"subnet-1"},
+				VpcID:     "vpc-1",
+			},
+		}
+		securityGroup = &ec2fakes.FakeSecurityGroup{
+			FindByNameSecurityGroupDetails: awsec2.SecurityGroupDetails{ID: "sg-1"},
+		}
+		brokerStore = store.NewMemoryStore()
+		provisionDetails = brokerapi.ProvisionDetails{
+			ServiceID:        "Service-1",
+			PlanID:           "Plan-1",
+			OrganizationGUID: "organization-id",
+			SpaceGUID:        "space-id",
+		}
+	})
+
+	JustBeforeEach(func() {
+		config = Config{
+			Region:      "elasticache-region",
+			CachePrefix: "cf",
+			Catalog: Catalog{
+				Services: []Service{
+					Service{
+						ID: "Service-1",
+						Plans: []ServicePlan{
+							ServicePlan{
+								ID: "Plan-1",
+								ElastiCacheProperties: ElastiCacheProperties{
+									CacheInstanceClass:         "cache.t2.micro",
+									Engine:                     "redis",
+									SubnetIDs:                  []string{"subnet-1"},
+									InstanceSecurityGroup:      true,
+									InstanceSecurityGroupCIDRs: []string{"10.0.0.0/16"},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		elastiCacheBroker = New(config, cacheCluster, cacheSubnetGroup, securityGroup, &cwfakes.FakeCacheClusterMetrics{}, nil, brokerStore, lagertest.NewTestLogger("broker_test"))
+	})
+
+	It("records the provision workflow without running its steps", func() {
+		_, asynch, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(asynch).To(BeTrue())
+		Expect(cacheSubnetGroup.CreateCalled).To(BeFalse())
+		Expect(cacheCluster.CreateCalled).To(BeFalse())
+		Expect(provisionWorkflow().State).To(Equal(store.WorkflowPending))
+
+		lastOperationResponse, err := elastiCacheBroker.LastOperation(instanceID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lastOperationResponse.State).To(Equal(brokerapi.LastOperationInProgress))
+		Expect(lastOperationResponse.Description).To(Equal("Starting provision"))
+	})
+
+	It("records the progress of each provision step", func() {
+		_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(elastiCacheBroker.ResumeWorkflows()).To(Succeed())
+		Expect(cacheCluster.CreateCacheClusterDetails.CacheSecurityGroups).To(ContainElement("sg-1"))
+
+		workflow := provisionWorkflow()
+		Expect(workflow.State).To(Equal(store.WorkflowSucceeded))
+		Expect(workflow.Steps).To(Equal([]store.WorkflowStep{
+			store.WorkflowStep{Name: "create-cache-subnet-group", State: store.StepDone},
+			store.WorkflowStep{Name: "create-instance-security-group", State: store.StepDone},
+			store.WorkflowStep{Name: "create-cache-cluster", State: store.StepDone},
+		}))
+	})
+
+	It("rolls back the instance security group when the cache cluster cannot be created", func() {
+		cacheCluster.CreateError = errors.New("operation failed")
+
+		_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(elastiCacheBroker.ResumeWorkflows()).To(Succeed())
+		Expect(securityGroup.DeleteCalled).To(BeTrue())
+		Expect(securityGroup.DeleteID).To(Equal("sg-1"))
+
+		lastOperationResponse, err := elastiCacheBroker.LastOperation(instanceID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lastOperationResponse.State).To(Equal(brokerapi.LastOperationFailed))
+		Expect(lastOperationResponse.Description).To(Equal("Step 'create-cache-cluster' failed: operation failed"))
+	})
+
+	It("runs started workflows without waiting for the next periodic resume", func() {
+		logger := lagertest.NewTestLogger("broker_test")
+		elastiCacheBroker = New(config, cacheCluster, cacheSubnetGroup, securityGroup, &cwfakes.FakeCacheClusterMetrics{}, nil, brokerStore, logger)
+
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			elastiCacheBroker.RunWorkflows(stop)
+			close(done)
+		}()
+
+		_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(func() string { return provisionWorkflow().State }).Should(Equal(store.WorkflowSucceeded))
+
+		close(stop)
+		Eventually(done).Should(BeClosed())
+		Expect(logger.LogMessages()).To(ContainElement("broker_test.broker.run-workflows"))
+	})
+
+	It("rejects deprovisions while the provision workflow owns the service instance", func() {
+		_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = elastiCacheBroker.Deprovision(instanceID, brokerapi.DeprovisionDetails{ServiceID: "Service-1", PlanID: "Plan-1"}, true)
+		Expect(err).To(MatchError("Cannot deprovision service instance 'ce71b484-d542-40f7-9dd4-5526e38c81ba': provision workflow in progress"))
+		Expect(err.(*api.FailureResponse).StatusCode()).To(Equal(422))
+		Expect(securityGroup.DeleteCalled).To(BeFalse())
+		Expect(provisionWorkflow().State).To(Equal(store.WorkflowPending))
+	})
+
+	Context("when the provision workflow cannot be started", func() {
+		BeforeEach(func() {
+			brokerStore = &unsavedWorkflowStore{MemoryStore: store.NewMemoryStore()}
+		})
+
+		It("records the provision failure", func() {
+			_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+			Expect(err).To(MatchError("store unavailable"))
+
+			instance, err := brokerStore.GetInstance(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance.Operation).To(BeNil())
+			Expect(instance.Failure.Reason).To(Equal("Provision workflow could not be started: store unavailable"))
+			Expect(instance.Failure.CleanedUp).To(BeTrue())
+		})
+	})
+
+	Context("with a file store", func() {
+		var storeDir string
+
+		BeforeEach(func() {
+			var err error
+			storeDir, err = ioutil.TempDir("", "broker")
+			Expect(err).ToNot(HaveOccurred())
+			brokerStore, err = store.NewFileStore(filepath.Join(storeDir, "state.json"), lagertest.NewTestLogger("broker_test"))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(storeDir)
+		})
+
+		It("runs the provision workflow recorded before a broker restart", func() {
+			_, _, err := elastiCacheBroker.Provision(instanceID, provisionDetails, true)
+			Expect(err).ToNot(HaveOccurred())
+
+			brokerStore, err = store.NewFileStore(filepath.Join(storeDir, "state.json"), lagertest.NewTestLogger("broker_test"))
+			Expect(err).ToNot(HaveOccurred())
+			elastiCacheBroker = New(config, cacheCluster, cacheSubnetGroup, securityGroup, &cwfakes.FakeCacheClusterMetrics{}, nil, brokerStore, lagertest.NewTestLogger("broker_test"))
+
+			Expect(elastiCacheBroker.ResumeWorkflows()).To(Succeed())
+			Expect(cacheCluster.CreateCalled).To(BeTrue())
+			Expect(cacheCluster.CreateID).To(Equal("cf-aso4rtfujlvj"))
+			Expect(provisionWorkflow().State).To(Equal(store.WorkflowSucceeded))
+		})
+	})
+
+	Context("when a provision was interrupted", func() {
+		BeforeEach(func() {
+			recordWorkflow(store.WorkflowRunning, []store.WorkflowStep{
+				store.WorkflowStep{Name: "create-cache-subnet-group", State: store.StepDone},
+				store.WorkflowStep{Name: "create-instance-security-group", State: store.StepDone},
+				store.WorkflowStep{Name: "create-cache-cluster", State: store.StepPending},
+			})
+		})
+
+		It("reports the step in progress", func() {
+			lastOperationResponse, err := elastiCacheBroker.LastOperation(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lastOperationResponse.State).To(Equal(brokerapi.LastOperationInProgress))
+			Expect(lastOperationResponse.Description).To(Equal("Running provision step 3 of 3: create-cache-cluster"))
+			Expect(cacheCluster.DescribeCalled).To(BeFalse())
+		})
+
+		It("resumes the remaining steps", func() {
+			Expect(elastiCacheBroker.ResumeWorkflows()).To(Succeed())
+			Expect(cacheSubnetGroup.CreateCalled).To(BeFalse())
+			Expect(securityGroup.FindByNameCalled).To(BeFalse())
+			Expect(cacheCluster.CreateCalled).To(BeTrue())
+			Expect(cacheCluster.CreateID).To(Equal("cf-aso4rtfujlvj"))
+			Expect(cacheCluster.CreateCacheClusterDetails.CacheSubnetGroupName).To(Equal("cf-subnet-group"))
+			Expect(provisionWorkflow().State).To(Equal(store.WorkflowSucceeded))
+		})
+
+		It("does not create the cache cluster again if it was created before the interruption", func() {
+			cacheCluster.DescribeError = nil
+
+			Expect(elastiCacheBroker.ResumeWorkflows()).To(Succeed())
+			Expect(cacheCluster.CreateCalled).To(BeFalse())
+			Expect(provisionWorkflow().State).To(Equal(store.WorkflowSucceeded))
+		})
+	})
+
+	Context("when a resumed provision failed", func() {
+		BeforeEach(func() {
+			recordWorkflow(store.WorkflowFailed, []store.WorkflowStep{
+				store.WorkflowStep{Name: "create-cache-subnet-group", State: store.StepDone},
+				store.WorkflowStep{Name: "create-instance-security-group", State: store.StepCompensated},
+				store.WorkflowStep{Name: "create-cache-cluster", State: store.StepPending},
+			})
+		})
+
+		It("reports the failure and releases the service instance", func() {
+			lastOperationResponse, err := elastiCacheBroker.LastOperation(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lastOperationResponse.State).To(Equal(brokerapi.LastOperationFailed))
+			Expect(lastOperationResponse.Description).To(Equal("Step 'create-cache-cluster' failed: operation failed"))
+
+			instance, err := brokerStore.GetInstance(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance.Operation).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,329 @@
+package cloudcontroller
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+)
+
+const requestTimeout = 30 * time.Second
+
+type CCClient struct {
+	apiURL      string
+	httpClient  *http.Client
+	tokenSource *uaaTokenSource
+	logger      lager.Logger
+}
+
+func NewCCClient(
+	config Config,
+	logger lager.Logger,
+) *CCClient {
+	httpClient := &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: config.SkipSSLValidation},
+		},
+	}
+
+	return &CCClient{
+		apiURL:     strings.TrimRight(config.APIURL, "/"),
+		httpClient: httpClient,
+		tokenSource: &uaaTokenSource{
+			uaaURL:       config.UAAURL,
+			clientID:     config.ClientID,
+			clientSecret: config.ClientSecret,
+			httpClient:   httpClient,
+		},
+		logger: logger.Session("cloud-controller"),
+	}
+}
+
+type resourceMetadata struct {
+	GUID string `json:"guid"`
+}
+
+type resource struct {
+	Metadata resourceMetadata `json:"metadata"`
+	Entity   json.RawMessage  `json:"entity"`
+}
+
+type paginatedResources struct {
+	NextURL   string     `json:"next_url"`
+	Resources []resource `json:"resources"`
+}
+
+type organizationEntity struct {
+	Name string `json:"name"`
+}
+
+type spaceEntity struct {
+	Name             string `json:"name"`
+	OrganizationGUID string `json:"organization_guid"`
+}
+
+type appEntity struct {
+	Name      string `json:"name"`
+	SpaceGUID string `json:"space_guid"`
+}
+
+type serviceBindingEntity struct {
+	AppGUID             string `json:"app_guid"`
+	ServiceInstanceGUID string `json:"service_instance_guid"`
+}
+
+type securityGroupEntity struct {
+	Name       string              `json:"name"`
+	Rules      []SecurityGroupRule `json:"rules"`
+	SpaceGUIDs []string            `json:"space_guids,omitempty"`
+}
+
+type errorResponse struct {
+	Code        int    `json:"code"`
+	Description string `json:"description"`
+	ErrorCode   string `json:"error_code"`
+}
+
+func (c *CCClient) GetOrganization(GUID string) (Organization, error) {
+	organization := Organization{}
+
+	organizationResource := resource{}
+	if err := c.do("GET", "/v2/organizations/"+url.QueryEscape(GUID), nil, &organizationResource); err != nil {
+		return organization, err
+	}
+
+	entity := organizationEntity{}
+	if err := json.Unmarshal(organizationResource.Entity, &entity); err != nil {
+		return organization, err
+	}
+
+	organization.GUID = organizationResource.Metadata.GUID
+	organization.Name = entity.Name
+
+	return organization, nil
+}
+
+func (c *CCClient) GetSpace(GUID string) (Space, error) {
+	space := Space{}
+
+	spaceResource := resource{}
+	if err := c.do("GET", "/v2/spaces/"+url.QueryEscape(GUID), nil, &spaceResource); err != nil {
+		return space, err
+	}
+
+	entity := spaceEntity{}
+	if err := json.Unmarshal(spaceResource.Entity, &entity); err != nil {
+		return space, err
+	}
+
+	space.GUID = spaceResource.Metadata.GUID
+	space.Name = entity.Name
+	space.OrganizationGUID = entity.OrganizationGUID
+
+	return space, nil
+}
+
+func (c *CCClient) GetApp(GUID string) (App, error) {
+	app := App{}
+
+	appResource := resource{}
+	if err := c.do("GET", "/v2/apps/"+url.QueryEscape(GUID), nil, &appResource); err != nil {
+		return app, err
+	}
+
+	entity := appEntity{}
+	if err := json.Unmarshal(appResource.Entity, &entity); err != nil {
+		return app, err
+	}
+
+	app.GUID = appResource.Metadata.GUID
+	app.Name = entity.Name
+	app.SpaceGUID = entity.SpaceGUID
+
+	return app, nil
+}
+
+func (c *CCClient) ListServiceBindings(serviceInstanceGUID string) ([]ServiceBinding, error) {
+	var serviceBindings []ServiceBinding
+
+	path := "/v2/service_instances/" + url.QueryEscape(serviceInstanceGUID) + "/service_bindings"
+	err := c.list(path, func(bindingResource resource) error {
+		entity := serviceBindingEntity{}
+		if err := json.Unmarshal(bindingResource.Entity, &entity); err != nil {
+			return err
+		}
+
+		serviceBindings = append(serviceBindings, ServiceBinding{
+			GUID:                bindingResource.Metadata.GUID,
+			AppGUID:             entity.AppGUID,
+			ServiceInstanceGUID: entity.ServiceInstanceGUID,
+		})
+		return nil
+	})
+
+	return serviceBindings, err
+}
+
+func (c *CCClient) FindSecurityGroup(name string) (SecurityGroup, error) {
+	var securityGroups []SecurityGroup
+
+	path := "/v2/security_groups?q=" + url.QueryEscape("name:"+name)
+	err := c.list(path, func(securityGroupResource resource) error {
+		entity := securityGroupEntity{}
+		if err := json.Unmarshal(securityGroupResource.Entity, &entity); err != nil {
+			return err
+		}
+
+		securityGroups = append(securityGroups, SecurityGroup{
+			GUID:  securityGroupResource.Metadata.GUID,
+			Name:  entity.Name,
+			Rules: entity.Rules,
+		})
+		return nil
+	})
+	if err != nil {
+		return SecurityGroup{}, err
+	}
+
+	if len(securityGroups) == 0 {
+		return SecurityGroup{}, ErrResourceNotFound
+	}
+
+	return securityGroups[0], nil
+}
+
+func (c *CCClient) CreateSecurityGroup(securityGroup SecurityGroup) (string, error) {
+	entity := securityGroupEntity{
+		Name:       securityGroup.Name,
+		Rules:      securityGroup.Rules,
+		SpaceGUIDs: securityGroup.SpaceGUIDs,
+	}
+
+	securityGroupResource := resource{}
+	if err := c.do("POST", "/v2/security_groups", entity, &securityGroupResource); err != nil {
+		return "", err
+	}
+
+	return securityGroupResource.Metadata.GUID, nil
+}
+
+func (c *CCClient) UpdateSecurityGroup(securityGroup SecurityGroup) error {
+	entity := securityGroupEntity{
+		Name:  securityGroup.Name,
+		Rules: securityGroup.Rules,
+	}
+
+	return c.do("PUT", "/v2/security_groups/"+url.QueryEscape(securityGroup.GUID), entity, nil)
+}
+
+func (c *CCClient) DeleteSecurityGroup(GUID string) error {
+	return c.do("DELETE", "/v2/security_groups/"+url.QueryEscape(GUID), nil, nil)
+}
+
+func (c *CCClient) BindSecurityGroupToSpace(securityGroupGUID string, spaceGUID string) error {
+	return c.do("PUT", "/v2/security_groups/"+url.QueryEscape(securityGroupGUID)+"/spaces/"+url.QueryEscape(spaceGUID), nil, nil)
+}
+
+func (c *CCClient) UnbindSecurityGroupFromSpace(securityGroupGUID string, spaceGUID string) error {
+	return c.do("DELETE", "/v2/security_groups/"+url.QueryEscape(securityGroupGUID)+"/spaces/"+url.QueryEscape(spaceGUID), nil, nil)
+}
+
+func (c *CCClient) list(path string, handler func(resource) error) error {
+	for path != "" {
+		page := paginatedResources{}
+		if err := c.do("GET", path, nil, &page); err != nil {
+			return err
+		}
+
+		for _, resource := range page.Resources {
+			if err := handler(resource); err != nil {
+				return err
+			}
+		}
+
+		path = page.NextURL
+	}
+
+	return nil
+}
+
+func (c *CCClient) do(method string, path string, body interface{}, result interface{}) error {
+	var requestBody []byte
+	if body != nil {
+		var err error
+		if requestBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.send(method, path, requestBody)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		c.tokenSource.Invalidate()
+		if resp, err = c.send(method, path, requestBody); err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrResourceNotFound
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return c.buildError(resp)
+	}
+
+	if result == nil {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func (c *CCClient) send(method string, path string, requestBody []byte) (*http.Response, error) {
+	accessToken, err := c.tokenSource.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, c.apiURL+path, bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	if requestBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	c.logger.Debug("request", lager.Data{"method": method, "path": path})
+
+	return c.httpClient.Do(req)
+}
+
+func (c *CCClient) buildError(resp *http.Response) error {
+	ccError := errorResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&ccError); err != nil || ccError.Description == "" {
+		return fmt.Errorf("Cloud Controller request failed with status code %d", resp.StatusCode)
+	}
+
+	c.logger.Error("cloud-controller-error", fmt.Errorf("%s: %s", ccError.ErrorCode, ccError.Description))
+
+	return fmt.Errorf("%s: %s", ccError.ErrorCode, ccError.Description)
+}
@@ -0,0 +1,234 @@
+package cloudcontroller_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/cloudfoundry-community/elasticache-broker/cloudcontroller"
+)
+
+type recordedRequest struct {
+	Method        string
+	Path          string
+	Query         string
+	Authorization string
+	Body          string
+}
+
+var _ = Describe("CCClient", func() {
+	var (
+		uaaServer   *httptest.Server
+		ccServer    *httptest.Server
+		tokenCount  int
+		requests    []recordedRequest
+		ccResponses map[string]string
+		ccStatus    map[string]int
+
+		client *CCClient
+	)
+
+	BeforeEach(func() {
+		tokenCount = 0
+		requests = []recordedRequest{}
+		ccResponses = map[string]string{}
+		ccStatus = map[string]int{}
+
+		uaaServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			username, password, _ := req.BasicAuth()
+			Expect(req.URL.Path).To(Equal("/oauth/token"))
+			Expect(username).To(Equal("client-id"))
+			Expect(password).To(Equal("client-secret"))
+			Expect(req.FormValue("grant_type")).To(Equal("client_credentials"))
+
+			tokenCount++
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token": "token",
+				"token_type":   "bearer",
+				"expires_in":   3600,
+			})
+		}))
+
+		ccServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body, _ := ioutil.ReadAll(req.Body)
+			requests = append(requests, recordedRequest{
+				Method:        req.Method,
+				Path:          req.URL.Path,
+				Query:         req.URL.RawQuery,
+				Authorization: req.Header.Get("Authorization"),
+				Body:          string(body),
+			})
+
+			key := req.Method + " " + req.URL.Path
+			if status, ok := ccStatus[key]; ok {
+				w.WriteHeader(status)
+			}
+			w.Write([]byte(ccResponses[key]))
+		}))
+
+		client = NewCCClient(Config{
+			APIURL:       ccServer.URL,
+			UAAURL:       uaaServer.URL,
+			ClientID:     "client-id",
+			ClientSecret: "client-secret",
+		}, lagertest.NewTestLogger("cc_client_test"))
+	})
+
+	AfterEach(func() {
+		uaaServer.Close()
+		ccServer.Close()
+	})
+
+	Describe("GetOrganization", func() {
+		It("returns the organization", func() {
+			ccResponses["GET /v2/organizations/org-guid"] = `{"metadata":{"guid":"org-guid"},"entity":{"name":"my-org"}}`
+
+			organization, err := client.GetOrganization("org-guid")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(organization).To(Equal(Organization{GUID: "org-guid", Name: "my-org"}))
+		})
+	})
+
+	Describe("GetSpace", func() {
+		It("returns the space", func() {
+			ccResponses["GET /v2/spaces/space-guid"] = `{"metadata":{"guid":"space-guid"},"entity":{"name":"my-space","organization_guid":"org-guid"}}`
+
+			space, err := client.GetSpace("space-guid")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(space).To(Equal(Space{GUID: "space-guid", Name: "my-space", OrganizationGUID: "org-guid"}))
+		})
+	})
+
+	Describe("GetApp", func() {
+		It("returns the app", func() {
+			ccResponses["GET /v2/apps/app-guid"] = `{"metadata":{"guid":"app-guid"},"entity":{"name":"my-app","space_guid":"space-guid"}}`
+
+			app, err := client.GetApp("app-guid")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(app).To(Equal(App{GUID: "app-guid", Name: "my-app", SpaceGUID: "space-guid"}))
+			Expect(requests[0].Authorization).To(Equal("bearer token"))
+		})
+
+		It("returns the proper error if the app does not exist", func() {
+			ccStatus["GET /v2/apps/app-guid"] = http.StatusNotFound
+
+			_, err := client.GetApp("app-guid")
+			Expect(err).To(Equal(ErrResourceNotFound))
+		})
+
+		It("reuses the UAA token across requests", func() {
+			ccResponses["GET /v2/apps/app-guid"] = `{"metadata":{"guid":"app-guid"},"entity":{}}`
+
+			_, err := client.GetApp("app-guid")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = client.GetApp("app-guid")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tokenCount).To(Equal(1))
+		})
+
+		It("refreshes the UAA token when the Cloud Controller rejects it", func() {
+			ccStatus["GET /v2/apps/app-guid"] = http.StatusUnauthorized
+
+			_, err := client.GetApp("app-guid")
+			Expect(err).To(HaveOccurred())
+			Expect(tokenCount).To(Equal(2))
+			Expect(requests).To(HaveLen(2))
+		})
+	})
+
+	Describe("ListServiceBindings", func() {
+		It("follows the pagination", func() {
+			ccResponses["GET /v2/service_instances/instance-guid/service_bindings"] = `{"next_url":"/v2/service_bindings_page_2","resources":[{"metadata":{"guid":"binding-1"},"entity":{"app_guid":"app-1","service_instance_guid":"instance-guid"}}]}`
+			ccResponses["GET /v2/service_bindings_page_2"] = `{"next_url":null,"resources":[{"metadata":{"guid":"binding-2"},"entity":{"app_guid":"app-2","service_instance_guid":"instance-guid"}}]}`
+
+			serviceBindings, err := client.ListServiceBindings("instance-guid")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(serviceBindings).To(Equal([]ServiceBinding{
+				ServiceBinding{GUID: "binding-1", AppGUID: "app-1", ServiceInstanceGUID: "instance-guid"},
+				ServiceBinding{GUID: "binding-2", AppGUID: "app-2", ServiceInstanceGUID: "instance-guid"},
+			}))
+		})
+	})
+
+	Describe("FindSecurityGroup", func() {
+		It("returns the security group", func() {
+			ccResponses["GET /v2/security_groups"] = `{"resources":[{"metadata":{"guid":"sg-guid"},"entity":{"name":"cf-instance","rules":[{"protocol":"tcp","destination":"10.0.0.1","ports":"6379"}]}}]}`
+
+			securityGroup, err := client.FindSecurityGroup("cf-instance")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(securityGroup).To(Equal(SecurityGroup{
+				GUID:  "sg-guid",
+				Name:  "cf-instance",
+				Rules: []SecurityGroupRule{SecurityGroupRule{Protocol: "tcp", Destination: "10.0.0.1", Ports: "6379"}},
+			}))
+			Expect(requests[0].Query).To(Equal("q=name%3Acf-instance"))
+		})
+
+		It("returns the proper error if the security group does not exist", func() {
+			ccResponses["GET /v2/security_groups"] = `{"resources":[]}`
+
+			_, err := client.FindSecurityGroup("cf-instance")
+			Expect(err).To(Equal(ErrResourceNotFound))
+		})
+	})
+
+	Describe("CreateSecurityGroup", func() {
+		It("creates the security group", func() {
+			ccStatus["POST /v2/security_groups"] = http.StatusCreated
+			ccResponses["POST /v2/security_groups"] = `{"metadata":{"guid":"sg-guid"},"entity":{"name":"cf-instance"}}`
+
+			GUID, err := client.CreateSecurityGroup(SecurityGroup{
+				Name:       "cf-instance",
+				Rules:      []SecurityGroupRule{SecurityGroupRule{Protocol: "tcp", Destination: "10.0.0.1", Ports: "6379"}},
+				SpaceGUIDs: []string{"space-guid"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(GUID).To(Equal("sg-guid"))
+			Expect(requests[0].Body).To(MatchJSON(`{"name":"cf-instance","rules":[{"protocol":"tcp","destination":"10.0.0.1","ports":"6379"}],"space_guids":["space-guid"]}`))
+		})
+
+		It("returns the Cloud Controller error description", func() {
+			ccStatus["POST /v2/security_groups"] = http.StatusBadRequest
+			ccResponses["POST /v2/security_groups"] = `{"code":300001,"description":"The security group is invalid: rules is invalid","error_code":"CF-SecurityGroupInvalid"}`
+
+			_, err := client.CreateSecurityGroup(SecurityGroup{Name: "cf-instance"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("CF-SecurityGroupInvalid: The security group is invalid: rules is invalid"))
+		})
+	})
+
+	Describe("BindSecurityGroupToSpace", func() {
+		It("binds the security group to the space", func() {
+			ccStatus["PUT /v2/security_groups/sg-guid/spaces/space-guid"] = http.StatusCreated
+
+			err := client.BindSecurityGroupToSpace("sg-guid", "space-guid")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requests[0].Method).To(Equal("PUT"))
+		})
+	})
+
+	Describe("UnbindSecurityGroupFromSpace", func() {
+		It("unbinds the security group from the space", func() {
+			ccStatus["DELETE /v2/security_groups/sg-guid/spaces/space-guid"] = http.StatusNoContent
+
+			err := client.UnbindSecurityGroupFromSpace("sg-guid", "space-guid")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requests[0].Method).To(Equal("DELETE"))
+		})
+	})
+
+	Describe("DeleteSecurityGroup", func() {
+		It("deletes the security group", func() {
+			ccStatus["DELETE /v2/security_groups/sg-guid"] = http.StatusNoContent
+
+			err := client.DeleteSecurityGroup("sg-guid")
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+})
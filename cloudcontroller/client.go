@@ -0,0 +1,59 @@
+package cloudcontroller
+
+import (
+	"errors"
+)
+
+type Client interface {
+	GetOrganization(GUID string) (Organization, error)
+	GetSpace(GUID string) (Space, error)
+	GetApp(GUID string) (App, error)
+	ListServiceBindings(serviceInstanceGUID string) ([]ServiceBinding, error)
+	FindSecurityGroup(name string) (SecurityGroup, error)
+	CreateSecurityGroup(securityGroup SecurityGroup) (string, error)
+	UpdateSecurityGroup(securityGroup SecurityGroup) error
+	DeleteSecurityGroup(GUID string) error
+	BindSecurityGroupToSpace(securityGroupGUID string, spaceGUID string) error
+	UnbindSecurityGroupFromSpace(securityGroupGUID string, spaceGUID string) error
+}
+
+type Organization struct {
+	GUID string
+	Name string
+}
+
+type Space struct {
+	GUID             string
+	Name             string
+	OrganizationGUID string
+}
+
+type App struct {
+	GUID      string
+	Name      string
+	SpaceGUID string
+}
+
+type ServiceBinding struct {
+	GUID                string
+	AppGUID             string
+	ServiceInstanceGUID string
+}
+
+type SecurityGroup struct {
+	GUID       string
+	Name       string
+	Rules      []SecurityGroupRule
+	SpaceGUIDs []string
+}
+
+type SecurityGroupRule struct {
+	Protocol    string `json:"protocol"`
+	Destination string `json:"destination"`
+	Ports       string `json:"ports,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+var (
+	ErrResourceNotFound = errors.New("cloud controller resource not found")
+)
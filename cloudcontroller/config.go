@@ -0,0 +1,33 @@
+package cloudcontroller
+
+import (
+	"errors"
+)
+
+type Config struct {
+	APIURL            string `json:"api_url"`
+	UAAURL            string `json:"uaa_url"`
+	ClientID          string `json:"client_id"`
+	ClientSecret      string `json:"client_secret"`
+	SkipSSLValidation bool   `json:"skip_ssl_validation"`
+}
+
+func (c Config) Validate() error {
+	if c.APIURL == "" {
+		return errors.New("Must provide a non-empty APIURL")
+	}
+
+	if c.UAAURL == "" {
+		return errors.New("Must provide a non-empty UAAURL")
+	}
+
+	if c.ClientID == "" {
+		return errors.New("Must provide a non-empty ClientID")
+	}
+
+	if c.ClientSecret == "" {
+		return errors.New("Must provide a non-empty ClientSecret")
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package fakes
+
+import (
+	"github.com/cloudfoundry-community/elasticache-broker/cloudcontroller"
+)
+
+type FakeClient struct {
+	GetOrganizationCalled       bool
+	GetOrganizationGUID         string
+	GetOrganizationOrganization cloudcontroller.Organization
+	GetOrganizationError        error
+
+	GetSpaceCalled bool
+	GetSpaceGUID   string
+	GetSpaceSpace  cloudcontroller.Space
+	GetSpaceError  error
+
+	GetAppCalled bool
+	GetAppGUID   string
+	GetAppApp    cloudcontroller.App
+	GetAppApps   map[string]cloudcontroller.App
+	GetAppError  error
+
+	ListServiceBindingsCalled              bool
+	ListServiceBindingsServiceInstanceGUID string
+	ListServiceBindingsServiceBindings     []cloudcontroller.ServiceBinding
+	ListServiceBindingsError               error
+
+	FindSecurityGroupCalled        bool
+	FindSecurityGroupName          string
+	FindSecurityGroupSecurityGroup cloudcontroller.SecurityGroup
+	FindSecurityGroupError         error
+
+	CreateSecurityGroupCalled        bool
+	CreateSecurityGroupSecurityGroup cloudcontroller.SecurityGroup
+	CreateSecurityGroupGUID          string
+	CreateSecurityGroupError         error
+
+	UpdateSecurityGroupCalled        bool
+	UpdateSecurityGroupSecurityGroup cloudcontroller.SecurityGroup
+	UpdateSecurityGroupError         error
+
+	DeleteSecurityGroupCalled bool
+	DeleteSecurityGroupGUID   string
+	DeleteSecurityGroupError  error
+
+	BindSecurityGroupToSpaceCalled            bool
+	BindSecurityGroupToSpaceSecurityGroupGUID string
+	BindSecurityGroupToSpaceSpaceGUID         string
+	BindSecurityGroupToSpaceError             error
+
+	UnbindSecurityGroupFromSpaceCalled            bool
+	UnbindSecurityGroupFromSpaceSecurityGroupGUID string
+	UnbindSecurityGroupFromSpaceSpaceGUID         string
+	UnbindSecurityGroupFromSpaceError             error
+}
+
+func (f *FakeClient) GetOrganization(GUID string) (cloudcontroller.Organization, error) {
+	f.GetOrganizationCalled = true
+	f.GetOrganizationGUID = GUID
+
+	return f.GetOrganizationOrganization, f.GetOrganizationError
+}
+
+func (f *FakeClient) GetSpace(GUID string) (cloudcontroller.Space, error) {
+	f.GetSpaceCalled = true
+	f.GetSpaceGUID = GUID
+
+	return f.GetSpaceSpace, f.GetSpaceError
+}
+
+func (f *FakeClient) GetApp(GUID string) (cloudcontroller.App, error) {
+	f.GetAppCalled = true
+	f.GetAppGUID = GUID
+
+	if app, ok := f.GetAppApps[GUID]; ok {
+		return app, f.GetAppError
+	}
+
+	return f.GetAppApp, f.GetAppError
+}
+
+func (f *FakeClient) ListServiceBindings(serviceInstanceGUID string) ([]cloudcontroller.ServiceBinding, error) {
+	f.ListServiceBindingsCalled = true
+	f.ListServiceBindingsServiceInstanceGUID = serviceInstanceGUID
+
+	return f.ListServiceBindingsServiceBindings, f.ListServiceBindingsError
+}
+
+func (f *FakeClient) FindSecurityGroup(name string) (cloudcontroller.SecurityGroup, error) {
+	f.FindSecurityGroupCalled = true
+	f.FindSecurityGroupName = name
+
+	return f.FindSecurityGroupSecurityGroup, f.FindSecurityGroupError
+}
+
+func (f *FakeClient) CreateSecurityGroup(securityGroup cloudcontroller.SecurityGroup) (string, error) {
+	f.CreateSecurityGroupCalled = true
+	f.CreateSecurityGroupSecurityGroup = securityGroup
+
+	return f.CreateSecurityGroupGUID, f.CreateSecurityGroupError
+}
+
+func (f *FakeClient) UpdateSecurityGroup(securityGroup cloudcontroller.SecurityGroup) error {
+	f.UpdateSecurityGroupCalled = true
+	f.UpdateSecurityGroupSecurityGroup = securityGroup
+
+	return f.UpdateSecurityGroupError
+}
+
+func (f *FakeClient) DeleteSecurityGroup(GUID string) error {
+	f.DeleteSecurityGroupCalled = true
+	f.DeleteSecurityGroupGUID = GUID
+
+	return f.DeleteSecurityGroupError
+}
+
+func (f *FakeClient) BindSecurityGroupToSpace(securityGroupGUID string, spaceGUID string) error {
+	f.BindSecurityGroupToSpaceCalled = true
+	f.BindSecurityGroupToSpaceSecurityGroupGUID = securityGroupGUID
+	f.BindSecurityGroupToSpaceSpaceGUID = spaceGUID
+
+	return f.BindSecurityGroupToSpaceError
+}
+
+func (f *FakeClient) UnbindSecurityGroupFromSpace(securityGroupGUID string, spaceGUID string) error {
+	f.UnbindSecurityGroupFromSpaceCalled = true
+	f.UnbindSecurityGroupFromSpaceSecurityGroupGUID = securityGroupGUID
+	f.UnbindSecurityGroupFromSpaceSpaceGUID = spaceGUID
+
+	return f.UnbindSecurityGroupFromSpaceError
+}
@@ -0,0 +1,82 @@
+package cloudcontroller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenExpiryMargin is subtracted from the token lifetime so tokens are refreshed before they expire.
+const tokenExpiryMargin = 30 * time.Second
+
+type uaaTokenSource struct {
+	uaaURL       string
+	clientID     string
+	clientSecret string
+	httpClient   *http.Client
+
+	mutex       sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+type uaaTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// Token returns a cached client credentials access token, requesting a new one to the UAA if
+// there is no token yet or if the current token is about to expire.
+func (t *uaaTokenSource) Token() (string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.accessToken != "" && time.Now().Before(t.expiresAt) {
+		return t.accessToken, nil
+	}
+
+	form := url.Values{
+		"grant_type": {"client_credentials"},
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimRight(t.uaaURL, "/")+"/oauth/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(t.clientID, t.clientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Error requesting UAA token: status code %d", resp.StatusCode)
+	}
+
+	tokenResponse := uaaTokenResponse{}
+	if err = json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		return "", err
+	}
+
+	t.accessToken = tokenResponse.AccessToken
+	t.expiresAt = time.Now().Add(time.Duration(tokenResponse.ExpiresIn)*time.Second - tokenExpiryMargin)
+
+	return t.accessToken, nil
+}
+
+// Invalidate discards the cached token, forcing a new one to be requested on the next call.
+func (t *uaaTokenSource) Invalidate() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.accessToken = ""
+}
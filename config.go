@@ -8,13 +8,19 @@ import (
 	"os"
 
 	"github.com/cloudfoundry-community/elasticache-broker/broker"
+	"github.com/cloudfoundry-community/elasticache-broker/cloudcontroller"
+	"github.com/cloudfoundry-community/elasticache-broker/preflight"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
 )
 
 type Config struct {
-	LogLevel  string           `json:"log_level"`
-	Username  string           `json:"username"`
-	Password  string           `json:"password"`
-  ElastiCacheConfig broker.Config `json:"elasticache_config"`
+	LogLevel              string                 `json:"log_level"`
+	Username              string                 `json:"username"`
+	Password              string                 `json:"password"`
+	ElastiCacheConfig     broker.Config          `json:"elasticache_config"`
+	CloudControllerConfig cloudcontroller.Config `json:"cloud_controller,omitempty"`
+	PreflightConfig       preflight.Config       `json:"preflight,omitempty"`
+	StoreConfig           store.Config           `json:"store,omitempty"`
 }
 
 func LoadConfig(configFile string) (config *Config, err error) {
@@ -61,5 +67,15 @@ func (c Config) Validate() error {
 		return fmt.Errorf("Validating ElastiCache configuration: %s", err)
 	}
 
+	if c.CloudControllerConfig.APIURL != "" || c.ElastiCacheConfig.ManageApplicationSecurityGroups {
+		if err := c.CloudControllerConfig.Validate(); err != nil {
+			return fmt.Errorf("Validating Cloud Controller configuration: %s", err)
+		}
+	}
+
+	if err := c.StoreConfig.Validate(); err != nil {
+		return fmt.Errorf("Validating Store configuration: %s", err)
+	}
+
 	return nil
 }
@@ -7,6 +7,7 @@ import (
 	. "github.com/cloudfoundry-community/elasticache-broker"
 
 	"github.com/cloudfoundry-community/elasticache-broker/broker"
+	"github.com/cloudfoundry-community/elasticache-broker/cloudcontroller"
 )
 
 var _ = Describe("Config", func() {
@@ -65,5 +66,21 @@ var _ = Describe("Config", func() {
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("Validating ElastiCache configuration"))
 		})
+
+		It("returns error if Cloud Controller configuration is not valid", func() {
+			config.CloudControllerConfig = cloudcontroller.Config{APIURL: "https://api.example.com"}
+
+			err := config.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Validating Cloud Controller configuration"))
+		})
+
+		It("returns error if Application Security Groups are managed without a Cloud Controller configuration", func() {
+			config.ElastiCacheConfig.ManageApplicationSecurityGroups = true
+
+			err := config.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Validating Cloud Controller configuration"))
+		})
 	})
 })
@@ -0,0 +1,346 @@
+// Package jsonschema implements the subset of JSON Schema (draft 4) needed to validate
+// service broker parameters: type, properties, required, additionalProperties, items,
+// enum, minimum, maximum, minLength, maxLength, pattern, minItems and maxItems. Schemas
+// using any other validation keyword are rejected by CheckSchema, so that they are not
+// silently ignored.
+package jsonschema
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var knownTypes = []string{"array", "boolean", "integer", "null", "number", "object", "string"}
+
+var supportedKeywords = []string{
+	"type", "properties", "required", "additionalProperties", "items", "enum",
+	"minimum", "maximum", "minLength", "maxLength", "pattern", "minItems", "maxItems",
+}
+
+// annotationKeywords do not take part in validation and are accepted as is.
+var annotationKeywords = []string{"$schema", "id", "title", "description", "default"}
+
+// Validate checks that document conforms to schema. The document is expected to be
+// decoded from JSON (i.e. maps, slices, strings, float64, bools and nil).
+func Validate(schema map[string]interface{}, document interface{}) error {
+	return validate("", schema, document)
+}
+
+// CheckSchema checks that schema only uses supported keywords, known types and valid patterns.
+func CheckSchema(schema map[string]interface{}) error {
+	return checkSchema("", schema)
+}
+
+func validate(path string, schema map[string]interface{}, value interface{}) error {
+	if types, ok := schemaTypes(schema); ok {
+		matched := false
+		for _, t := range types {
+			if hasType(value, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: must be of type %s", location(path), strings.Join(types, " or "))
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if equal(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: must be one of %s", location(path), formatValues(enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return validateObject(path, schema, v)
+	case []interface{}:
+		return validateArray(path, schema, v)
+	case string:
+		return validateString(path, schema, v)
+	}
+
+	if number, ok := toFloat64(value); ok {
+		return validateNumber(path, schema, number)
+	}
+
+	return nil
+}
+
+func validateObject(path string, schema map[string]interface{}, object map[string]interface{}) error {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, ok := object[key]; !ok {
+					return fmt.Errorf("%s: is required", location(join(path, key)))
+				}
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	var keys []string
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if propertySchema, ok := properties[key].(map[string]interface{}); ok {
+			if err := validate(join(path, key), propertySchema, object[key]); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch additionalProperties := schema["additionalProperties"].(type) {
+		case bool:
+			if !additionalProperties {
+				return fmt.Errorf("%s: is not allowed", location(join(path, key)))
+			}
+		case map[string]interface{}:
+			if err := validate(join(path, key), additionalProperties, object[key]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateArray(path string, schema map[string]interface{}, array []interface{}) error {
+	if minItems, ok := toFloat64(schema["minItems"]); ok && float64(len(array)) < minItems {
+		return fmt.Errorf("%s: must have at least %v items", location(path), minItems)
+	}
+
+	if maxItems, ok := toFloat64(schema["maxItems"]); ok && float64(len(array)) > maxItems {
+		return fmt.Errorf("%s: must have at most %v items", location(path), maxItems)
+	}
+
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		for i, item := range array {
+			if err := validate(fmt.Sprintf("%s[%d]", path, i), items, item); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateString(path string, schema map[string]interface{}, str string) error {
+	length := float64(utf8.RuneCountInString(str))
+
+	if minLength, ok := toFloat64(schema["minLength"]); ok && length < minLength {
+		return fmt.Errorf("%s: must be at least %v characters long", location(path), minLength)
+	}
+
+	if maxLength, ok := toFloat64(schema["maxLength"]); ok && length > maxLength {
+		return fmt.Errorf("%s: must be at most %v characters long", location(path), maxLength)
+	}
+
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: invalid pattern '%s': %s", location(path), pattern, err)
+		}
+		if !re.MatchString(str) {
+			return fmt.Errorf("%s: must match pattern '%s'", location(path), pattern)
+		}
+	}
+
+	return nil
+}
+
+func validateNumber(path string, schema map[string]interface{}, number float64) error {
+	if minimum, ok := toFloat64(schema["minimum"]); ok && number < minimum {
+		return fmt.Errorf("%s: must be greater than or equal to %v", location(path), minimum)
+	}
+
+	if maximum, ok := toFloat64(schema["maximum"]); ok && number > maximum {
+		return fmt.Errorf("%s: must be less than or equal to %v", location(path), maximum)
+	}
+
+	return nil
+}
+
+func checkSchema(path string, schema map[string]interface{}) error {
+	var keywords []string
+	for keyword := range schema {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+
+	for _, keyword := range keywords {
+		if !contains(supportedKeywords, keyword) && !contains(annotationKeywords, keyword) {
+			return fmt.Errorf("%s: unsupported keyword '%s'", location(path), keyword)
+		}
+	}
+
+	if _, ok := schema["type"]; ok {
+		types, ok := schemaTypes(schema)
+		if !ok {
+			return fmt.Errorf("%s: type must be a string or a list of strings", location(path))
+		}
+		for _, t := range types {
+			if !contains(knownTypes, t) {
+				return fmt.Errorf("%s: unknown type '%s'", location(path), t)
+			}
+		}
+	}
+
+	if pattern, ok := schema["pattern"]; ok {
+		str, ok := pattern.(string)
+		if !ok {
+			return fmt.Errorf("%s: pattern must be a string", location(path))
+		}
+		if _, err := regexp.Compile(str); err != nil {
+			return fmt.Errorf("%s: invalid pattern '%s': %s", location(path), str, err)
+		}
+	}
+
+	if properties, ok := schema["properties"].(map[string]interface{}); ok {
+		for key, property := range properties {
+			propertySchema, ok := property.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s: must be a schema", location(join(path, key)))
+			}
+			if err := checkSchema(join(path, key), propertySchema); err != nil {
+				return err
+			}
+		}
+	}
+
+	if additionalProperties, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+		if err := checkSchema(path, additionalProperties); err != nil {
+			return err
+		}
+	}
+
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		if err := checkSchema(path+"[]", items); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func schemaTypes(schema map[string]interface{}) ([]string, bool) {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}, true
+	case []string:
+		return t, true
+	case []interface{}:
+		var types []string
+		for _, item := range t {
+			str, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			types = append(types, str)
+		}
+		return types, true
+	}
+
+	return nil, false
+}
+
+func hasType(value interface{}, t string) bool {
+	switch t {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "number":
+		_, ok := toFloat64(value)
+		return ok
+	case "integer":
+		number, ok := toFloat64(value)
+		return ok && number == float64(int64(number))
+	}
+
+	return false
+}
+
+func equal(a, b interface{}) bool {
+	if numberA, ok := toFloat64(a); ok {
+		numberB, ok := toFloat64(b)
+		return ok && numberA == numberB
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+
+	return 0, false
+}
+
+func formatValues(values []interface{}) string {
+	var formatted []string
+	for _, value := range values {
+		formatted = append(formatted, fmt.Sprintf("%v", value))
+	}
+
+	return strings.Join(formatted, ", ")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+func location(path string) string {
+	if path == "" {
+		return "parameters"
+	}
+
+	return "parameters." + path
+}
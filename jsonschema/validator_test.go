@@ -0,0 +1,106 @@
+package jsonschema_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry-community/elasticache-broker/jsonschema"
+)
+
+func decode(document string) map[string]interface{} {
+	decoded := map[string]interface{}{}
+	Expect(json.Unmarshal([]byte(document), &decoded)).To(Succeed())
+	return decoded
+}
+
+var _ = Describe("Validate", func() {
+	var schema map[string]interface{}
+
+	BeforeEach(func() {
+		schema = decode(`{
+			"$schema": "http://json-schema.org/draft-04/schema#",
+			"type": "object",
+			"additionalProperties": false,
+			"required": ["name"],
+			"properties": {
+				"name": {"type": "string", "minLength": 1, "maxLength": 8, "pattern": "^[a-z]+$"},
+				"size": {"type": "integer", "minimum": 1, "maximum": 3},
+				"class": {"type": "string", "enum": ["small", "large"]},
+				"enabled": {"type": "boolean"},
+				"zones": {"type": "array", "maxItems": 2, "items": {"type": "string"}},
+				"tags": {"type": "object", "additionalProperties": {"type": "string"}}
+			}
+		}`)
+	})
+
+	It("accepts a valid document", func() {
+		document := decode(`{"name": "cache", "size": 2, "class": "small", "enabled": true, "zones": ["a"], "tags": {"team": "a"}}`)
+
+		Expect(Validate(schema, document)).To(Succeed())
+	})
+
+	It("rejects missing required properties", func() {
+		Expect(Validate(schema, decode(`{}`))).To(MatchError("parameters.name: is required"))
+	})
+
+	It("rejects additional properties", func() {
+		Expect(Validate(schema, decode(`{"name": "cache", "color": "red"}`))).To(MatchError("parameters.color: is not allowed"))
+	})
+
+	It("rejects values of the wrong type", func() {
+		Expect(Validate(schema, decode(`{"name": "cache", "enabled": "yes"}`))).To(MatchError("parameters.enabled: must be of type boolean"))
+	})
+
+	It("rejects numbers that are not integers", func() {
+		Expect(Validate(schema, decode(`{"name": "cache", "size": 1.5}`))).To(MatchError("parameters.size: must be of type integer"))
+	})
+
+	It("rejects numbers out of range", func() {
+		Expect(Validate(schema, decode(`{"name": "cache", "size": 4}`))).To(MatchError("parameters.size: must be less than or equal to 3"))
+		Expect(Validate(schema, decode(`{"name": "cache", "size": 0}`))).To(MatchError("parameters.size: must be greater than or equal to 1"))
+	})
+
+	It("rejects values not in the enum", func() {
+		Expect(Validate(schema, decode(`{"name": "cache", "class": "medium"}`))).To(MatchError("parameters.class: must be one of small, large"))
+	})
+
+	It("rejects strings that do not match the constraints", func() {
+		Expect(Validate(schema, decode(`{"name": "Cache"}`))).To(MatchError("parameters.name: must match pattern '^[a-z]+$'"))
+		Expect(Validate(schema, decode(`{"name": "averylongname"}`))).To(MatchError("parameters.name: must be at most 8 characters long"))
+	})
+
+	It("validates array items", func() {
+		Expect(Validate(schema, decode(`{"name": "cache", "zones": ["a", 1]}`))).To(MatchError("parameters.zones[1]: must be of type string"))
+		Expect(Validate(schema, decode(`{"name": "cache", "zones": ["a", "b", "c"]}`))).To(MatchError("parameters.zones: must have at most 2 items"))
+	})
+
+	It("validates additional properties against a schema", func() {
+		Expect(Validate(schema, decode(`{"name": "cache", "tags": {"team": 1}}`))).To(MatchError("parameters.tags.team: must be of type string"))
+	})
+})
+
+var _ = Describe("CheckSchema", func() {
+	It("accepts a valid schema", func() {
+		Expect(CheckSchema(decode(`{"type": "object", "properties": {"name": {"type": ["string", "null"]}}}`))).To(Succeed())
+	})
+
+	It("rejects unknown types", func() {
+		Expect(CheckSchema(decode(`{"type": "object", "properties": {"name": {"type": "text"}}}`))).To(MatchError("parameters.name: unknown type 'text'"))
+	})
+
+	It("rejects unsupported keywords", func() {
+		Expect(CheckSchema(decode(`{"type": "object", "properties": {"port": {"oneOf": [{"type": "integer"}, {"type": "string"}]}}}`))).To(MatchError("parameters.port: unsupported keyword 'oneOf'"))
+		Expect(CheckSchema(decode(`{"$ref": "#/definitions/cache"}`))).To(MatchError("parameters: unsupported keyword '$ref'"))
+		Expect(CheckSchema(decode(`{"type": "string", "format": "hostname"}`))).To(MatchError("parameters: unsupported keyword 'format'"))
+	})
+
+	It("accepts annotation keywords", func() {
+		Expect(CheckSchema(decode(`{"$schema": "http://json-schema.org/draft-04/schema#", "title": "Cache", "type": "object", "properties": {"name": {"type": "string", "description": "Name", "default": "cache"}}}`))).To(Succeed())
+	})
+
+	It("rejects invalid patterns", func() {
+		Expect(CheckSchema(decode(`{"type": "string", "pattern": "["}`))).To(MatchError(ContainSubstring("invalid pattern '['")))
+	})
+})
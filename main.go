@@ -10,18 +10,25 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/frodenas/brokerapi"
 	"github.com/pivotal-golang/lager"
 
+	"github.com/cloudfoundry-community/elasticache-broker/api"
+	"github.com/cloudfoundry-community/elasticache-broker/awscloudwatch"
+	"github.com/cloudfoundry-community/elasticache-broker/awsec2"
 	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
 	"github.com/cloudfoundry-community/elasticache-broker/broker"
+	"github.com/cloudfoundry-community/elasticache-broker/cloudcontroller"
+	"github.com/cloudfoundry-community/elasticache-broker/preflight"
+	"github.com/cloudfoundry-community/elasticache-broker/store"
 )
 
 var (
 	configFilePath string
 	port           string
+	preflightOnly  bool
 
 	logLevels = map[string]lager.LogLevel{
 		"DEBUG": lager.DEBUG,
@@ -34,6 +41,7 @@ var (
 func init() {
 	flag.StringVar(&configFilePath, "config", "", "Location of the config file")
 	flag.StringVar(&port, "port", "3000", "Listen port")
+	flag.BoolVar(&preflightOnly, "preflight", false, "Check the plans against the AWS offerings and exit")
 }
 
 func buildLogger(logLevel string) lager.Logger {
@@ -64,15 +72,56 @@ func main() {
 	iamsvc := iam.New(awsSession)
 	elasticachesvc := elasticache.New(awsSession)
 	cacheCluster := awselasticache.NewElastiCacheCluster(config.ElastiCacheConfig.Region, iamsvc, elasticachesvc, logger)
+	cacheSubnetGroup := awselasticache.NewElastiCacheSubnetGroup(elasticachesvc, logger)
+
+	ec2svc := awsec2.NewEC2API(awsSession)
+	securityGroup := awsec2.NewEC2SecurityGroup(ec2svc, logger)
+
+	cloudwatchsvc := awscloudwatch.NewCloudWatchAPI(awsSession)
+	cacheClusterMetrics := awscloudwatch.NewCloudWatchCacheClusterMetrics(cloudwatchsvc, logger)
+
+	if preflightOnly || config.PreflightConfig.Enabled {
+		offerings := awselasticache.NewElastiCacheOfferings(elasticachesvc, logger)
+		checker := preflight.NewChecker(config.ElastiCacheConfig.Catalog, offerings, cacheSubnetGroup, securityGroup, logger)
+		report := checker.Run()
+		report.Write(os.Stdout)
+
+		if preflightOnly {
+			if report.HasErrors() {
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+
+		if report.HasErrors() && config.PreflightConfig.FailOnError {
+			log.Fatal("Preflight check failed")
+		}
+	}
+
+	var cloudController cloudcontroller.Client
+	if config.CloudControllerConfig.APIURL != "" {
+		cloudController = cloudcontroller.NewCCClient(config.CloudControllerConfig, logger)
+	}
 
-	serviceBroker := broker.New(config.ElastiCacheConfig, cacheCluster, logger)
+	brokerStore, err := store.New(config.StoreConfig, logger)
+	if err != nil {
+		log.Fatalf("Error opening the broker store: %s", err)
+	}
+
+	serviceBroker := broker.New(config.ElastiCacheConfig, cacheCluster, cacheSubnetGroup, securityGroup, cacheClusterMetrics, cloudController, brokerStore, logger)
+	if err = serviceBroker.SyncCacheSubnetGroups(); err != nil {
+		log.Fatalf("Error syncing cache subnet groups: %s", err)
+	}
+	go serviceBroker.RunPendingDeletes(nil)
+	go serviceBroker.RunWorkflows(nil)
+	go serviceBroker.RunFailedProvisionCleanup(nil)
 
 	credentials := brokerapi.BrokerCredentials{
 		Username: config.Username,
 		Password: config.Password,
 	}
 
-	brokerAPI := brokerapi.New(serviceBroker, logger, credentials)
+	brokerAPI := api.New(serviceBroker, logger, credentials)
 	http.Handle("/", brokerAPI)
 
 	fmt.Println("ElastiCache Service Broker started on port " + port + "...")
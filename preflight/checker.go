@@ -0,0 +1,116 @@
+// Package preflight checks the service plans in the catalog against what is actually
+// available in the AWS account and region the broker is configured for.
+package preflight
+
+import (
+	"fmt"
+
+	"github.com/pivotal-golang/lager"
+
+	"github.com/cloudfoundry-community/elasticache-broker/awsec2"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/broker"
+)
+
+type Checker struct {
+	catalog          broker.Catalog
+	offerings        awselasticache.Offerings
+	cacheSubnetGroup awselasticache.CacheSubnetGroup
+	securityGroup    awsec2.SecurityGroup
+	logger           lager.Logger
+}
+
+func NewChecker(
+	catalog broker.Catalog,
+	offerings awselasticache.Offerings,
+	cacheSubnetGroup awselasticache.CacheSubnetGroup,
+	securityGroup awsec2.SecurityGroup,
+	logger lager.Logger,
+) *Checker {
+	return &Checker{
+		catalog:          catalog,
+		offerings:        offerings,
+		cacheSubnetGroup: cacheSubnetGroup,
+		securityGroup:    securityGroup,
+		logger:           logger.Session("preflight"),
+	}
+}
+
+// Run checks every plan in the catalog and returns a report with the problems found.
+func (c *Checker) Run() Report {
+	report := Report{}
+
+	for _, service := range c.catalog.Services {
+		for _, servicePlan := range service.Plans {
+			planReport := PlanReport{
+				ServiceName: service.Name,
+				PlanName:    servicePlan.Name,
+				Errors:      c.checkServicePlan(servicePlan),
+			}
+			c.logger.Debug("check-service-plan", lager.Data{
+				"service": planReport.ServiceName,
+				"plan":    planReport.PlanName,
+				"errors":  planReport.Errors,
+			})
+			report.Plans = append(report.Plans, planReport)
+		}
+	}
+
+	return report
+}
+
+func (c *Checker) checkServicePlan(servicePlan broker.ServicePlan) []string {
+	var planErrors []string
+	properties := servicePlan.ElastiCacheProperties
+
+	exists, err := c.offerings.EngineVersionExists(properties.Engine, properties.EngineVersion)
+	switch {
+	case err != nil:
+		planErrors = append(planErrors, fmt.Sprintf("Checking Engine Version: %s", err))
+	case !exists && properties.EngineVersion == "":
+		planErrors = append(planErrors, fmt.Sprintf("Engine '%s' is not available", properties.Engine))
+	case !exists:
+		planErrors = append(planErrors, fmt.Sprintf("Engine Version '%s' is not available for engine '%s'", properties.EngineVersion, properties.Engine))
+	}
+
+	offered, err := c.offerings.CacheNodeTypeOffered(properties.Engine, properties.CacheInstanceClass)
+	switch {
+	case err != nil:
+		planErrors = append(planErrors, fmt.Sprintf("Checking Cache Instance Class: %s", err))
+	case !offered:
+		planErrors = append(planErrors, fmt.Sprintf("Cache Instance Class '%s' is not offered for engine '%s' in this region", properties.CacheInstanceClass, properties.Engine))
+	}
+
+	// Cache subnet groups for plans with SubnetIDs are created by the broker itself.
+	if properties.CacheSubnetGroupName != "" {
+		if _, err = c.cacheSubnetGroup.Describe(properties.CacheSubnetGroupName); err != nil {
+			if err == awselasticache.ErrCacheSubnetGroupDoesNotExist {
+				planErrors = append(planErrors, fmt.Sprintf("Cache Subnet Group '%s' does not exist", properties.CacheSubnetGroupName))
+			} else {
+				planErrors = append(planErrors, fmt.Sprintf("Checking Cache Subnet Group '%s': %s", properties.CacheSubnetGroupName, err))
+			}
+		}
+	}
+
+	for _, securityGroupID := range properties.CacheSecurityGroups {
+		if _, err = c.securityGroup.Describe(securityGroupID); err != nil {
+			if err == awsec2.ErrSecurityGroupDoesNotExist {
+				planErrors = append(planErrors, fmt.Sprintf("Cache Security Group '%s' does not exist", securityGroupID))
+			} else {
+				planErrors = append(planErrors, fmt.Sprintf("Checking Cache Security Group '%s': %s", securityGroupID, err))
+			}
+		}
+	}
+
+	if properties.CacheParameterGroupName != "" {
+		exists, err = c.offerings.CacheParameterGroupExists(properties.CacheParameterGroupName)
+		switch {
+		case err != nil:
+			planErrors = append(planErrors, fmt.Sprintf("Checking Cache Parameter Group '%s': %s", properties.CacheParameterGroupName, err))
+		case !exists:
+			planErrors = append(planErrors, fmt.Sprintf("Cache Parameter Group '%s' does not exist", properties.CacheParameterGroupName))
+		}
+	}
+
+	return planErrors
+}
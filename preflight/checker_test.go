@@ -0,0 +1,164 @@
+package preflight_test
+
+import (
+	"bytes"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/pivotal-golang/lager/lagertest"
+
+	"github.com/cloudfoundry-community/elasticache-broker/awsec2"
+	ec2fakes "github.com/cloudfoundry-community/elasticache-broker/awsec2/fakes"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache"
+	"github.com/cloudfoundry-community/elasticache-broker/awselasticache/fakes"
+	"github.com/cloudfoundry-community/elasticache-broker/broker"
+	. "github.com/cloudfoundry-community/elasticache-broker/preflight"
+)
+
+var _ = Describe("Checker", func() {
+	var (
+		properties       broker.ElastiCacheProperties
+		offerings        *fakes.FakeOfferings
+		cacheSubnetGroup *fakes.FakeCacheSubnetGroup
+		securityGroup    *ec2fakes.FakeSecurityGroup
+		report           Report
+	)
+
+	BeforeEach(func() {
+		properties = broker.ElastiCacheProperties{
+			CacheInstanceClass:      "cache.t2.micro",
+			Engine:                  "redis",
+			EngineVersion:           "2.8.24",
+			CacheSubnetGroupName:    "subnet-group-name",
+			CacheSecurityGroups:     []string{"sg-1"},
+			CacheParameterGroupName: "parameter-group-name",
+		}
+		offerings = &fakes.FakeOfferings{
+			EngineVersionExistsResult:       true,
+			CacheNodeTypeOfferedResult:      true,
+			CacheParameterGroupExistsResult: true,
+		}
+		cacheSubnetGroup = &fakes.FakeCacheSubnetGroup{}
+		securityGroup = &ec2fakes.FakeSecurityGroup{}
+	})
+
+	JustBeforeEach(func() {
+		catalog := broker.Catalog{
+			Services: []broker.Service{
+				broker.Service{
+					ID:   "Service-1",
+					Name: "Service 1",
+					Plans: []broker.ServicePlan{
+						broker.ServicePlan{
+							ID:                    "Plan-1",
+							Name:                  "Plan 1",
+							ElastiCacheProperties: properties,
+						},
+					},
+				},
+			},
+		}
+
+		checker := NewChecker(catalog, offerings, cacheSubnetGroup, securityGroup, lagertest.NewTestLogger("preflight_test"))
+		report = checker.Run()
+	})
+
+	It("checks the plan against the AWS offerings", func() {
+		Expect(offerings.EngineVersionExistsEngine).To(Equal("redis"))
+		Expect(offerings.EngineVersionExistsEngineVersion).To(Equal("2.8.24"))
+		Expect(offerings.CacheNodeTypeOfferedEngine).To(Equal("redis"))
+		Expect(offerings.CacheNodeTypeOfferedCacheNodeType).To(Equal("cache.t2.micro"))
+		Expect(cacheSubnetGroup.DescribeName).To(Equal("subnet-group-name"))
+		Expect(securityGroup.DescribeID).To(Equal("sg-1"))
+		Expect(offerings.CacheParameterGroupExistsName).To(Equal("parameter-group-name"))
+	})
+
+	It("reports the plan without errors", func() {
+		Expect(report.HasErrors()).To(BeFalse())
+		Expect(report.Plans).To(Equal([]PlanReport{
+			PlanReport{ServiceName: "Service 1", PlanName: "Plan 1"},
+		}))
+	})
+
+	Context("when the engine version is not available", func() {
+		BeforeEach(func() {
+			offerings.EngineVersionExistsResult = false
+		})
+
+		It("reports an error", func() {
+			Expect(report.HasErrors()).To(BeTrue())
+			Expect(report.Plans[0].Errors).To(ConsistOf("Engine Version '2.8.24' is not available for engine 'redis'"))
+		})
+	})
+
+	Context("when the cache node type is not offered", func() {
+		BeforeEach(func() {
+			offerings.CacheNodeTypeOfferedResult = false
+		})
+
+		It("reports an error", func() {
+			Expect(report.Plans[0].Errors).To(ConsistOf("Cache Instance Class 'cache.t2.micro' is not offered for engine 'redis' in this region"))
+		})
+	})
+
+	Context("when the referenced resources do not exist", func() {
+		BeforeEach(func() {
+			cacheSubnetGroup.DescribeError = awselasticache.ErrCacheSubnetGroupDoesNotExist
+			securityGroup.DescribeError = awsec2.ErrSecurityGroupDoesNotExist
+			offerings.CacheParameterGroupExistsResult = false
+		})
+
+		It("reports an error for each of them", func() {
+			Expect(report.Plans[0].Errors).To(ConsistOf(
+				"Cache Subnet Group 'subnet-group-name' does not exist",
+				"Cache Security Group 'sg-1' does not exist",
+				"Cache Parameter Group 'parameter-group-name' does not exist",
+			))
+		})
+	})
+
+	Context("when checking the offerings fails", func() {
+		BeforeEach(func() {
+			offerings.EngineVersionExistsError = errors.New("operation failed")
+		})
+
+		It("reports the error", func() {
+			Expect(report.Plans[0].Errors).To(ConsistOf("Checking Engine Version: operation failed"))
+		})
+	})
+
+	Context("when the plan uses subnet IDs", func() {
+		BeforeEach(func() {
+			properties.CacheSubnetGroupName = ""
+			properties.SubnetIDs = []string{"subnet-1"}
+			properties.CacheParameterGroupName = ""
+		})
+
+		It("does not check the cache subnet group nor the parameter group", func() {
+			Expect(cacheSubnetGroup.DescribeCalled).To(BeFalse())
+			Expect(offerings.CacheParameterGroupExistsCalled).To(BeFalse())
+		})
+	})
+})
+
+var _ = Describe("Report", func() {
+	It("writes a line per plan followed by its errors", func() {
+		report := Report{
+			Plans: []PlanReport{
+				PlanReport{ServiceName: "Service 1", PlanName: "Plan 1"},
+				PlanReport{ServiceName: "Service 1", PlanName: "Plan 2", Errors: []string{"first error", "second error"}},
+			},
+		}
+
+		buffer := &bytes.Buffer{}
+		Expect(report.Write(buffer)).To(Succeed())
+		Expect(buffer.String()).To(Equal(
+			"Service 'Service 1' Plan 'Plan 1': OK\n" +
+				"Service 'Service 1' Plan 'Plan 2': 2 error(s)\n" +
+				"  - first error\n" +
+				"  - second error\n",
+		))
+	})
+})
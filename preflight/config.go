@@ -0,0 +1,6 @@
+package preflight
+
+type Config struct {
+	Enabled     bool `json:"enabled"`
+	FailOnError bool `json:"fail_on_error"`
+}
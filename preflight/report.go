@@ -0,0 +1,48 @@
+package preflight
+
+import (
+	"fmt"
+	"io"
+)
+
+type Report struct {
+	Plans []PlanReport
+}
+
+type PlanReport struct {
+	ServiceName string
+	PlanName    string
+	Errors      []string
+}
+
+func (r Report) HasErrors() bool {
+	for _, planReport := range r.Plans {
+		if len(planReport.Errors) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Write prints one line per plan, followed by the plan errors if there are any.
+func (r Report) Write(w io.Writer) error {
+	for _, planReport := range r.Plans {
+		status := "OK"
+		if len(planReport.Errors) > 0 {
+			status = fmt.Sprintf("%d error(s)", len(planReport.Errors))
+		}
+
+		if _, err := fmt.Fprintf(w, "Service '%s' Plan '%s': %s\n", planReport.ServiceName, planReport.PlanName, status); err != nil {
+			return err
+		}
+
+		for _, planError := range planReport.Errors {
+			if _, err := fmt.Fprintf(w, "  - %s\n", planError); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,7 @@
+//go:build mysql
+// +build mysql
+
+package main
+
+// Registers the MySQL driver for the SQL store, build with `-tags mysql`.
+import _ "github.com/go-sql-driver/mysql"
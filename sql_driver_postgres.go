@@ -0,0 +1,7 @@
+//go:build postgres
+// +build postgres
+
+package main
+
+// Registers the PostgreSQL driver for the SQL store, build with `-tags postgres`.
+import _ "github.com/lib/pq"
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pivotal-golang/lager"
+)
+
+// Store types.
+const (
+	TypeMemory = "memory"
+	TypeFile   = "file"
+	TypeSQL    = "sql"
+)
+
+type Config struct {
+	Type   string `json:"type"`
+	Path   string `json:"path,omitempty"`
+	Driver string `json:"driver,omitempty"`
+	DSN    string `json:"dsn,omitempty"`
+}
+
+func (c Config) Validate() error {
+	switch c.Type {
+	case "", TypeMemory:
+	case TypeFile:
+		if c.Path == "" {
+			return errors.New("Must provide a non-empty Path")
+		}
+	case TypeSQL:
+		if c.Driver == "" {
+			return errors.New("Must provide a non-empty Driver")
+		}
+		if c.DSN == "" {
+			return errors.New("Must provide a non-empty DSN")
+		}
+	default:
+		return fmt.Errorf("Invalid Type '%s', must be one of: %s, %s, %s", c.Type, TypeMemory, TypeFile, TypeSQL)
+	}
+
+	return nil
+}
+
+// New returns the store configured by config, defaulting to an in-memory store. As the state of
+// an in-memory store is lost when the broker restarts, a warning is logged when it is used.
+func New(config Config, logger lager.Logger) (Store, error) {
+	switch config.Type {
+	case TypeFile:
+		return NewFileStore(config.Path, logger)
+	case TypeSQL:
+		return NewSQLStore(config.Driver, config.DSN, logger)
+	default:
+		logger.Info("memory-store", lager.Data{"warning": "the broker state, including operations in progress, is lost on restart: configure a file or sql store"})
+		return NewMemoryStore(), nil
+	}
+}
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pivotal-golang/lager"
+)
+
+// FileStore keeps the broker state in memory and persists it to a JSON file on every change,
+// so it survives broker restarts. Changes are made to a copy of the state, which replaces the
+// current state only once the file has been replaced atomically: a change that cannot be
+// persisted is not seen either.
+type FileStore struct {
+	path   string
+	mutex  sync.Mutex
+	logger lager.Logger
+
+	memoryMutex sync.RWMutex
+	memory      *MemoryStore
+}
+
+type fileContents struct {
+	Instances []Instance `json:"instances"`
+	Bindings  []Binding  `json:"bindings"`
+}
+
+func NewFileStore(path string, logger lager.Logger) (*FileStore, error) {
+	s := &FileStore{
+		path:   path,
+		memory: NewMemoryStore(),
+		logger: logger.Session("file-store"),
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *FileStore) GetInstance(instanceID string) (Instance, error) {
+	return s.current().GetInstance(instanceID)
+}
+
+func (s *FileStore) PutInstance(instance Instance) error {
+	return s.update(func(memory *MemoryStore) error {
+		return memory.PutInstance(instance)
+	})
+}
+
+func (s *FileStore) DeleteInstance(instanceID string) error {
+	return s.update(func(memory *MemoryStore) error {
+		return memory.DeleteInstance(instanceID)
+	})
+}
+
+func (s *FileStore) ListInstances() ([]Instance, error) {
+	return s.current().ListInstances()
+}
+
+func (s *FileStore) GetBinding(instanceID, bindingID string) (Binding, error) {
+	return s.current().GetBinding(instanceID, bindingID)
+}
+
+func (s *FileStore) PutBinding(binding Binding) error {
+	return s.update(func(memory *MemoryStore) error {
+		return memory.PutBinding(binding)
+	})
+}
+
+func (s *FileStore) DeleteBinding(instanceID, bindingID string) error {
+	return s.update(func(memory *MemoryStore) error {
+		return memory.DeleteBinding(instanceID, bindingID)
+	})
+}
+
+func (s *FileStore) ListBindings(instanceID string) ([]Binding, error) {
+	return s.current().ListBindings(instanceID)
+}
+
+func (s *FileStore) Durable() bool {
+	return true
+}
+
+func (s *FileStore) current() *MemoryStore {
+	s.memoryMutex.RLock()
+	defer s.memoryMutex.RUnlock()
+
+	return s.memory
+}
+
+// update applies change to a copy of the state, persists the copy and then makes it current.
+func (s *FileStore) update(change func(memory *MemoryStore) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	memory := s.current().clone()
+	if err := change(memory); err != nil {
+		return err
+	}
+
+	if err := s.save(memory); err != nil {
+		return err
+	}
+
+	s.memoryMutex.Lock()
+	s.memory = memory
+	s.memoryMutex.Unlock()
+
+	return nil
+}
+
+func (s *FileStore) load() error {
+	bytes, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	contents := fileContents{}
+	if err = json.Unmarshal(bytes, &contents); err != nil {
+		return err
+	}
+
+	for _, instance := range contents.Instances {
+		s.memory.instances[instance.ID] = instance
+	}
+	for _, binding := range contents.Bindings {
+		s.memory.PutBinding(binding)
+	}
+
+	s.logger.Debug("load", lager.Data{"path": s.path, "instances": len(contents.Instances), "bindings": len(contents.Bindings)})
+
+	return nil
+}
+
+func (s *FileStore) save(memory *MemoryStore) error {
+	instances, err := memory.ListInstances()
+	if err != nil {
+		return err
+	}
+
+	bytes, err := json.Marshal(fileContents{
+		Instances: instances,
+		Bindings:  memory.allBindings(),
+	})
+	if err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path))
+	if err != nil {
+		s.logger.Error("save", err)
+		return err
+	}
+
+	if _, err = file.Write(bytes); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		s.logger.Error("save", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,222 @@
+package store
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryStore keeps the broker state in memory. State is lost when the broker restarts.
+type MemoryStore struct {
+	mutex     sync.RWMutex
+	instances map[string]Instance
+	bindings  map[string]map[string]Binding
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		instances: make(map[string]Instance),
+		bindings:  make(map[string]map[string]Binding),
+	}
+}
+
+func (s *MemoryStore) GetInstance(instanceID string) (Instance, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	instance, ok := s.instances[instanceID]
+	if !ok {
+		return Instance{}, ErrInstanceNotFound
+	}
+
+	return copyInstance(instance), nil
+}
+
+func (s *MemoryStore) PutInstance(instance Instance) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.instances[instance.ID].Version != instance.Version {
+		return ErrConcurrentUpdate
+	}
+
+	instance.Version++
+	s.instances[instance.ID] = copyInstance(instance)
+
+	return nil
+}
+
+// DeleteInstance deletes the service instance along with its bindings.
+func (s *MemoryStore) DeleteInstance(instanceID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.instances[instanceID]; !ok {
+		return ErrInstanceNotFound
+	}
+
+	delete(s.instances, instanceID)
+	delete(s.bindings, instanceID)
+
+	return nil
+}
+
+func (s *MemoryStore) ListInstances() ([]Instance, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	instances := []Instance{}
+	for _, instance := range s.instances {
+		instances = append(instances, copyInstance(instance))
+	}
+	sort.Sort(instancesByID(instances))
+
+	return instances, nil
+}
+
+func (s *MemoryStore) GetBinding(instanceID, bindingID string) (Binding, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	binding, ok := s.bindings[instanceID][bindingID]
+	if !ok {
+		return Binding{}, ErrBindingNotFound
+	}
+
+	return binding, nil
+}
+
+func (s *MemoryStore) PutBinding(binding Binding) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.bindings[binding.InstanceID]; !ok {
+		s.bindings[binding.InstanceID] = make(map[string]Binding)
+	}
+	s.bindings[binding.InstanceID][binding.ID] = binding
+
+	return nil
+}
+
+func (s *MemoryStore) DeleteBinding(instanceID, bindingID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.bindings[instanceID][bindingID]; !ok {
+		return ErrBindingNotFound
+	}
+
+	delete(s.bindings[instanceID], bindingID)
+	if len(s.bindings[instanceID]) == 0 {
+		delete(s.bindings, instanceID)
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) ListBindings(instanceID string) ([]Binding, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	bindings := []Binding{}
+	for _, binding := range s.bindings[instanceID] {
+		bindings = append(bindings, binding)
+	}
+	sort.Sort(bindingsByID(bindings))
+
+	return bindings, nil
+}
+
+// Durable returns false: the state of a memory store is lost when the broker restarts.
+func (s *MemoryStore) Durable() bool {
+	return false
+}
+
+// clone returns a copy of the store, whose changes are not seen by the store.
+func (s *MemoryStore) clone() *MemoryStore {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	clone := NewMemoryStore()
+	for instanceID, instance := range s.instances {
+		clone.instances[instanceID] = copyInstance(instance)
+	}
+	for instanceID, bindings := range s.bindings {
+		clone.bindings[instanceID] = make(map[string]Binding)
+		for bindingID, binding := range bindings {
+			clone.bindings[instanceID][bindingID] = binding
+		}
+	}
+
+	return clone
+}
+
+// copyInstance copies the operation, failure, parameters and adopted tags of a service instance,
+// so changes made by callers to the instances they get or put are not seen by the store.
+func copyInstance(instance Instance) Instance {
+	if instance.Parameters != nil {
+		parameters := make(map[string]interface{})
+		for key, value := range instance.Parameters {
+			parameters[key] = value
+		}
+		instance.Parameters = parameters
+	}
+
+	if instance.AdoptedTags != nil {
+		adoptedTags := make(map[string]string)
+		for key, value := range instance.AdoptedTags {
+			adoptedTags[key] = value
+		}
+		instance.AdoptedTags = adoptedTags
+	}
+
+	if instance.Operation != nil {
+		operation := *instance.Operation
+		if operation.Workflow != nil {
+			workflow := *operation.Workflow
+			workflow.Steps = append([]WorkflowStep(nil), workflow.Steps...)
+			if workflow.Data != nil {
+				data := make(map[string]string)
+				for key, value := range workflow.Data {
+					data[key] = value
+				}
+				workflow.Data = data
+			}
+			operation.Workflow = &workflow
+		}
+		instance.Operation = &operation
+	}
+
+	if instance.Failure != nil {
+		failure := *instance.Failure
+		instance.Failure = &failure
+	}
+
+	return instance
+}
+
+type instancesByID []Instance
+
+func (s instancesByID) Len() int           { return len(s) }
+func (s instancesByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s instancesByID) Less(i, j int) bool { return s[i].ID < s[j].ID }
+
+type bindingsByID []Binding
+
+func (s bindingsByID) Len() int           { return len(s) }
+func (s bindingsByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s bindingsByID) Less(i, j int) bool { return s[i].ID < s[j].ID }
+
+func (s *MemoryStore) allBindings() []Binding {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	bindings := []Binding{}
+	for _, instanceBindings := range s.bindings {
+		for _, binding := range instanceBindings {
+			bindings = append(bindings, binding)
+		}
+	}
+	sort.Sort(bindingsByID(bindings))
+
+	return bindings
+}
@@ -0,0 +1,364 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pivotal-golang/lager"
+)
+
+// sqlMigrations are the schema changes applied in order by NewSQLStore. Applied migrations
+// are recorded in the schema_migrations table; never change or remove a migration, add a
+// new one instead. TEXT columns are rewritten for the drivers whose TEXT type is too small
+// (see ddl).
+var sqlMigrations = []string{
+	`CREATE TABLE instances (
+		id VARCHAR(255) NOT NULL PRIMARY KEY,
+		data TEXT NOT NULL,
+		version INTEGER NOT NULL
+	)`,
+	`CREATE TABLE bindings (
+		instance_id VARCHAR(255) NOT NULL,
+		id VARCHAR(255) NOT NULL,
+		data TEXT NOT NULL,
+		PRIMARY KEY (instance_id, id)
+	)`,
+}
+
+// sqlMigrationsLock is the name of the lock serializing the schema migrations of the brokers
+// sharing a database, sqlMigrationsLockID its key as a postgres advisory lock, and
+// sqlMigrationsLockTimeout the seconds MySQL waits for it.
+const (
+	sqlMigrationsLock        = "elasticache-broker-schema-migrations"
+	sqlMigrationsLockID      = 4523719105
+	sqlMigrationsLockTimeout = 300
+)
+
+// SQLStore keeps the broker state in a SQL database, so several brokers can share it. Service
+// instance rows carry a version used for optimistic locking.
+type SQLStore struct {
+	db     *sql.DB
+	driver string
+	logger lager.Logger
+}
+
+// NewSQLStore opens the database and applies the pending schema migrations. The database/sql
+// driver (e.g. postgres, mysql or sqlite3) must be registered by the broker binary.
+func NewSQLStore(driver string, dsn string, logger lager.Logger) (*SQLStore, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SQLStore{
+		db:     db,
+		driver: driver,
+		logger: logger.Session("sql-store"),
+	}
+
+	if err = s.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Migrating the database schema: %s", err)
+	}
+
+	return s, nil
+}
+
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLStore) GetInstance(instanceID string) (Instance, error) {
+	var data string
+	var version int64
+
+	err := s.db.QueryRow(s.query("SELECT data, version FROM instances WHERE id = ?"), instanceID).Scan(&data, &version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Instance{}, ErrInstanceNotFound
+		}
+		return Instance{}, err
+	}
+
+	return decodeInstance(data, version)
+}
+
+func (s *SQLStore) PutInstance(instance Instance) error {
+	data, err := json.Marshal(instance)
+	if err != nil {
+		return err
+	}
+
+	if instance.Version == 0 {
+		if _, err = s.db.Exec(s.query("INSERT INTO instances (id, data, version) VALUES (?, ?, 1)"), instance.ID, string(data)); err != nil {
+			if _, getErr := s.GetInstance(instance.ID); getErr == nil {
+				return ErrConcurrentUpdate
+			}
+			return err
+		}
+		return nil
+	}
+
+	result, err := s.db.Exec(s.query("UPDATE instances SET data = ?, version = version + 1 WHERE id = ? AND version = ?"), string(data), instance.ID, instance.Version)
+	if err != nil {
+		return err
+	}
+
+	return expectRowsAffected(result, ErrConcurrentUpdate)
+}
+
+// DeleteInstance deletes the service instance along with its bindings.
+func (s *SQLStore) DeleteInstance(instanceID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(s.query("DELETE FROM bindings WHERE instance_id = ?"), instanceID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	result, err := tx.Exec(s.query("DELETE FROM instances WHERE id = ?"), instanceID)
+	if err == nil {
+		err = expectRowsAffected(result, ErrInstanceNotFound)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLStore) ListInstances() ([]Instance, error) {
+	rows, err := s.db.Query("SELECT data, version FROM instances ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	instances := []Instance{}
+	for rows.Next() {
+		var data string
+		var version int64
+		if err = rows.Scan(&data, &version); err != nil {
+			return nil, err
+		}
+
+		instance, err := decodeInstance(data, version)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+
+	return instances, rows.Err()
+}
+
+func (s *SQLStore) GetBinding(instanceID, bindingID string) (Binding, error) {
+	var data string
+
+	err := s.db.QueryRow(s.query("SELECT data FROM bindings WHERE instance_id = ? AND id = ?"), instanceID, bindingID).Scan(&data)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Binding{}, ErrBindingNotFound
+		}
+		return Binding{}, err
+	}
+
+	binding := Binding{}
+	if err = json.Unmarshal([]byte(data), &binding); err != nil {
+		return Binding{}, err
+	}
+
+	return binding, nil
+}
+
+func (s *SQLStore) PutBinding(binding Binding) error {
+	data, err := json.Marshal(binding)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(s.query("DELETE FROM bindings WHERE instance_id = ? AND id = ?"), binding.InstanceID, binding.ID); err == nil {
+		_, err = tx.Exec(s.query("INSERT INTO bindings (instance_id, id, data) VALUES (?, ?, ?)"), binding.InstanceID, binding.ID, string(data))
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLStore) DeleteBinding(instanceID, bindingID string) error {
+	result, err := s.db.Exec(s.query("DELETE FROM bindings WHERE instance_id = ? AND id = ?"), instanceID, bindingID)
+	if err != nil {
+		return err
+	}
+
+	return expectRowsAffected(result, ErrBindingNotFound)
+}
+
+func (s *SQLStore) ListBindings(instanceID string) ([]Binding, error) {
+	rows, err := s.db.Query(s.query("SELECT data FROM bindings WHERE instance_id = ? ORDER BY id"), instanceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bindings := []Binding{}
+	for rows.Next() {
+		var data string
+		if err = rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		binding := Binding{}
+		if err = json.Unmarshal([]byte(data), &binding); err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, binding)
+	}
+
+	return bindings, rows.Err()
+}
+
+func (s *SQLStore) Durable() bool {
+	return true
+}
+
+func (s *SQLStore) migrate() error {
+	unlock, err := s.lockMigrations()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := s.db.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL PRIMARY KEY)"); err != nil {
+		return err
+	}
+
+	var currentVersion int
+	if err := s.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&currentVersion); err != nil {
+		return err
+	}
+
+	for version := currentVersion + 1; version <= len(sqlMigrations); version++ {
+		s.logger.Info("migrate", lager.Data{"version": version})
+
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+
+		if _, err = tx.Exec(s.ddl(sqlMigrations[version-1])); err == nil {
+			_, err = tx.Exec(s.query("INSERT INTO schema_migrations (version) VALUES (?)"), version)
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %s", version, err)
+		}
+
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lockMigrations takes the lock serializing the schema migrations, so brokers sharing a
+// database and starting together do not apply the same migration twice. The lock is taken in
+// a transaction, which holds a single connection until the returned function releases it.
+// SQLite databases are not shared between brokers and are not locked.
+func (s *SQLStore) lockMigrations() (func(), error) {
+	if s.driver != "postgres" && s.driver != "mysql" {
+		return func() {}, nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.driver == "postgres" {
+		_, err = tx.Exec(s.query("SELECT pg_advisory_xact_lock(?)"), sqlMigrationsLockID)
+	} else {
+		var locked sql.NullInt64
+		err = tx.QueryRow("SELECT GET_LOCK(?, ?)", sqlMigrationsLock, sqlMigrationsLockTimeout).Scan(&locked)
+		if err == nil && locked.Int64 != 1 {
+			err = fmt.Errorf("timed out after %d seconds waiting for the '%s' lock", sqlMigrationsLockTimeout, sqlMigrationsLock)
+		}
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return func() {
+		if s.driver == "mysql" {
+			var released sql.NullInt64
+			if err := tx.QueryRow("SELECT RELEASE_LOCK(?)", sqlMigrationsLock).Scan(&released); err != nil {
+				s.logger.Error("unlock-migrations", err)
+			}
+		}
+		tx.Rollback()
+	}, nil
+}
+
+// ddl rewrites the column types of a migration for the driver in use: MySQL TEXT columns hold
+// at most 64KB, so the JSON documents are stored in MEDIUMTEXT columns instead.
+func (s *SQLStore) ddl(statement string) string {
+	if s.driver != "mysql" {
+		return statement
+	}
+
+	return strings.Replace(statement, " TEXT ", " MEDIUMTEXT ", -1)
+}
+
+// query rewrites the ? placeholders for drivers using numbered placeholders.
+func (s *SQLStore) query(query string) string {
+	if s.driver != "postgres" {
+		return query
+	}
+
+	parts := strings.Split(query, "?")
+	rewritten := parts[0]
+	for i, part := range parts[1:] {
+		rewritten += fmt.Sprintf("$%d%s", i+1, part)
+	}
+
+	return rewritten
+}
+
+func decodeInstance(data string, version int64) (Instance, error) {
+	instance := Instance{}
+	if err := json.Unmarshal([]byte(data), &instance); err != nil {
+		return Instance{}, err
+	}
+	instance.Version = version
+
+	return instance, nil
+}
+
+func expectRowsAffected(result sql.Result, notFoundErr error) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return notFoundErr
+	}
+
+	return nil
+}
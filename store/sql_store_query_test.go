@@ -0,0 +1,39 @@
+package store
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SQLStore query", func() {
+	It("numbers the placeholders for postgres", func() {
+		sqlStore := &SQLStore{driver: "postgres"}
+
+		Expect(sqlStore.query("UPDATE instances SET data = ? WHERE id = ? AND version = ?")).To(Equal("UPDATE instances SET data = $1 WHERE id = $2 AND version = $3"))
+	})
+
+	It("keeps the placeholders for other drivers", func() {
+		sqlStore := &SQLStore{driver: "mysql"}
+
+		Expect(sqlStore.query("UPDATE instances SET data = ? WHERE id = ?")).To(Equal("UPDATE instances SET data = ? WHERE id = ?"))
+	})
+
+	It("keeps queries without placeholders", func() {
+		sqlStore := &SQLStore{driver: "postgres"}
+
+		Expect(sqlStore.query("SELECT id FROM instances")).To(Equal("SELECT id FROM instances"))
+	})
+
+	It("stores the JSON documents in MEDIUMTEXT columns for mysql", func() {
+		sqlStore := &SQLStore{driver: "mysql"}
+
+		Expect(sqlStore.ddl(sqlMigrations[0])).To(ContainSubstring("data MEDIUMTEXT NOT NULL"))
+		Expect(sqlStore.ddl(sqlMigrations[1])).To(ContainSubstring("data MEDIUMTEXT NOT NULL"))
+	})
+
+	It("keeps the TEXT columns for other drivers", func() {
+		sqlStore := &SQLStore{driver: "postgres"}
+
+		Expect(sqlStore.ddl(sqlMigrations[0])).To(Equal(sqlMigrations[0]))
+	})
+})
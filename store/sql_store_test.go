@@ -0,0 +1,90 @@
+//go:build sqlite
+// +build sqlite
+
+package store_test
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+// The SQL store tests need the SQLite driver, run them with `go test -tags sqlite ./store`.
+var _ = Describe("SQLStore", func() {
+	var (
+		dir string
+		dsn string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "store")
+		Expect(err).ToNot(HaveOccurred())
+		dsn = filepath.Join(dir, "state.db")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	itBehavesLikeAStore(func() Store {
+		sqlStore, err := NewSQLStore("sqlite3", dsn, lagertest.NewTestLogger("store_test"))
+		Expect(err).ToNot(HaveOccurred())
+		return sqlStore
+	})
+
+	It("applies the schema migrations once and keeps the state", func() {
+		sqlStore, err := NewSQLStore("sqlite3", dsn, lagertest.NewTestLogger("store_test"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sqlStore.PutInstance(Instance{ID: "instance-1", PlanID: "Plan-1"})).To(Succeed())
+		Expect(sqlStore.PutBinding(Binding{ID: "binding-1", InstanceID: "instance-1"})).To(Succeed())
+		Expect(sqlStore.Close()).To(Succeed())
+
+		sqlStore, err = NewSQLStore("sqlite3", dsn, lagertest.NewTestLogger("store_test"))
+		Expect(err).ToNot(HaveOccurred())
+		defer sqlStore.Close()
+		Expect(sqlStore.GetInstance("instance-1")).To(Equal(Instance{ID: "instance-1", PlanID: "Plan-1", Version: 1}))
+		Expect(sqlStore.GetBinding("instance-1", "binding-1")).To(Equal(Binding{ID: "binding-1", InstanceID: "instance-1"}))
+	})
+
+	It("applies both schema migrations", func() {
+		sqlStore, err := NewSQLStore("sqlite3", dsn, lagertest.NewTestLogger("store_test"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sqlStore.Close()).To(Succeed())
+
+		db, err := sql.Open("sqlite3", dsn)
+		Expect(err).ToNot(HaveOccurred())
+		defer db.Close()
+
+		versions := []int{}
+		rows, err := db.Query("SELECT version FROM schema_migrations ORDER BY version")
+		Expect(err).ToNot(HaveOccurred())
+		for rows.Next() {
+			var version int
+			Expect(rows.Scan(&version)).To(Succeed())
+			versions = append(versions, version)
+		}
+		Expect(rows.Close()).To(Succeed())
+		Expect(versions).To(Equal([]int{1, 2}))
+
+		for _, table := range []string{"instances", "bindings"} {
+			var name string
+			Expect(db.QueryRow("SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&name)).To(Succeed())
+			Expect(name).To(Equal(table))
+		}
+	})
+
+	It("returns error if the driver is not registered", func() {
+		_, err := NewSQLStore("unknown", dsn, lagertest.NewTestLogger("store_test"))
+		Expect(err).To(HaveOccurred())
+	})
+})
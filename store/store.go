@@ -0,0 +1,121 @@
+// Package store records the service instances, bindings and in-flight operations handled by
+// the broker, which the OSBAPI requests alone do not carry.
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+type Store interface {
+	GetInstance(instanceID string) (Instance, error)
+	PutInstance(instance Instance) error
+	DeleteInstance(instanceID string) error
+	ListInstances() ([]Instance, error)
+	GetBinding(instanceID, bindingID string) (Binding, error)
+	PutBinding(binding Binding) error
+	DeleteBinding(instanceID, bindingID string) error
+	ListBindings(instanceID string) ([]Binding, error)
+	// Durable reports whether the state survives a broker restart.
+	Durable() bool
+}
+
+// Operation types.
+const (
+	OperationProvision   = "provision"
+	OperationUpdate      = "update"
+	OperationDeprovision = "deprovision"
+)
+
+type Instance struct {
+	ID             string                 `json:"id"`
+	ServiceID      string                 `json:"service_id"`
+	PlanID         string                 `json:"plan_id"`
+	OrganizationID string                 `json:"organization_id"`
+	SpaceID        string                 `json:"space_id"`
+	CacheClusterID string                 `json:"cache_cluster_id"`
+	Parameters     map[string]interface{} `json:"parameters,omitempty"`
+	Operation      *Operation             `json:"operation,omitempty"`
+	Failure        *Failure               `json:"failure,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+	// AdoptedTags are the tags of an adopted cache cluster before its adoption, restored when
+	// it is released.
+	AdoptedTags map[string]string `json:"adopted_tags,omitempty"`
+	// Version is incremented on every change. PutInstance fails with ErrConcurrentUpdate
+	// unless it is given the version last read, so concurrent changes are never lost.
+	Version int64 `json:"version"`
+}
+
+// Operation is an asynchronous operation in progress on a service instance. A pending
+// operation has been accepted but not yet issued to AWS, e.g. a deprovision waiting for
+// its cache cluster to be created.
+type Operation struct {
+	Type      string    `json:"type"`
+	StartedAt time.Time `json:"started_at"`
+	Pending   bool      `json:"pending,omitempty"`
+	// Workflow tracks the progress of operations made of several AWS calls.
+	Workflow *Workflow `json:"workflow,omitempty"`
+}
+
+// Failure records why the provision of a service instance failed, for operators. The
+// resources left behind by the failed provision are cleaned up by the broker.
+type Failure struct {
+	Reason    string    `json:"reason"`
+	FailedAt  time.Time `json:"failed_at"`
+	CleanedUp bool      `json:"cleaned_up"`
+}
+
+// Workflow states.
+const (
+	WorkflowPending   = "pending"
+	WorkflowRunning   = "running"
+	WorkflowSucceeded = "succeeded"
+	WorkflowFailed    = "failed"
+)
+
+// Workflow step states.
+const (
+	StepPending     = "pending"
+	StepDone        = "done"
+	StepCompensated = "compensated"
+)
+
+// Workflow is the progress of a multi-step operation, from which it is resumed after a broker
+// restart. Data holds the values steps pass on to later steps, e.g. the IDs of the resources
+// they created.
+type Workflow struct {
+	Name      string            `json:"name"`
+	State     string            `json:"state"`
+	Steps     []WorkflowStep    `json:"steps"`
+	Data      map[string]string `json:"data,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+type WorkflowStep struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+type Binding struct {
+	ID          string                 `json:"id"`
+	InstanceID  string                 `json:"instance_id"`
+	AppGUID     string                 `json:"app_guid,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+	Credentials *Credentials           `json:"credentials,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+// Credentials are the credentials handed to the application of a service binding.
+type Credentials struct {
+	Host string `json:"host,omitempty"`
+	Port int64  `json:"port,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+var (
+	ErrInstanceNotFound = errors.New("service instance not found")
+	ErrBindingNotFound  = errors.New("service binding not found")
+	ErrConcurrentUpdate = errors.New("service instance has been updated concurrently")
+)
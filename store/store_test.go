@@ -0,0 +1,218 @@
+package store_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+func itBehavesLikeAStore(newStore func() Store) {
+	var store Store
+
+	instance := Instance{
+		ID:             "instance-1",
+		ServiceID:      "Service-1",
+		PlanID:         "Plan-1",
+		OrganizationID: "organization-id",
+		SpaceID:        "space-id",
+		CacheClusterID: "cf-aso4rtfujlvj",
+		Parameters:     map[string]interface{}{"engine_version": "2.8.24"},
+		Operation:      &Operation{Type: OperationProvision, StartedAt: time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)},
+		CreatedAt:      time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC),
+		UpdatedAt:      time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	binding := Binding{
+		ID:         "binding-1",
+		InstanceID: "instance-1",
+		AppGUID:    "app-guid",
+		CreatedAt:  time.Date(2016, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+
+	BeforeEach(func() {
+		store = newStore()
+	})
+
+	It("records service instances", func() {
+		_, err := store.GetInstance("instance-1")
+		Expect(err).To(Equal(ErrInstanceNotFound))
+
+		Expect(store.PutInstance(instance)).To(Succeed())
+
+		storedInstance, err := store.GetInstance("instance-1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(storedInstance.Version).To(Equal(int64(1)))
+		storedInstance.Version = 0
+		Expect(storedInstance).To(Equal(instance))
+		Expect(store.ListInstances()).To(HaveLen(1))
+
+		Expect(store.DeleteInstance("instance-1")).To(Succeed())
+		Expect(store.ListInstances()).To(BeEmpty())
+		Expect(store.DeleteInstance("instance-1")).To(Equal(ErrInstanceNotFound))
+	})
+
+	It("refuses concurrent service instance updates", func() {
+		Expect(store.PutInstance(instance)).To(Succeed())
+		Expect(store.PutInstance(instance)).To(Equal(ErrConcurrentUpdate))
+
+		storedInstance, err := store.GetInstance("instance-1")
+		Expect(err).ToNot(HaveOccurred())
+
+		updatedInstance := storedInstance
+		updatedInstance.PlanID = "Plan-2"
+		Expect(store.PutInstance(updatedInstance)).To(Succeed())
+
+		storedInstance.PlanID = "Plan-3"
+		Expect(store.PutInstance(storedInstance)).To(Equal(ErrConcurrentUpdate))
+
+		storedInstance, err = store.GetInstance("instance-1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(storedInstance.PlanID).To(Equal("Plan-2"))
+		Expect(storedInstance.Version).To(Equal(int64(2)))
+	})
+
+	It("records workflow progress without sharing it with callers", func() {
+		workflowInstance := instance
+		workflowInstance.Operation = &Operation{
+			Type: OperationProvision,
+			Workflow: &Workflow{
+				Name:  "provision",
+				State: WorkflowRunning,
+				Steps: []WorkflowStep{WorkflowStep{Name: "create-cache-cluster", State: StepPending}},
+				Data:  map[string]string{"cache-subnet-group": "cf-subnet-group"},
+			},
+		}
+		Expect(store.PutInstance(workflowInstance)).To(Succeed())
+
+		workflowInstance.Operation.Workflow.Steps[0].State = StepDone
+		workflowInstance.Operation.Workflow.Data["cache-subnet-group"] = "changed"
+
+		storedInstance, err := store.GetInstance("instance-1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(storedInstance.Operation.Workflow.Steps[0].State).To(Equal(StepPending))
+		Expect(storedInstance.Operation.Workflow.Data).To(Equal(map[string]string{"cache-subnet-group": "cf-subnet-group"}))
+	})
+
+	It("records service bindings", func() {
+		_, err := store.GetBinding("instance-1", "binding-1")
+		Expect(err).To(Equal(ErrBindingNotFound))
+
+		Expect(store.PutBinding(binding)).To(Succeed())
+		Expect(store.GetBinding("instance-1", "binding-1")).To(Equal(binding))
+		Expect(store.ListBindings("instance-1")).To(Equal([]Binding{binding}))
+		Expect(store.ListBindings("instance-2")).To(BeEmpty())
+
+		Expect(store.DeleteBinding("instance-1", "binding-1")).To(Succeed())
+		Expect(store.ListBindings("instance-1")).To(BeEmpty())
+		Expect(store.DeleteBinding("instance-1", "binding-1")).To(Equal(ErrBindingNotFound))
+	})
+
+	It("deletes the bindings of deleted service instances", func() {
+		Expect(store.PutInstance(instance)).To(Succeed())
+		Expect(store.PutBinding(binding)).To(Succeed())
+
+		Expect(store.DeleteInstance("instance-1")).To(Succeed())
+		Expect(store.ListBindings("instance-1")).To(BeEmpty())
+	})
+}
+
+var _ = Describe("MemoryStore", func() {
+	itBehavesLikeAStore(func() Store {
+		return NewMemoryStore()
+	})
+
+	It("is not durable", func() {
+		Expect(NewMemoryStore().Durable()).To(BeFalse())
+	})
+})
+
+var _ = Describe("FileStore", func() {
+	var (
+		dir  string
+		path string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "store")
+		Expect(err).ToNot(HaveOccurred())
+		path = filepath.Join(dir, "state.json")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	itBehavesLikeAStore(func() Store {
+		fileStore, err := NewFileStore(path, lagertest.NewTestLogger("store_test"))
+		Expect(err).ToNot(HaveOccurred())
+		return fileStore
+	})
+
+	It("persists the state across restarts", func() {
+		fileStore, err := NewFileStore(path, lagertest.NewTestLogger("store_test"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fileStore.Durable()).To(BeTrue())
+		Expect(fileStore.PutInstance(Instance{ID: "instance-1", PlanID: "Plan-1"})).To(Succeed())
+		Expect(fileStore.PutBinding(Binding{ID: "binding-1", InstanceID: "instance-1"})).To(Succeed())
+
+		fileStore, err = NewFileStore(path, lagertest.NewTestLogger("store_test"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fileStore.GetInstance("instance-1")).To(Equal(Instance{ID: "instance-1", PlanID: "Plan-1", Version: 1}))
+		Expect(fileStore.GetBinding("instance-1", "binding-1")).To(Equal(Binding{ID: "binding-1", InstanceID: "instance-1"}))
+	})
+
+	It("does not apply changes that cannot be persisted", func() {
+		fileStore, err := NewFileStore(path, lagertest.NewTestLogger("store_test"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fileStore.PutInstance(Instance{ID: "instance-1", PlanID: "Plan-1"})).To(Succeed())
+		Expect(os.RemoveAll(dir)).To(Succeed())
+
+		Expect(fileStore.PutInstance(Instance{ID: "instance-1", PlanID: "Plan-2", Version: 1})).ToNot(Succeed())
+		Expect(fileStore.DeleteInstance("instance-1")).ToNot(Succeed())
+		Expect(fileStore.GetInstance("instance-1")).To(Equal(Instance{ID: "instance-1", PlanID: "Plan-1", Version: 1}))
+	})
+
+	It("returns error if the state file is not valid", func() {
+		Expect(ioutil.WriteFile(path, []byte("not json"), 0600)).To(Succeed())
+
+		_, err := NewFileStore(path, lagertest.NewTestLogger("store_test"))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Config", func() {
+	It("defaults to an in-memory store", func() {
+		Expect(Config{}.Validate()).To(Succeed())
+	})
+
+	It("warns that the state of an in-memory store is lost on restart", func() {
+		logger := lagertest.NewTestLogger("store_test")
+
+		brokerStore, err := New(Config{}, logger)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(brokerStore).To(BeAssignableToTypeOf(&MemoryStore{}))
+		Expect(logger.LogMessages()).To(ContainElement("store_test.memory-store"))
+	})
+
+	It("returns error if the file store Path is empty", func() {
+		Expect(Config{Type: TypeFile}.Validate()).To(MatchError("Must provide a non-empty Path"))
+	})
+
+	It("returns error if the SQL store Driver or DSN is empty", func() {
+		Expect(Config{Type: TypeSQL, DSN: "postgres://localhost/broker"}.Validate()).To(MatchError("Must provide a non-empty Driver"))
+		Expect(Config{Type: TypeSQL, Driver: "postgres"}.Validate()).To(MatchError("Must provide a non-empty DSN"))
+	})
+
+	It("returns error if the Type is not valid", func() {
+		Expect(Config{Type: "bolt"}.Validate()).To(MatchError("Invalid Type 'bolt', must be one of: memory, file, sql"))
+	})
+})
@@ -0,0 +1,273 @@
+// Package workflow runs operations made of several steps, in the background of the requests
+// starting them. The progress of each step is recorded on the service instance operation in
+// the broker store, so that workflows interrupted by a broker restart are resumed, and steps
+// already done are compensated (rolled back) when a later step fails.
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+)
+
+const instanceIDLogKey = "instance-id"
+const workflowLogKey = "workflow"
+const stepLogKey = "step"
+
+var (
+	ErrNoOperation     = errors.New("service instance has no operation in progress")
+	ErrUnknownWorkflow = errors.New("unknown workflow")
+)
+
+// Step is a single step of a workflow.
+type Step struct {
+	Name string
+	// Run performs the step. It must be idempotent, as a step interrupted by a broker restart
+	// is run again. Values needed by later steps are recorded in data.
+	Run func(data map[string]string) error
+	// Compensate undoes the step when a later step fails. It may be nil.
+	Compensate func(data map[string]string) error
+}
+
+// Builder returns the steps of a workflow for a service instance.
+type Builder func(instance store.Instance) ([]Step, error)
+
+type Engine struct {
+	store    store.Store
+	builders map[string]Builder
+	started  chan struct{}
+	logger   lager.Logger
+}
+
+func NewEngine(stateStore store.Store, logger lager.Logger) *Engine {
+	return &Engine{
+		store:    stateStore,
+		builders: make(map[string]Builder),
+		started:  make(chan struct{}, 1),
+		logger:   logger.Session("workflow"),
+	}
+}
+
+// Register makes a workflow available to Start and Resume.
+func (e *Engine) Register(name string, builder Builder) {
+	e.builders[name] = builder
+}
+
+// Start attaches a new workflow to the operation in progress on the service instance and
+// records it as pending. Its steps are not run by Start but by the next Resume, which callers
+// are told to run through Started.
+func (e *Engine) Start(instanceID string, name string) error {
+	instance, err := e.store.GetInstance(instanceID)
+	if err != nil {
+		return err
+	}
+
+	if instance.Operation == nil {
+		return ErrNoOperation
+	}
+
+	steps, err := e.build(name, instance)
+	if err != nil {
+		return err
+	}
+
+	workflow := &store.Workflow{
+		Name:  name,
+		State: store.WorkflowPending,
+		Data:  make(map[string]string),
+	}
+	for _, step := range steps {
+		workflow.Steps = append(workflow.Steps, store.WorkflowStep{Name: step.Name, State: store.StepPending})
+	}
+	instance.Operation.Workflow = workflow
+
+	if _, err = e.save(instance); err != nil {
+		return err
+	}
+
+	select {
+	case e.started <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Started is signaled when a workflow is started, to run it without waiting for the next
+// periodic Resume.
+func (e *Engine) Started() <-chan struct{} {
+	return e.started
+}
+
+// Resume runs the pending workflows, and the workflows left running by a broker that stopped,
+// i.e. whose progress has not been recorded for staleAfter. A workflow is claimed in the store
+// before being run, so only one broker replica runs it.
+func (e *Engine) Resume(staleAfter time.Duration) error {
+	instances, err := e.store.ListInstances()
+	if err != nil {
+		return err
+	}
+
+	for _, instance := range instances {
+		if instance.Operation == nil || instance.Operation.Workflow == nil {
+			continue
+		}
+
+		workflow := instance.Operation.Workflow
+		action := "run-workflow"
+		switch workflow.State {
+		case store.WorkflowPending:
+		case store.WorkflowRunning:
+			if time.Since(workflow.UpdatedAt) < staleAfter {
+				continue
+			}
+			action = "resume-workflow"
+		default:
+			continue
+		}
+
+		logData := lager.Data{instanceIDLogKey: instance.ID, workflowLogKey: workflow.Name}
+
+		workflow.State = store.WorkflowRunning
+		if instance, err = e.save(instance); err != nil {
+			if err != store.ErrConcurrentUpdate {
+				e.logger.Error("claim-workflow", err, logData)
+			}
+			continue
+		}
+
+		e.logger.Info(action, logData)
+
+		var steps []Step
+		steps, err = e.build(workflow.Name, instance)
+		if err == nil {
+			err = checkSteps(instance.Operation.Workflow, steps)
+		}
+		if err != nil {
+			e.logger.Error(action, err, logData)
+			if err = e.fail(instance, err); err != nil {
+				e.logger.Error("fail-workflow", err, logData)
+			}
+			continue
+		}
+
+		if err = e.run(instance, steps); err != nil {
+			e.logger.Error(action, err, logData)
+		}
+	}
+
+	return nil
+}
+
+func (e *Engine) build(name string, instance store.Instance) ([]Step, error) {
+	builder, ok := e.builders[name]
+	if !ok {
+		return nil, ErrUnknownWorkflow
+	}
+
+	return builder(instance)
+}
+
+// checkSteps makes sure a recorded workflow still has the steps the broker would run, as
+// they may change between broker versions.
+func checkSteps(workflow *store.Workflow, steps []Step) error {
+	if len(workflow.Steps) != len(steps) {
+		return fmt.Errorf("Workflow '%s' has %d recorded steps, expected %d", workflow.Name, len(workflow.Steps), len(steps))
+	}
+
+	for i, step := range steps {
+		if workflow.Steps[i].Name != step.Name {
+			return fmt.Errorf("Workflow '%s' step %d is '%s', expected '%s'", workflow.Name, i+1, workflow.Steps[i].Name, step.Name)
+		}
+	}
+
+	return nil
+}
+
+func (e *Engine) run(instance store.Instance, steps []Step) error {
+	var err error
+
+	for i, step := range steps {
+		workflow := instance.Operation.Workflow
+		if workflow.Data == nil {
+			workflow.Data = make(map[string]string)
+		}
+		if workflow.Steps[i].State == store.StepDone {
+			continue
+		}
+
+		logData := lager.Data{instanceIDLogKey: instance.ID, workflowLogKey: workflow.Name, stepLogKey: step.Name}
+		e.logger.Debug("run-step", logData)
+
+		if err = step.Run(workflow.Data); err != nil {
+			e.logger.Error("run-step", err, logData)
+			return e.compensate(instance, steps, i, err)
+		}
+
+		workflow.Steps[i].State = store.StepDone
+		if instance, err = e.save(instance); err != nil {
+			return err
+		}
+	}
+
+	instance.Operation.Workflow.State = store.WorkflowSucceeded
+	_, err = e.save(instance)
+
+	return err
+}
+
+// compensate undoes the steps done before the failed step, in reverse order, and records the
+// workflow failure. Compensation errors are logged, the resources involved are left behind.
+func (e *Engine) compensate(instance store.Instance, steps []Step, failedStep int, stepErr error) error {
+	workflow := instance.Operation.Workflow
+
+	for i := failedStep - 1; i >= 0; i-- {
+		if workflow.Steps[i].State != store.StepDone {
+			continue
+		}
+
+		if steps[i].Compensate != nil {
+			logData := lager.Data{instanceIDLogKey: instance.ID, workflowLogKey: workflow.Name, stepLogKey: steps[i].Name}
+			if err := steps[i].Compensate(workflow.Data); err != nil {
+				e.logger.Error("compensate-step", err, logData)
+				continue
+			}
+			e.logger.Info("compensated-step", logData)
+		}
+
+		workflow.Steps[i].State = store.StepCompensated
+	}
+
+	if err := e.fail(instance, fmt.Errorf("Step '%s' failed: %s", steps[failedStep].Name, stepErr)); err != nil {
+		return err
+	}
+
+	return stepErr
+}
+
+func (e *Engine) fail(instance store.Instance, err error) error {
+	instance.Operation.Workflow.State = store.WorkflowFailed
+	instance.Operation.Workflow.Error = err.Error()
+
+	_, saveErr := e.save(instance)
+
+	return saveErr
+}
+
+// save records the workflow progress and returns the service instance as now stored.
+func (e *Engine) save(instance store.Instance) (store.Instance, error) {
+	now := time.Now()
+	instance.Operation.Workflow.UpdatedAt = now
+	instance.UpdatedAt = now
+
+	if err := e.store.PutInstance(instance); err != nil {
+		return instance, err
+	}
+	instance.Version++
+
+	return instance, nil
+}
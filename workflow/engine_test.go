@@ -0,0 +1,170 @@
+package workflow_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/pivotal-golang/lager/lagertest"
+
+	"github.com/cloudfoundry-community/elasticache-broker/store"
+	. "github.com/cloudfoundry-community/elasticache-broker/workflow"
+)
+
+var _ = Describe("Engine", func() {
+	const instanceID = "instance-1"
+
+	var (
+		brokerStore *store.MemoryStore
+		engine      *Engine
+
+		ran         []string
+		compensated []string
+		failingStep string
+	)
+
+	step := func(name string) Step {
+		return Step{
+			Name: name,
+			Run: func(data map[string]string) error {
+				if name == failingStep {
+					return errors.New("step failed")
+				}
+				ran = append(ran, name)
+				data[name] = "done"
+				return nil
+			},
+			Compensate: func(data map[string]string) error {
+				compensated = append(compensated, name)
+				return nil
+			},
+		}
+	}
+
+	storedWorkflow := func() *store.Workflow {
+		instance, err := brokerStore.GetInstance(instanceID)
+		Expect(err).ToNot(HaveOccurred())
+		return instance.Operation.Workflow
+	}
+
+	BeforeEach(func() {
+		ran = nil
+		compensated = nil
+		failingStep = ""
+
+		brokerStore = store.NewMemoryStore()
+		Expect(brokerStore.PutInstance(store.Instance{
+			ID:        instanceID,
+			Operation: &store.Operation{Type: store.OperationProvision},
+		})).To(Succeed())
+
+		engine = NewEngine(brokerStore, lagertest.NewTestLogger("workflow_test"))
+		engine.Register("provision", func(instance store.Instance) ([]Step, error) {
+			return []Step{step("first"), step("second"), step("third")}, nil
+		})
+	})
+
+	It("records the workflow without running its steps", func() {
+		Expect(engine.Start(instanceID, "provision")).To(Succeed())
+		Expect(ran).To(BeEmpty())
+		Expect(engine.Started()).To(Receive())
+
+		workflow := storedWorkflow()
+		Expect(workflow.State).To(Equal(store.WorkflowPending))
+		Expect(workflow.Steps).To(Equal([]store.WorkflowStep{
+			store.WorkflowStep{Name: "first", State: store.StepPending},
+			store.WorkflowStep{Name: "second", State: store.StepPending},
+			store.WorkflowStep{Name: "third", State: store.StepPending},
+		}))
+	})
+
+	It("runs the steps of started workflows in order and records their progress", func() {
+		Expect(engine.Start(instanceID, "provision")).To(Succeed())
+		Expect(engine.Resume(time.Hour)).To(Succeed())
+		Expect(ran).To(Equal([]string{"first", "second", "third"}))
+
+		workflow := storedWorkflow()
+		Expect(workflow.State).To(Equal(store.WorkflowSucceeded))
+		Expect(workflow.Steps).To(Equal([]store.WorkflowStep{
+			store.WorkflowStep{Name: "first", State: store.StepDone},
+			store.WorkflowStep{Name: "second", State: store.StepDone},
+			store.WorkflowStep{Name: "third", State: store.StepDone},
+		}))
+		Expect(workflow.Data).To(HaveKeyWithValue("second", "done"))
+	})
+
+	It("compensates the steps done when a step fails", func() {
+		failingStep = "third"
+
+		Expect(engine.Start(instanceID, "provision")).To(Succeed())
+		Expect(engine.Resume(time.Hour)).To(Succeed())
+		Expect(compensated).To(Equal([]string{"second", "first"}))
+
+		workflow := storedWorkflow()
+		Expect(workflow.State).To(Equal(store.WorkflowFailed))
+		Expect(workflow.Error).To(Equal("Step 'third' failed: step failed"))
+		Expect(workflow.Steps[0].State).To(Equal(store.StepCompensated))
+		Expect(workflow.Steps[2].State).To(Equal(store.StepPending))
+	})
+
+	It("returns error if the workflow is unknown", func() {
+		Expect(engine.Start(instanceID, "unknown")).To(Equal(ErrUnknownWorkflow))
+	})
+
+	It("returns error if the service instance has no operation in progress", func() {
+		Expect(brokerStore.PutInstance(store.Instance{ID: "instance-2"})).To(Succeed())
+
+		Expect(engine.Start("instance-2", "provision")).To(Equal(ErrNoOperation))
+	})
+
+	Describe("Resume", func() {
+		BeforeEach(func() {
+			instance, err := brokerStore.GetInstance(instanceID)
+			Expect(err).ToNot(HaveOccurred())
+			instance.Operation.Workflow = &store.Workflow{
+				Name:  "provision",
+				State: store.WorkflowRunning,
+				Steps: []store.WorkflowStep{
+					store.WorkflowStep{Name: "first", State: store.StepDone},
+					store.WorkflowStep{Name: "second", State: store.StepPending},
+					store.WorkflowStep{Name: "third", State: store.StepPending},
+				},
+				UpdatedAt: time.Now().Add(-time.Hour),
+			}
+			Expect(brokerStore.PutInstance(instance)).To(Succeed())
+		})
+
+		It("runs the remaining steps of interrupted workflows", func() {
+			Expect(engine.Resume(time.Minute)).To(Succeed())
+			Expect(ran).To(Equal([]string{"second", "third"}))
+			Expect(storedWorkflow().State).To(Equal(store.WorkflowSucceeded))
+		})
+
+		It("leaves workflows that are still making progress", func() {
+			Expect(engine.Resume(2 * time.Hour)).To(Succeed())
+			Expect(ran).To(BeEmpty())
+			Expect(storedWorkflow().State).To(Equal(store.WorkflowRunning))
+		})
+
+		It("compensates resumed workflows that fail", func() {
+			failingStep = "second"
+
+			Expect(engine.Resume(time.Minute)).To(Succeed())
+			Expect(compensated).To(Equal([]string{"first"}))
+			Expect(storedWorkflow().State).To(Equal(store.WorkflowFailed))
+		})
+
+		It("fails workflows whose steps changed", func() {
+			engine.Register("provision", func(instance store.Instance) ([]Step, error) {
+				return []Step{step("first"), step("third")}, nil
+			})
+
+			Expect(engine.Resume(time.Minute)).To(Succeed())
+			Expect(ran).To(BeEmpty())
+			Expect(storedWorkflow().State).To(Equal(store.WorkflowFailed))
+			Expect(storedWorkflow().Error).To(ContainSubstring("has 3 recorded steps, expected 2"))
+		})
+	})
+})